@@ -0,0 +1,149 @@
+package geometry
+
+import (
+	"errors"
+	"math"
+)
+
+// Affine is a 3D affine transformation mapping (x, y, z) to
+//
+//	x' = A*x + B*y + C*z + XOff
+//	y' = D*x + E*y + F*z + YOff
+//	z' = G*x + H*y + I*z + ZOff
+//
+// 2D transformations leave C, F, G and H at zero and I at one.
+type Affine struct {
+	A, B, C, XOff float64
+	D, E, F, YOff float64
+	G, H, I, ZOff float64
+}
+
+func AffineIdentity() Affine {
+	return Affine{A: 1, E: 1, I: 1}
+}
+
+// Affine2D builds a 2D transformation from the matrix coefficients in the
+// same order as shapely: x' = a*x + b*y + xoff, y' = d*x + e*y + yoff.
+func Affine2D(a, b, d, e, xoff, yoff float64) Affine {
+	return Affine{A: a, B: b, XOff: xoff, D: d, E: e, YOff: yoff, I: 1}
+}
+
+func AffineTranslate(dx, dy, dz float64) Affine {
+	return Affine{A: 1, XOff: dx, E: 1, YOff: dy, I: 1, ZOff: dz}
+}
+
+func AffineScale(sx, sy, sz float64) Affine {
+	return Affine{A: sx, E: sy, I: sz}
+}
+
+// AffineRotate rotates counter-clockwise by angle degrees around the
+// origin in the XY plane.
+func AffineRotate(angle float64) Affine {
+	s, c := math.Sincos(angle * deg)
+	return Affine{A: c, B: -s, D: s, E: c, I: 1}
+}
+
+// AffineRotateAround rotates counter-clockwise by angle degrees around
+// the point (x, y).
+func AffineRotateAround(angle, x, y float64) Affine {
+	return AffineTranslate(x, y, 0).Multiply(AffineRotate(angle)).Multiply(AffineTranslate(-x, -y, 0))
+}
+
+// AffineSkew shears along the X and Y axes by angles in degrees.
+func AffineSkew(xs, ys float64) Affine {
+	return Affine{A: 1, B: math.Tan(xs * deg), D: math.Tan(ys * deg), E: 1, I: 1}
+}
+
+// AffineFromGeoTransform builds the pixel to world transformation
+// described by a GDAL geotransform, mapping (column, row) to (x, y).
+func AffineFromGeoTransform(gt [6]float64) Affine {
+	return Affine{A: gt[1], B: gt[2], XOff: gt[0], D: gt[4], E: gt[5], YOff: gt[3], I: 1}
+}
+
+// GeoTransform returns the 2D part of the transformation as a GDAL
+// geotransform.
+func (a Affine) GeoTransform() [6]float64 {
+	return [6]float64{a.XOff, a.A, a.B, a.YOff, a.D, a.E}
+}
+
+// Multiply composes two transformations. The result applies b first and
+// then a.
+func (a Affine) Multiply(b Affine) Affine {
+	return Affine{
+		A:    a.A*b.A + a.B*b.D + a.C*b.G,
+		B:    a.A*b.B + a.B*b.E + a.C*b.H,
+		C:    a.A*b.C + a.B*b.F + a.C*b.I,
+		XOff: a.A*b.XOff + a.B*b.YOff + a.C*b.ZOff + a.XOff,
+		D:    a.D*b.A + a.E*b.D + a.F*b.G,
+		E:    a.D*b.B + a.E*b.E + a.F*b.H,
+		F:    a.D*b.C + a.E*b.F + a.F*b.I,
+		YOff: a.D*b.XOff + a.E*b.YOff + a.F*b.ZOff + a.YOff,
+		G:    a.G*b.A + a.H*b.D + a.I*b.G,
+		H:    a.G*b.B + a.H*b.E + a.I*b.H,
+		I:    a.G*b.C + a.H*b.F + a.I*b.I,
+		ZOff: a.G*b.XOff + a.H*b.YOff + a.I*b.ZOff + a.ZOff,
+	}
+}
+
+func (a Affine) Determinant() float64 {
+	return a.A*(a.E*a.I-a.F*a.H) - a.B*(a.D*a.I-a.F*a.G) + a.C*(a.D*a.H-a.E*a.G)
+}
+
+func (a Affine) Inverse() (Affine, error) {
+	det := a.Determinant()
+	if det == 0 || math.IsNaN(det) {
+		return Affine{}, errors.New("Affine transformation is not invertible")
+	}
+	inv := Affine{
+		A: (a.E*a.I - a.F*a.H) / det,
+		B: (a.C*a.H - a.B*a.I) / det,
+		C: (a.B*a.F - a.C*a.E) / det,
+		D: (a.F*a.G - a.D*a.I) / det,
+		E: (a.A*a.I - a.C*a.G) / det,
+		F: (a.C*a.D - a.A*a.F) / det,
+		G: (a.D*a.H - a.E*a.G) / det,
+		H: (a.B*a.G - a.A*a.H) / det,
+		I: (a.A*a.E - a.B*a.D) / det,
+	}
+	off := inv.ApplyPoint(Point{X: a.XOff, Y: a.YOff, Z: a.ZOff})
+	inv.XOff, inv.YOff, inv.ZOff = -off.X, -off.Y, -off.Z
+	return inv, nil
+}
+
+func (a Affine) ApplyPoint(p Point) Point {
+	return Point{
+		X: a.A*p.X + a.B*p.Y + a.C*p.Z + a.XOff,
+		Y: a.D*p.X + a.E*p.Y + a.F*p.Z + a.YOff,
+		Z: a.G*p.X + a.H*p.Y + a.I*p.Z + a.ZOff,
+	}
+}
+
+func (a Affine) ApplyRing(r LinearRing) LinearRing {
+	out, _ := mapPoints(r, a.mapper)
+	return LinearRing(out)
+}
+
+// Apply returns a copy of the geometry with the transformation applied to
+// every coordinate.
+func (a Affine) Apply(g Geometry) (Geometry, error) {
+	return mapGeometry(g, a.mapper)
+}
+
+// ApplyCollection transforms the geometry of every feature in the
+// collection.
+func (a Affine) ApplyCollection(fc *FeatureCollection) (*FeatureCollection, error) {
+	out := &FeatureCollection{Type: fc.Type, Features: make([]Feature, len(fc.Features))}
+	for i, f := range fc.Features {
+		g, err := a.Apply(f.Geometry)
+		if err != nil {
+			return nil, err
+		}
+		out.Features[i] = f
+		out.Features[i].Geometry = g
+	}
+	return out, nil
+}
+
+func (a Affine) mapper(p Point) (Point, error) {
+	return a.ApplyPoint(p), nil
+}
@@ -0,0 +1,122 @@
+package geometry
+
+import (
+	"fmt"
+	"math"
+)
+
+// Bounds is an axis aligned bounding box in the XY plane. The zero value
+// is the degenerate box at the origin, use EmptyBounds for a box that
+// contains nothing.
+type Bounds struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+func EmptyBounds() Bounds {
+	return Bounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+}
+
+func (b Bounds) IsEmpty() bool {
+	return b.MinX > b.MaxX || b.MinY > b.MaxY
+}
+
+func (b Bounds) ExtendPoint(p Point) Bounds {
+	return Bounds{math.Min(b.MinX, p.X), math.Min(b.MinY, p.Y), math.Max(b.MaxX, p.X), math.Max(b.MaxY, p.Y)}
+}
+
+func (b Bounds) Extend(c Bounds) Bounds {
+	return Bounds{math.Min(b.MinX, c.MinX), math.Min(b.MinY, c.MinY), math.Max(b.MaxX, c.MaxX), math.Max(b.MaxY, c.MaxY)}
+}
+
+func (b Bounds) Intersects(c Bounds) bool {
+	return b.MinX <= c.MaxX && c.MinX <= b.MaxX && b.MinY <= c.MaxY && c.MinY <= b.MaxY
+}
+
+func (b Bounds) Contains(c Bounds) bool {
+	return b.MinX <= c.MinX && c.MaxX <= b.MaxX && b.MinY <= c.MinY && c.MaxY <= b.MaxY
+}
+
+func (b Bounds) Area() float64 {
+	if b.IsEmpty() {
+		return 0
+	}
+	return (b.MaxX - b.MinX) * (b.MaxY - b.MinY)
+}
+
+func (b Bounds) Margin() float64 {
+	if b.IsEmpty() {
+		return 0
+	}
+	return (b.MaxX - b.MinX) + (b.MaxY - b.MinY)
+}
+
+func (b Bounds) Centre() Point {
+	return Point{X: (b.MinX + b.MaxX) / 2, Y: (b.MinY + b.MaxY) / 2}
+}
+
+// Distance returns the distance from p to the closest point of the box,
+// zero if p is inside it.
+func (b Bounds) Distance(p Point) float64 {
+	dx := math.Max(0, math.Max(b.MinX-p.X, p.X-b.MaxX))
+	dy := math.Max(0, math.Max(b.MinY-p.Y, p.Y-b.MaxY))
+	return math.Hypot(dx, dy)
+}
+
+func (b Bounds) AsPolygon() Polygon {
+	return Polygon{LinearRing{{X: b.MinX, Y: b.MinY}, {X: b.MaxX, Y: b.MinY}, {X: b.MaxX, Y: b.MaxY}, {X: b.MinX, Y: b.MaxY}}}
+}
+
+func pointsBounds(pts []Point) Bounds {
+	b := EmptyBounds()
+	for _, p := range pts {
+		b = b.ExtendPoint(p)
+	}
+	return b
+}
+
+func (p *Point) Bounds() Bounds {
+	return Bounds{p.X, p.Y, p.X, p.Y}
+}
+
+func (m *MultiPoint) Bounds() Bounds {
+	return pointsBounds(*m)
+}
+
+func (l LineString) Bounds() Bounds {
+	return pointsBounds(l)
+}
+
+func (r LinearRing) Bounds() Bounds {
+	return pointsBounds(r)
+}
+
+func (p *Polygon) Bounds() Bounds {
+	if len(*p) == 0 {
+		return EmptyBounds()
+	}
+	return (*p)[0].Bounds()
+}
+
+func (m *MultiLineString) Bounds() Bounds {
+	b := EmptyBounds()
+	for _, l := range *m {
+		b = b.Extend(l.Bounds())
+	}
+	return b
+}
+
+func (m *MultiPolygon) Bounds() Bounds {
+	b := EmptyBounds()
+	for _, p := range *m {
+		b = b.Extend(p.Bounds())
+	}
+	return b
+}
+
+// BoundsOf returns the bounding box of any geometry in the package.
+func BoundsOf(g Geometry) (Bounds, error) {
+	if b, ok := g.(interface{ Bounds() Bounds }); ok {
+		return b.Bounds(), nil
+	}
+	return Bounds{}, fmt.Errorf("Geometry %T has no bounds", g)
+}
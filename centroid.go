@@ -0,0 +1,309 @@
+package geometry
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// lineMoments returns the length of a line and the sums of its segment
+// midpoints weighted by segment length.
+func lineMoments(pts []Point, closed bool) (float64, float64, float64) {
+	length, mx, my := 0.0, 0.0, 0.0
+	n := len(pts)
+	if !closed {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		a, b := pts[i], pts[(i+1)%len(pts)]
+		l := math.Hypot(b.X-a.X, b.Y-a.Y)
+		length += l
+		mx += l * (a.X + b.X) / 2
+		my += l * (a.Y + b.Y) / 2
+	}
+	return length, mx, my
+}
+
+// polygonMoments returns the area of a polygon, less its holes, and its
+// first moments of area. Ring orientation is ignored.
+func polygonMoments(p Polygon) (float64, float64, float64) {
+	area, mx, my := 0.0, 0.0, 0.0
+	for i, r := range p {
+		a, cx, cy := 0.0, 0.0, 0.0
+		for j := range r {
+			u, v := r[j], r[(j+1)%len(r)]
+			c := u.X*v.Y - v.X*u.Y
+			a += c / 2
+			cx += (u.X + v.X) * c / 6
+			cy += (u.Y + v.Y) * c / 6
+		}
+		if (i == 0) != (a > 0) {
+			a, cx, cy = -a, -cx, -cy
+		}
+		area += a
+		mx += cx
+		my += cy
+	}
+	return area, mx, my
+}
+
+func meanPoint(pts []Point) Point {
+	c := Point{}
+	for _, pt := range pts {
+		c.X += pt.X / float64(len(pts))
+		c.Y += pt.Y / float64(len(pts))
+	}
+	return c
+}
+
+func (p *Point) Centroid() Point {
+	return Point{X: p.X, Y: p.Y}
+}
+
+func (m *MultiPoint) Centroid() Point {
+	return meanPoint(*m)
+}
+
+// Centroid returns the length weighted centre of the line, or the mean of
+// its points if it has no length.
+func (l LineString) Centroid() Point {
+	length, mx, my := lineMoments(l, false)
+	if length == 0 {
+		return meanPoint(l)
+	}
+	return Point{X: mx / length, Y: my / length}
+}
+
+func (m *MultiLineString) Centroid() Point {
+	length, mx, my := 0.0, 0.0, 0.0
+	pts := []Point{}
+	for _, l := range *m {
+		ll, lx, ly := lineMoments(l, false)
+		length, mx, my = length+ll, mx+lx, my+ly
+		pts = append(pts, l...)
+	}
+	if length == 0 {
+		return meanPoint(pts)
+	}
+	return Point{X: mx / length, Y: my / length}
+}
+
+// Centroid returns the area weighted centre of the polygon. Polygons with
+// no area fall back to the centroid of their boundary.
+func (p *Polygon) Centroid() Point {
+	m := MultiPolygon{*p}
+	return m.Centroid()
+}
+
+func (m *MultiPolygon) Centroid() Point {
+	area, mx, my := 0.0, 0.0, 0.0
+	for _, p := range *m {
+		a, x, y := polygonMoments(p)
+		area, mx, my = area+a, mx+x, my+y
+	}
+	if area != 0 {
+		return Point{X: mx / area, Y: my / area}
+	}
+
+	length, pts := 0.0, []Point{}
+	for _, p := range *m {
+		for _, r := range p {
+			l, x, y := lineMoments(r, true)
+			length, mx, my = length+l, mx+x, my+y
+			pts = append(pts, r...)
+		}
+	}
+	if length == 0 {
+		return meanPoint(pts)
+	}
+	return Point{X: mx / length, Y: my / length}
+}
+
+func (p *Point) InteriorPoint() Point {
+	return p.Centroid()
+}
+
+// InteriorPoint returns the point closest to the centroid.
+func (m *MultiPoint) InteriorPoint() Point {
+	pts := make([][]Point, len(*m))
+	for i, p := range *m {
+		pts[i] = []Point{p}
+	}
+	return closestVertex(pts, m.Centroid())
+}
+
+// InteriorPoint returns the vertex of the line closest to its centroid,
+// preferring vertices other than the end points.
+func (l LineString) InteriorPoint() Point {
+	return closestVertex([][]Point{l}, l.Centroid())
+}
+
+func (m *MultiLineString) InteriorPoint() Point {
+	lines := make([][]Point, len(*m))
+	for i, l := range *m {
+		lines[i] = l
+	}
+	return closestVertex(lines, m.Centroid())
+}
+
+func closestVertex(lines [][]Point, c Point) Point {
+	best, bestDist, bestInterior := Point{}, math.Inf(1), false
+	for _, l := range lines {
+		for i, pt := range l {
+			interior := i > 0 && i < len(l)-1
+			d := math.Hypot(pt.X-c.X, pt.Y-c.Y)
+			if (interior && !bestInterior) || (interior == bestInterior && d < bestDist) {
+				best, bestDist, bestInterior = pt, d, interior
+			}
+		}
+	}
+	return best
+}
+
+// InteriorPoint returns a point guaranteed to lie inside the polygon: the
+// middle of the widest interior section of a horizontal line near the
+// middle of the polygon, chosen to miss all vertices.
+func (p *Polygon) InteriorPoint() Point {
+	m := MultiPolygon{*p}
+	return m.InteriorPoint()
+}
+
+func (m *MultiPolygon) InteriorPoint() Point {
+	best, bestWidth := Point{}, -1.0
+	for _, p := range *m {
+		if len(p) == 0 || len(p[0]) == 0 {
+			continue
+		}
+		b := p.Bounds()
+		cy := (b.MinY + b.MaxY) / 2
+		lo, hi := b.MinY, b.MaxY
+		for _, r := range p {
+			for _, pt := range r {
+				if pt.Y <= cy {
+					lo = math.Max(lo, pt.Y)
+				} else {
+					hi = math.Min(hi, pt.Y)
+				}
+			}
+		}
+		y := (lo + hi) / 2
+
+		xs := []float64{}
+		for _, r := range p {
+			for i := range r {
+				a, c := r[i], r[(i+1)%len(r)]
+				if (a.Y > y) != (c.Y > y) {
+					xs = append(xs, a.X+(y-a.Y)*(c.X-a.X)/(c.Y-a.Y))
+				}
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			if w := xs[i+1] - xs[i]; w > bestWidth {
+				best, bestWidth = Point{X: (xs[i] + xs[i+1]) / 2, Y: y}, w
+			}
+		}
+		if bestWidth < 0 {
+			best, bestWidth = p[0][0], 0
+		}
+	}
+	return best
+}
+
+// PoleOfInaccessibility returns the point inside the polygon furthest from
+// its boundary, to within precision, and its distance to the boundary. It
+// makes a good anchor for labels on concave polygons.
+func (p *Polygon) PoleOfInaccessibility(precision float64) (Point, float64) {
+	return poleOfInaccessibility([]Polygon{*p}, precision)
+}
+
+func (m *MultiPolygon) PoleOfInaccessibility(precision float64) (Point, float64) {
+	return poleOfInaccessibility(*m, precision)
+}
+
+type poleCell struct {
+	c   Point
+	h   float64
+	d   float64
+	max float64
+}
+
+type poleQueue []poleCell
+
+func (q poleQueue) Len() int            { return len(q) }
+func (q poleQueue) Less(i, j int) bool  { return q[i].max > q[j].max }
+func (q poleQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *poleQueue) Push(x interface{}) { *q = append(*q, x.(poleCell)) }
+func (q *poleQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// poleOfInaccessibility searches for the pole with the polylabel
+// algorithm, subdividing square cells in order of the best distance they
+// could contain and discarding those that cannot improve on the best
+// found by more than precision.
+func poleOfInaccessibility(polys []Polygon, precision float64) (Point, float64) {
+	b := EmptyBounds()
+	for _, p := range polys {
+		b = b.Extend(p.Bounds())
+	}
+	if b.IsEmpty() {
+		return Point{}, 0
+	}
+	size := math.Min(b.MaxX-b.MinX, b.MaxY-b.MinY)
+	if size == 0 {
+		return Point{X: b.MinX, Y: b.MinY}, 0
+	}
+	precision = math.Max(precision, size*1e-9)
+
+	signedDist := func(pt Point) float64 {
+		inside, d := false, math.Inf(1)
+		for _, p := range polys {
+			inside = inside || polygonContains(p, pt)
+			for _, r := range p {
+				for i := range r {
+					d = math.Min(d, segmentDistance(pt, r[i], r[(i+1)%len(r)]))
+				}
+			}
+		}
+		if !inside {
+			d = -d
+		}
+		return d
+	}
+	cell := func(c Point, h float64) poleCell {
+		d := signedDist(c)
+		return poleCell{c: c, h: h, d: d, max: d + h*math.Sqrt2}
+	}
+
+	q := &poleQueue{}
+	h := size / 2
+	for x := b.MinX; x < b.MaxX; x += size {
+		for y := b.MinY; y < b.MaxY; y += size {
+			heap.Push(q, cell(Point{X: x + h, Y: y + h}, h))
+		}
+	}
+
+	m := MultiPolygon(polys)
+	best := cell(m.Centroid(), 0)
+	if c := cell(b.Centre(), 0); c.d > best.d {
+		best = c
+	}
+	for q.Len() > 0 {
+		c := heap.Pop(q).(poleCell)
+		if c.d > best.d {
+			best = c
+		}
+		if c.max-best.d <= precision {
+			continue
+		}
+		h := c.h / 2
+		for _, off := range [4][2]float64{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}} {
+			heap.Push(q, cell(Point{X: c.c.X + off[0]*h, Y: c.c.Y + off[1]*h}, h))
+		}
+	}
+	return best.c, best.d
+}
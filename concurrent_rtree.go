@@ -0,0 +1,101 @@
+package geometry
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ConcurrentRTree is an R-tree that may be shared between goroutines.
+// Readers work lock-free on an immutable snapshot of the tree, while
+// writers are serialised, copy the nodes they change and publish the new
+// version atomically. A reader never observes a partial update.
+type ConcurrentRTree[T any] struct {
+	mu   sync.Mutex
+	tree atomic.Pointer[RTree[T]]
+}
+
+// NewConcurrentRTree returns an empty tree whose nodes hold at most
+// maxEntries entries, 9 if maxEntries is less than 4.
+func NewConcurrentRTree[T any](maxEntries int) *ConcurrentRTree[T] {
+	c := &ConcurrentRTree[T]{}
+	t := NewRTree[T](maxEntries)
+	t.persistent = true
+	c.tree.Store(t)
+	return c
+}
+
+// Snapshot returns the current version of the tree. It is never modified
+// by later writes and may be queried for as long as needed. Changes made
+// to the snapshot itself copy the nodes they touch and are not seen by c.
+func (c *ConcurrentRTree[T]) Snapshot() *RTree[T] {
+	t := *c.tree.Load()
+	return &t
+}
+
+// update applies f to a copy of the current tree and publishes the result.
+func (c *ConcurrentRTree[T]) update(f func(t *RTree[T])) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := *c.tree.Load()
+	f(&t)
+	c.tree.Store(&t)
+}
+
+func (c *ConcurrentRTree[T]) Insert(b Bounds, item T) {
+	c.update(func(t *RTree[T]) {
+		t.Insert(b, item)
+	})
+}
+
+// Delete removes the first item within bounds b for which match returns
+// true, reporting whether an item was found.
+func (c *ConcurrentRTree[T]) Delete(b Bounds, match func(T) bool) bool {
+	found := false
+	c.update(func(t *RTree[T]) {
+		found = t.Delete(b, match)
+	})
+	return found
+}
+
+// BulkLoad replaces the contents of the tree with the given items.
+func (c *ConcurrentRTree[T]) BulkLoad(bounds []Bounds, items []T) {
+	c.update(func(t *RTree[T]) {
+		t.BulkLoad(bounds, items)
+	})
+}
+
+func (c *ConcurrentRTree[T]) Clear() {
+	c.update(func(t *RTree[T]) {
+		t.Clear()
+	})
+}
+
+func (c *ConcurrentRTree[T]) Len() int {
+	return c.tree.Load().Len()
+}
+
+func (c *ConcurrentRTree[T]) Bounds() Bounds {
+	return c.tree.Load().Bounds()
+}
+
+// Search calls fn for every item whose bounds intersect b in the current
+// snapshot, stopping early if fn returns false.
+func (c *ConcurrentRTree[T]) Search(b Bounds, fn func(Bounds, T) bool) {
+	c.tree.Load().Search(b, fn)
+}
+
+func (c *ConcurrentRTree[T]) SearchItems(b Bounds) []T {
+	return c.tree.Load().SearchItems(b)
+}
+
+func (c *ConcurrentRTree[T]) Each(fn func(Bounds, T) bool) {
+	c.tree.Load().Each(fn)
+}
+
+func (c *ConcurrentRTree[T]) Nearest(p Point, k int) []T {
+	return c.tree.Load().Nearest(p, k)
+}
+
+func (c *ConcurrentRTree[T]) NearestFunc(p Point, k int, dist func(T) float64) []T {
+	return c.tree.Load().NearestFunc(p, k, dist)
+}
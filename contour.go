@@ -0,0 +1,327 @@
+package geometry
+
+import (
+	"errors"
+	"math"
+)
+
+// contourCell holds the four samples around a marching squares cell in
+// counter-clockwise order: top left, top right, bottom right, bottom left.
+type contourCell struct {
+	v  [4]float64
+	p  [4]Point
+	id [4]int
+}
+
+// cross returns where level crosses the cell side between corners i and
+// j, always interpolating from the lower sample id so neighbouring cells
+// agree exactly.
+func (c *contourCell) cross(i, j int, level float64) Point {
+	if c.id[i] > c.id[j] {
+		i, j = j, i
+	}
+	t := (level - c.v[i]) / (c.v[j] - c.v[i])
+	a, b := c.p[i], c.p[j]
+	return Point{X: a.X + t*(b.X-a.X), Y: a.Y + t*(b.Y-a.Y)}
+}
+
+func (c *contourCell) inside(k int, level float64, above bool) bool {
+	return (c.v[k] >= level) == above
+}
+
+// saddle reports whether the corners alternate about level, and if so
+// whether the cell centre joins the corners at or above it.
+func (c *contourCell) saddle(level float64) (bool, bool) {
+	a := c.v[0] >= level
+	if a != (c.v[2] >= level) || (c.v[1] >= level) != (c.v[3] >= level) || a == (c.v[1] >= level) {
+		return false, false
+	}
+	return true, (c.v[0]+c.v[1]+c.v[2]+c.v[3])/4 >= level
+}
+
+// region returns the convex pieces of the cell at or above level, or below
+// it when above is false.
+func (c *contourCell) region(level float64, above bool) [][]Point {
+	ring := []Point{}
+	n := 0
+	for k := 0; k < 4; k++ {
+		if c.inside(k, level, above) {
+			ring = append(ring, c.p[k])
+			n++
+		}
+		if c.inside(k, level, above) != c.inside((k+1)%4, level, above) {
+			ring = append(ring, c.cross(k, (k+1)%4, level))
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+
+	if saddle, joinAbove := c.saddle(level); saddle && joinAbove != above {
+		out := [][]Point{}
+		for k := 0; k < 4; k++ {
+			if c.inside(k, level, above) {
+				out = append(out, []Point{c.cross((k+3)%4, k, level), c.p[k], c.cross(k, (k+1)%4, level)})
+			}
+		}
+		return out
+	}
+
+	return [][]Point{ring}
+}
+
+// segments returns the isoline segments of level crossing the cell, each
+// with the sides of the cell it joins.
+func (c *contourCell) segments(level float64) [][2]int {
+	crossing := []int{}
+	for k := 0; k < 4; k++ {
+		if c.inside(k, level, true) != c.inside((k+1)%4, level, true) {
+			crossing = append(crossing, k)
+		}
+	}
+	if len(crossing) == 2 {
+		return [][2]int{{crossing[0], crossing[1]}}
+	}
+	if len(crossing) != 4 {
+		return nil
+	}
+
+	// Saddle: cut off the corners not joined through the centre.
+	_, joinAbove := c.saddle(level)
+	out := [][2]int{}
+	for k := 0; k < 4; k++ {
+		if c.inside(k, level, true) != joinAbove {
+			out = append(out, [2]int{(k + 3) % 4, k})
+		}
+	}
+	return out
+}
+
+func contourCells(data [][]float64, nodata *float64) ([]contourCell, error) {
+	height := len(data)
+	if height == 0 {
+		return nil, nil
+	}
+	width := len(data[0])
+	for _, row := range data {
+		if len(row) != width {
+			return nil, errors.New("Contour: rows of different length")
+		}
+	}
+
+	cells := []contourCell{}
+	for r := 0; r+1 < height; r++ {
+		for col := 0; col+1 < width; col++ {
+			c := contourCell{}
+			skip := false
+			for k, off := range [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+				x, y := col+off[0], r+off[1]
+				v := data[y][x]
+				if math.IsNaN(v) || (nodata != nil && v == *nodata) {
+					skip = true
+					break
+				}
+				c.v[k], c.p[k], c.id[k] = v, Point{X: float64(x), Y: float64(y)}, y*width+x
+			}
+			if !skip {
+				cells = append(cells, c)
+			}
+		}
+	}
+	return cells, nil
+}
+
+// contourToWorld maps sample positions, with sample (col, row) at the
+// centre of its pixel, through the geotransform.
+func contourToWorld(gt [6]float64) Affine {
+	return AffineFromGeoTransform(gt).Multiply(AffineTranslate(0.5, 0.5, 0))
+}
+
+// ContourLines traces isolines of a gridded field, indexed by row then
+// column, at each of the given levels using marching squares. NaN samples
+// are skipped, as are those equal to nodata when it is not nil. Each level
+// becomes a MultiLineString feature with a "level" property.
+func ContourLines(data [][]float64, gt [6]float64, levels []float64, nodata *float64) (FeatureCollection, error) {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	cells, err := contourCells(data, nodata)
+	if err != nil {
+		return fc, err
+	}
+	toWorld := contourToWorld(gt)
+
+	for _, level := range levels {
+		type side [2]int
+		pts := map[side]Point{}
+		segs := [][2]side{}
+		for i := range cells {
+			c := &cells[i]
+			for _, s := range c.segments(level) {
+				var seg [2]side
+				for j, k := range s {
+					a, b := c.id[k], c.id[(k+1)%4]
+					if a > b {
+						a, b = b, a
+					}
+					seg[j] = side{a, b}
+					pts[seg[j]] = c.cross(k, (k+1)%4, level)
+				}
+				segs = append(segs, seg)
+			}
+		}
+
+		ends := map[side][]int{}
+		for i, s := range segs {
+			ends[s[0]] = append(ends[s[0]], i)
+			ends[s[1]] = append(ends[s[1]], i)
+		}
+		used := make([]bool, len(segs))
+		follow := func(start side) LineString {
+			line := LineString{pts[start]}
+			cur := start
+			for {
+				next := -1
+				for _, i := range ends[cur] {
+					if !used[i] {
+						next = i
+						break
+					}
+				}
+				if next < 0 {
+					return line
+				}
+				used[next] = true
+				if segs[next][0] == cur {
+					cur = segs[next][1]
+				} else {
+					cur = segs[next][0]
+				}
+				line = append(line, pts[cur])
+			}
+		}
+
+		mls := MultiLineString{}
+		// Open lines start at the grid edge or next to nodata, the
+		// remaining segments form closed rings.
+		for _, s := range segs {
+			for _, e := range s {
+				if len(ends[e]) == 1 && !used[ends[e][0]] {
+					mls = append(mls, follow(e))
+				}
+			}
+		}
+		for i, s := range segs {
+			if !used[i] {
+				mls = append(mls, follow(s[0]))
+			}
+		}
+
+		g, _ := toWorld.Apply(&mls)
+		fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: g, Properties: map[string]interface{}{"level": level}})
+	}
+
+	return fc, nil
+}
+
+// ContourBands builds filled isobands between each consecutive pair of
+// ascending levels, covering samples at or above the lower level and below
+// the upper. Samples are skipped as in ContourLines. Each band becomes a
+// MultiPolygon feature with "min" and "max" properties.
+func ContourBands(data [][]float64, gt [6]float64, levels []float64, nodata *float64) (FeatureCollection, error) {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for i := 1; i < len(levels); i++ {
+		if levels[i] <= levels[i-1] {
+			return fc, errors.New("Contour: band levels must be ascending")
+		}
+	}
+	cells, err := contourCells(data, nodata)
+	if err != nil {
+		return fc, err
+	}
+	toWorld := contourToWorld(gt)
+
+	for i := 0; i+1 < len(levels); i++ {
+		lo, hi := levels[i], levels[i+1]
+		g := &planarGraph{tol: 1e-9, grid: map[[2]int64][]int{}}
+		count := map[edge]int{}
+		order := []edge{}
+		for j := range cells {
+			c := &cells[j]
+			for _, a := range c.region(lo, true) {
+				for _, b := range c.region(hi, false) {
+					piece := clipConvex(a, b)
+					if len(piece) < 3 {
+						continue
+					}
+					ids := []int{}
+					for _, pt := range piece {
+						n := g.addNode(pt, false)
+						if len(ids) == 0 || ids[len(ids)-1] != n {
+							ids = append(ids, n)
+						}
+					}
+					for len(ids) > 1 && ids[0] == ids[len(ids)-1] {
+						ids = ids[:len(ids)-1]
+					}
+					if len(ids) < 3 {
+						continue
+					}
+					// Edges shared by neighbouring pieces cancel, leaving
+					// the outline of the band.
+					for k := range ids {
+						e := edge{ids[k], ids[(k+1)%len(ids)]}
+						if count[edge{e.b, e.a}] > 0 {
+							count[edge{e.b, e.a}]--
+							continue
+						}
+						if count[e] == 0 {
+							order = append(order, e)
+						}
+						count[e]++
+					}
+				}
+			}
+		}
+
+		bound := []edge{}
+		for _, e := range order {
+			for n := count[e]; n > 0; n-- {
+				bound = append(bound, e)
+			}
+		}
+		mp := g.polygons(bound, false)
+		w, _ := toWorld.Apply(&mp)
+		mp = w.(*MultiPolygon).ForceRHR()
+		fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: &mp, Properties: map[string]interface{}{"min": lo, "max": hi}})
+	}
+
+	return fc, nil
+}
+
+// clipConvex clips the convex counter-clockwise polygon a to the convex
+// counter-clockwise polygon b.
+func clipConvex(a, b []Point) []Point {
+	out := a
+	for i := range b {
+		if len(out) == 0 {
+			break
+		}
+		e1, e2 := b[i], b[(i+1)%len(b)]
+		side := func(p Point) float64 {
+			return (e2.X-e1.X)*(p.Y-e1.Y) - (e2.Y-e1.Y)*(p.X-e1.X)
+		}
+		in := out
+		out = []Point{}
+		for j := range in {
+			p, q := in[j], in[(j+1)%len(in)]
+			sp, sq := side(p), side(q)
+			if sp >= 0 {
+				out = append(out, p)
+			}
+			if (sp >= 0) != (sq >= 0) {
+				t := sp / (sp - sq)
+				out = append(out, Point{X: p.X + t*(q.X-p.X), Y: p.Y + t*(q.Y-p.Y)})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,177 @@
+package geometry
+
+import (
+	"fmt"
+	"math"
+)
+
+// Helmert holds the seven parameters of a position vector transformation
+// to WGS84: translations in metres, rotations in arc seconds and scale in
+// parts per million.
+type Helmert struct {
+	TX, TY, TZ float64
+	RX, RY, RZ float64
+	S          float64
+}
+
+func (h Helmert) isZero() bool {
+	return h == Helmert{}
+}
+
+func (h Helmert) apply(x, y, z float64, inverse bool) (float64, float64, float64) {
+	sec := deg / 3600
+	rx, ry, rz, s := h.RX*sec, h.RY*sec, h.RZ*sec, 1+h.S*1e-6
+	if inverse {
+		x, y, z = x-h.TX, y-h.TY, z-h.TZ
+		x, y, z = x/s, y/s, z/s
+		return x + rz*y - ry*z, -rz*x + y + rx*z, ry*x - rx*y + z
+	}
+	return h.TX + s*(x-rz*y+ry*z), h.TY + s*(rz*x+y-rx*z), h.TZ + s*(-ry*x+rx*y+z)
+}
+
+type Datum struct {
+	Name      string
+	Ellipsoid Ellipsoid
+	ToWGS84   Helmert
+}
+
+var (
+	WGS84Datum = Datum{Name: "WGS84", Ellipsoid: WGS84Ellipsoid}
+	GDA94Datum = Datum{Name: "GDA94", Ellipsoid: GRS80Ellipsoid}
+	// GDA2020 is related to WGS84 through GDA94 using the inverse of the
+	// GDA94 to GDA2020 transformation (EPSG:8048).
+	GDA2020Datum = Datum{Name: "GDA2020", Ellipsoid: GRS80Ellipsoid, ToWGS84: Helmert{
+		TX: -0.06155, TY: 0.01087, TZ: 0.04019,
+		RX: -0.0394924, RY: -0.0327221, RZ: -0.0328979,
+		S: 0.009994,
+	}}
+)
+
+// CRS is a coordinate reference system. Geographic systems have a nil
+// Projection and hold longitude and latitude in degrees as X and Y.
+type CRS struct {
+	Code       int
+	Name       string
+	Datum      Datum
+	Projection Projection
+}
+
+func (c *CRS) IsGeographic() bool {
+	return c.Projection == nil
+}
+
+// EPSG returns the coordinate reference system registered under code.
+func EPSG(code int) (*CRS, error) {
+	utm := func(zone int, south bool, datum Datum, name string) *CRS {
+		y0 := 0.0
+		if south {
+			y0 = 10000000
+		}
+		tm := NewTransverseMercator(datum.Ellipsoid, 0, float64(zone*6-183), 0.9996, 500000, y0)
+		return &CRS{Code: code, Name: fmt.Sprintf(name, zone), Datum: datum, Projection: tm}
+	}
+
+	switch {
+	case code == 4326:
+		return &CRS{Code: code, Name: "WGS 84", Datum: WGS84Datum}, nil
+	case code == 4283:
+		return &CRS{Code: code, Name: "GDA94", Datum: GDA94Datum}, nil
+	case code == 7844:
+		return &CRS{Code: code, Name: "GDA2020", Datum: GDA2020Datum}, nil
+	case code == 3857:
+		return &CRS{Code: code, Name: "WGS 84 / Pseudo-Mercator", Datum: WGS84Datum, Projection: NewWebMercator()}, nil
+	case code == 3395:
+		return &CRS{Code: code, Name: "WGS 84 / World Mercator", Datum: WGS84Datum, Projection: NewMercator(WGS84Ellipsoid, 0, 1, 0, 0)}, nil
+	case code == 3577:
+		return &CRS{Code: code, Name: "GDA94 / Australian Albers", Datum: GDA94Datum,
+			Projection: NewAlbersEqualArea(GRS80Ellipsoid, -18, -36, 0, 132, 0, 0)}, nil
+	case code == 9473:
+		return &CRS{Code: code, Name: "GDA2020 / Australian Albers", Datum: GDA2020Datum,
+			Projection: NewAlbersEqualArea(GRS80Ellipsoid, -18, -36, 0, 132, 0, 0)}, nil
+	case code > 32600 && code <= 32660:
+		return utm(code-32600, false, WGS84Datum, "WGS 84 / UTM zone %dN"), nil
+	case code > 32700 && code <= 32760:
+		return utm(code-32700, true, WGS84Datum, "WGS 84 / UTM zone %dS"), nil
+	case code >= 28348 && code <= 28358:
+		return utm(code-28300, true, GDA94Datum, "GDA94 / MGA zone %d"), nil
+	case code >= 7846 && code <= 7859:
+		return utm(code-7800, true, GDA2020Datum, "GDA2020 / MGA zone %d"), nil
+	}
+
+	return nil, fmt.Errorf("EPSG code %d not supported", code)
+}
+
+// geographic converts a coordinate in the CRS to longitude, latitude and
+// height on its datum.
+func (c *CRS) geographic(pt Point) (Point, error) {
+	if c.IsGeographic() {
+		return pt, nil
+	}
+	lon, lat, err := c.Projection.Inverse(pt.X, pt.Y)
+	return Point{X: lon, Y: lat, Z: pt.Z}, err
+}
+
+func (c *CRS) project(pt Point) (Point, error) {
+	if c.IsGeographic() {
+		return pt, nil
+	}
+	x, y, err := c.Projection.Forward(pt.X, pt.Y)
+	return Point{X: x, Y: y, Z: pt.Z}, err
+}
+
+func geodeticToGeocentric(pt Point, ell Ellipsoid) (float64, float64, float64) {
+	e2 := ell.E2()
+	lam, phi := pt.X*deg, pt.Y*deg
+	n := ell.A / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+	return (n + pt.Z) * math.Cos(phi) * math.Cos(lam),
+		(n + pt.Z) * math.Cos(phi) * math.Sin(lam),
+		(n*(1-e2) + pt.Z) * math.Sin(phi)
+}
+
+func geocentricToGeodetic(x, y, z float64, ell Ellipsoid) Point {
+	e2 := ell.E2()
+	p := math.Hypot(x, y)
+	lam := math.Atan2(y, x)
+	phi := math.Atan2(z, p*(1-e2))
+	h := 0.0
+	for i := 0; i < 10; i++ {
+		n := ell.A / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+		h = p/math.Cos(phi) - n
+		next := math.Atan2(z, p*(1-e2*n/(n+h)))
+		if math.Abs(next-phi) < 1e-15 {
+			phi = next
+			break
+		}
+		phi = next
+	}
+	return Point{X: lam / deg, Y: phi / deg, Z: h}
+}
+
+// shiftDatum moves a geographic coordinate from one datum to another
+// through geocentric WGS84.
+func shiftDatum(pt Point, from, to Datum) Point {
+	if from == to || (from.ToWGS84.isZero() && to.ToWGS84.isZero() && from.Ellipsoid == to.Ellipsoid) {
+		return pt
+	}
+	x, y, z := geodeticToGeocentric(pt, from.Ellipsoid)
+	x, y, z = from.ToWGS84.apply(x, y, z, false)
+	x, y, z = to.ToWGS84.apply(x, y, z, true)
+	return geocentricToGeodetic(x, y, z, to.Ellipsoid)
+}
+
+// TransformPoint converts a single coordinate between reference systems.
+func TransformPoint(pt Point, from, to *CRS) (Point, error) {
+	geo, err := from.geographic(pt)
+	if err != nil {
+		return Point{}, err
+	}
+	return to.project(shiftDatum(geo, from.Datum, to.Datum))
+}
+
+// Transform returns a copy of the geometry with every coordinate converted
+// from one reference system to another.
+func Transform(g Geometry, from, to *CRS) (Geometry, error) {
+	return mapGeometry(g, func(pt Point) (Point, error) {
+		return TransformPoint(pt, from, to)
+	})
+}
@@ -0,0 +1,327 @@
+package geometry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DBFField describes a column of a dBASE table. Type is one of C
+// (character), N or F (numeric), L (logical) and D (date).
+type DBFField struct {
+	Name     string
+	Type     byte
+	Length   int
+	Decimals int
+}
+
+type dbfHeader struct {
+	Version    uint8
+	Year       uint8
+	Month      uint8
+	Day        uint8
+	NumRecords uint32
+	HeaderSize uint16
+	RecordSize uint16
+	Reserved   [20]byte
+}
+
+type dbfFieldDescriptor struct {
+	Name     [11]byte
+	Type     byte
+	Reserved [4]byte
+	Length   uint8
+	Decimals uint8
+	Padding  [14]byte
+}
+
+// cp1252 holds the characters of Windows code page 1252 from 0x80 to 0x9f,
+// where it differs from ISO 8859-1.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+// dbfCharset returns a function converting DBF text in the code page named
+// by a .cpg file to a string. An empty name is taken as UTF-8.
+func dbfCharset(cpg string) (func([]byte) string, error) {
+	name := strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.TrimSpace(cpg)))
+	single := func(high func(byte) rune) func([]byte) string {
+		return func(b []byte) string {
+			var sb strings.Builder
+			for _, c := range b {
+				if c < 0x80 {
+					sb.WriteByte(c)
+				} else {
+					sb.WriteRune(high(c))
+				}
+			}
+			return sb.String()
+		}
+	}
+	switch name {
+	case "", "UTF8", "65001", "ASCII", "USASCII":
+		return func(b []byte) string { return string(b) }, nil
+	case "ISO88591", "88591", "28591", "LATIN1":
+		return single(func(c byte) rune { return rune(c) }), nil
+	case "1252", "CP1252", "WINDOWS1252", "ANSI1252":
+		return single(func(c byte) rune {
+			if c < 0xa0 {
+				return cp1252[c-0x80]
+			}
+			return rune(c)
+		}), nil
+	}
+	return nil, fmt.Errorf("DBF code page %s not supported", cpg)
+}
+
+// decodeDBF reads the fields and records of a dBASE III table, decoding
+// text with the given charset. Numbers are read as float64, logicals as
+// bool, dates as time.Time and blank values as nil. Records flagged as
+// deleted are returned as nil.
+func decodeDBF(in []byte, text func([]byte) string) ([]DBFField, []map[string]interface{}, error) {
+	buf := bytes.NewReader(in)
+	var h dbfHeader
+	if err := binary.Read(buf, binary.LittleEndian, &h); err != nil {
+		return nil, nil, fmt.Errorf("Problem reading DBF header: %s", err)
+	}
+
+	fields := []DBFField{}
+	for {
+		var d dbfFieldDescriptor
+		if b, err := buf.ReadByte(); err != nil {
+			return nil, nil, fmt.Errorf("Problem reading DBF fields: %s", err)
+		} else if b == 0x0d {
+			break
+		}
+		buf.UnreadByte()
+		if err := binary.Read(buf, binary.LittleEndian, &d); err != nil {
+			return nil, nil, fmt.Errorf("Problem reading DBF fields: %s", err)
+		}
+		name := text(bytes.TrimRight(d.Name[:], "\x00 "))
+		if i := strings.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		fields = append(fields, DBFField{Name: name, Type: d.Type, Length: int(d.Length), Decimals: int(d.Decimals)})
+	}
+
+	size := 1
+	for _, f := range fields {
+		size += f.Length
+	}
+	if size > int(h.RecordSize) {
+		return nil, nil, errors.New("DBF fields longer than the record size")
+	}
+
+	records := make([]map[string]interface{}, 0, h.NumRecords)
+	for i := 0; i < int(h.NumRecords); i++ {
+		start := int(h.HeaderSize) + i*int(h.RecordSize)
+		if start+int(h.RecordSize) > len(in) {
+			return nil, nil, errors.New("DBF file truncated")
+		}
+		if in[start] == '*' {
+			records = append(records, nil)
+			continue
+		}
+		pos := start + 1
+		rec := map[string]interface{}{}
+		for _, f := range fields {
+			raw := strings.TrimSpace(text(in[pos : pos+f.Length]))
+			pos += f.Length
+			val, err := parseDBFValue(f, raw)
+			if err != nil {
+				return nil, nil, fmt.Errorf("DBF record %d field %s: %s", i, f.Name, err)
+			}
+			rec[f.Name] = val
+		}
+		records = append(records, rec)
+	}
+	return fields, records, nil
+}
+
+func parseDBFValue(f DBFField, raw string) (interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	switch f.Type {
+	case 'N', 'F':
+		if strings.Trim(raw, "*") == "" {
+			return nil, nil
+		}
+		return strconv.ParseFloat(raw, 64)
+	case 'L':
+		switch raw {
+		case "T", "t", "Y", "y":
+			return true, nil
+		case "F", "f", "N", "n":
+			return false, nil
+		}
+		return nil, nil
+	case 'D':
+		return time.Parse("20060102", raw)
+	}
+	return raw, nil
+}
+
+// dbfFields chooses columns able to hold the properties of every feature,
+// ordered by name.
+func dbfFields(props []map[string]interface{}) ([]DBFField, error) {
+	kinds := map[string]byte{}
+	for _, p := range props {
+		for k, v := range p {
+			var t byte
+			switch v.(type) {
+			case nil:
+				continue
+			case bool:
+				t = 'L'
+			case time.Time:
+				t = 'D'
+			case float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+				t = 'N'
+			default:
+				t = 'C'
+			}
+			if old, ok := kinds[k]; ok && old != t {
+				t = 'C'
+			}
+			kinds[k] = t
+		}
+	}
+
+	names := []string{}
+	for k := range kinds {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	fields := []DBFField{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		short := name
+		if len(short) > 10 {
+			short = short[:10]
+		}
+		if seen[short] {
+			return nil, fmt.Errorf("DBF field name %s clashes with another after truncation", name)
+		}
+		seen[short] = true
+
+		f := DBFField{Name: name, Type: kinds[name], Length: 1}
+		switch f.Type {
+		case 'L':
+		case 'D':
+			f.Length = 8
+		default:
+			width := 1
+			for _, p := range props {
+				v, ok := p[name]
+				if !ok || v == nil {
+					continue
+				}
+				s := formatDBFValue(f, v)
+				if i := strings.IndexByte(s, '.'); i >= 0 && f.Type == 'N' {
+					f.Decimals = min(max(f.Decimals, len(s)-i-1), 15)
+					s = s[:i]
+				}
+				width = max(width, len(s))
+			}
+			f.Length = width
+			if f.Decimals > 0 {
+				f.Length += f.Decimals + 1
+			}
+			f.Length = min(f.Length, 254)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func formatDBFValue(f DBFField, v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		if t {
+			return "T"
+		}
+		return "F"
+	case time.Time:
+		return t.Format("20060102")
+	}
+	if f.Type == 'N' || f.Type == 'F' {
+		var x float64
+		switch t := v.(type) {
+		case float32:
+			x = float64(t)
+		case float64:
+			x = t
+		default:
+			x, _ = strconv.ParseFloat(fmt.Sprint(t), 64)
+		}
+		if f.Decimals > 0 {
+			return strconv.FormatFloat(x, 'f', f.Decimals, 64)
+		}
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// encodeDBF writes a dBASE III table holding the properties of each
+// feature in the given fields.
+func encodeDBF(fields []DBFField, props []map[string]interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	recordSize := 1
+	for _, f := range fields {
+		recordSize += f.Length
+	}
+	now := time.Now()
+	h := dbfHeader{
+		Version: 3, Year: uint8(now.Year() - 1900), Month: uint8(now.Month()), Day: uint8(now.Day()),
+		NumRecords: uint32(len(props)), HeaderSize: uint16(32 + 32*len(fields) + 1), RecordSize: uint16(recordSize),
+	}
+	binary.Write(buf, binary.LittleEndian, &h)
+	for _, f := range fields {
+		d := dbfFieldDescriptor{Type: f.Type, Length: uint8(f.Length), Decimals: uint8(f.Decimals)}
+		name := f.Name
+		if len(name) > 10 {
+			name = name[:10]
+		}
+		copy(d.Name[:], name)
+		binary.Write(buf, binary.LittleEndian, &d)
+	}
+	buf.WriteByte(0x0d)
+
+	for _, p := range props {
+		buf.WriteByte(' ')
+		for _, f := range fields {
+			s := formatDBFValue(f, p[f.Name])
+			if len(s) > f.Length {
+				if f.Type == 'N' || f.Type == 'F' {
+					return nil, fmt.Errorf("DBF value %s does not fit field %s", s, f.Name)
+				}
+				// Cut at a character boundary so the text stays valid
+				// UTF-8.
+				n := f.Length
+				for n > 0 && !utf8.RuneStart(s[n]) {
+					n--
+				}
+				s = s[:n]
+			}
+			if f.Type == 'N' || f.Type == 'F' {
+				s = strings.Repeat(" ", f.Length-len(s)) + s
+			} else {
+				s += strings.Repeat(" ", f.Length-len(s))
+			}
+			buf.WriteString(s)
+		}
+	}
+	buf.WriteByte(0x1a)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,331 @@
+package geometry
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// EarthSphere is the sphere with the mean radius of the WGS84 ellipsoid,
+// giving haversine distances when passed to GeodesicDistance.
+var EarthSphere = Ellipsoid{A: 6371008.8}
+
+// distanceParts breaks a geometry into segments, points becoming zero
+// length segments, plus the polygons whose interiors belong to it.
+type distanceParts struct {
+	segs  [][2]Point
+	polys []Polygon
+}
+
+func splitDistanceParts(g Geometry) (distanceParts, error) {
+	d := distanceParts{}
+	line := func(pts []Point, closed bool) {
+		for i := 0; i+1 < len(pts); i++ {
+			d.segs = append(d.segs, [2]Point{pts[i], pts[i+1]})
+		}
+		if len(pts) == 1 {
+			d.segs = append(d.segs, [2]Point{pts[0], pts[0]})
+		} else if closed && len(pts) > 2 {
+			d.segs = append(d.segs, [2]Point{pts[len(pts)-1], pts[0]})
+		}
+	}
+	poly := func(p Polygon) {
+		for _, r := range p {
+			line(r, true)
+		}
+		d.polys = append(d.polys, p)
+	}
+
+	switch t := g.(type) {
+	case *Point:
+		line([]Point{*t}, false)
+	case *MultiPoint:
+		for _, p := range *t {
+			line([]Point{p}, false)
+		}
+	case *LineString:
+		line(*t, false)
+	case *MultiLineString:
+		for _, l := range *t {
+			line(l, false)
+		}
+	case *Polygon:
+		poly(*t)
+	case *MultiPolygon:
+		for _, p := range *t {
+			poly(p)
+		}
+	default:
+		return d, fmt.Errorf("Distance: Geometry %T not supported", g)
+	}
+	if len(d.segs) == 0 {
+		return d, errors.New("Distance: empty geometry")
+	}
+	return d, nil
+}
+
+// polygonContains reports whether pt is inside the shell of p and outside
+// all of its holes.
+func polygonContains(p Polygon, pt Point) bool {
+	if len(p) == 0 || !pointInRing(pt, p[0]) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if pointInRing(pt, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// insideParts returns a vertex of a lying inside one of the polygons of b.
+func insideParts(a, b distanceParts) (Point, bool) {
+	for _, p := range b.polys {
+		for _, s := range a.segs {
+			if polygonContains(p, s[0]) {
+				return s[0], true
+			}
+		}
+	}
+	return Point{}, false
+}
+
+// nearestParts returns the smallest distance between a and b as measured
+// by segDist, with the points on each that realise it.
+func nearestParts(a, b distanceParts, segDist func(a0, a1, b0, b1 Point) (float64, Point, Point)) (float64, Point, Point) {
+	// A geometry inside one of the other's polygons is at distance zero.
+	if pt, ok := insideParts(a, b); ok {
+		return 0, pt, pt
+	}
+	if pt, ok := insideParts(b, a); ok {
+		return 0, pt, pt
+	}
+
+	best, pa, pb := math.Inf(1), Point{}, Point{}
+	for _, s := range a.segs {
+		for _, t := range b.segs {
+			if d, p, q := segDist(s[0], s[1], t[0], t[1]); d < best {
+				best, pa, pb = d, p, q
+				if d == 0 {
+					return best, pa, pb
+				}
+			}
+		}
+	}
+	return best, pa, pb
+}
+
+func closestOnSegment(pt, a, b Point) Point {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	l2 := dx*dx + dy*dy
+	if l2 == 0 {
+		return a
+	}
+	t := math.Max(0, math.Min(1, ((pt.X-a.X)*dx+(pt.Y-a.Y)*dy)/l2))
+	return Point{X: a.X + t*dx, Y: a.Y + t*dy}
+}
+
+func planarSegmentNearest(a0, a1, b0, b1 Point) (float64, Point, Point) {
+	rx, ry := a1.X-a0.X, a1.Y-a0.Y
+	sx, sy := b1.X-b0.X, b1.Y-b0.Y
+	if d := rx*sy - ry*sx; d != 0 {
+		qx, qy := b0.X-a0.X, b0.Y-a0.Y
+		u, v := (qx*sy-qy*sx)/d, (qx*ry-qy*rx)/d
+		if u >= 0 && u <= 1 && v >= 0 && v <= 1 {
+			pt := Point{X: a0.X + u*rx, Y: a0.Y + u*ry}
+			return 0, pt, pt
+		}
+	}
+
+	best, pa, pb := math.Inf(1), Point{}, Point{}
+	try := func(p, q Point) {
+		if d := math.Hypot(p.X-q.X, p.Y-q.Y); d < best {
+			best, pa, pb = d, p, q
+		}
+	}
+	try(a0, closestOnSegment(a0, b0, b1))
+	try(a1, closestOnSegment(a1, b0, b1))
+	try(closestOnSegment(b0, a0, a1), b0)
+	try(closestOnSegment(b1, a0, a1), b1)
+	return best, pa, pb
+}
+
+// Distance returns the minimum planar distance between two geometries,
+// zero if they intersect or one lies inside the other.
+func Distance(a, b Geometry) (float64, error) {
+	d, _, _, err := nearestPoints(a, b, planarSegmentNearest)
+	return d, err
+}
+
+// NearestPoints returns the closest pair of points on a and b in the plane.
+func NearestPoints(a, b Geometry) (Point, Point, error) {
+	_, p, q, err := nearestPoints(a, b, planarSegmentNearest)
+	return p, q, err
+}
+
+func nearestPoints(a, b Geometry, segDist func(a0, a1, b0, b1 Point) (float64, Point, Point)) (float64, Point, Point, error) {
+	pa, err := splitDistanceParts(a)
+	if err != nil {
+		return 0, Point{}, Point{}, err
+	}
+	pb, err := splitDistanceParts(b)
+	if err != nil {
+		return 0, Point{}, Point{}, err
+	}
+	d, p, q := nearestParts(pa, pb, segDist)
+	return d, p, q, nil
+}
+
+// GeodesicNearestPoints returns the closest pair of points on geometries
+// given in longitude and latitude degrees, joining vertices with great
+// circle arcs. Polygon containment is tested in longitude and latitude.
+func GeodesicNearestPoints(a, b Geometry) (Point, Point, error) {
+	_, p, q, err := nearestPoints(a, b, sphereSegmentNearest)
+	return p, q, err
+}
+
+// GeodesicDistance returns the minimum distance in metres between
+// geometries given in longitude and latitude degrees. The nearest points
+// are found on the sphere and their distance is measured on ell, so
+// EarthSphere gives haversine distances and WGS84Ellipsoid ellipsoidal
+// ones.
+func GeodesicDistance(a, b Geometry, ell Ellipsoid) (float64, error) {
+	d, p, q, err := nearestPoints(a, b, sphereSegmentNearest)
+	if err != nil || d == 0 {
+		return 0, err
+	}
+	return ell.Distance(p, q), nil
+}
+
+type vec3 [3]float64
+
+func lonLatVec(p Point) vec3 {
+	lon, lat := p.X*deg, p.Y*deg
+	return vec3{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+}
+
+func (v vec3) point() Point {
+	return Point{X: math.Atan2(v[1], v[0]) / deg, Y: math.Atan2(v[2], math.Hypot(v[0], v[1])) / deg}
+}
+
+func (v vec3) dot(w vec3) float64 {
+	return v[0]*w[0] + v[1]*w[1] + v[2]*w[2]
+}
+
+func (v vec3) cross(w vec3) vec3 {
+	return vec3{v[1]*w[2] - v[2]*w[1], v[2]*w[0] - v[0]*w[2], v[0]*w[1] - v[1]*w[0]}
+}
+
+func (v vec3) scale(s float64) vec3 {
+	return vec3{v[0] * s, v[1] * s, v[2] * s}
+}
+
+func (v vec3) norm() float64 {
+	return math.Sqrt(v.dot(v))
+}
+
+// angle returns the angle in radians between two unit vectors.
+func (v vec3) angle(w vec3) float64 {
+	return math.Atan2(v.cross(w).norm(), v.dot(w))
+}
+
+// onArc reports whether the unit vector c on the great circle with normal
+// n lies between a and b.
+func onArc(c, a, b, n vec3) bool {
+	return a.cross(c).dot(n) >= 0 && c.cross(b).dot(n) >= 0
+}
+
+func closestOnArc(p, a, b vec3) vec3 {
+	n := a.cross(b)
+	if nn := n.dot(n); nn > 0 {
+		c := vec3{p[0] - n[0]*p.dot(n)/nn, p[1] - n[1]*p.dot(n)/nn, p[2] - n[2]*p.dot(n)/nn}
+		if l := c.norm(); l > 0 {
+			c = c.scale(1 / l)
+			if onArc(c, a, b, n) {
+				return c
+			}
+		}
+	}
+	if p.angle(a) <= p.angle(b) {
+		return a
+	}
+	return b
+}
+
+// sphereSegmentNearest returns the angular distance between two great
+// circle arcs with the points realising it.
+func sphereSegmentNearest(a0, a1, b0, b1 Point) (float64, Point, Point) {
+	u0, u1, v0, v1 := lonLatVec(a0), lonLatVec(a1), lonLatVec(b0), lonLatVec(b1)
+	n1, n2 := u0.cross(u1), v0.cross(v1)
+	if i := n1.cross(n2); i.norm() > 0 {
+		i = i.scale(1 / i.norm())
+		for _, c := range []vec3{i, i.scale(-1)} {
+			if onArc(c, u0, u1, n1) && onArc(c, v0, v1, n2) {
+				pt := c.point()
+				return 0, pt, pt
+			}
+		}
+	}
+
+	best, pa, pb := math.Inf(1), vec3{}, vec3{}
+	for _, c := range [][2]vec3{
+		{u0, closestOnArc(u0, v0, v1)}, {u1, closestOnArc(u1, v0, v1)},
+		{closestOnArc(v0, u0, u1), v0}, {closestOnArc(v1, u0, u1), v1},
+	} {
+		if d := c[0].angle(c[1]); d < best {
+			best, pa, pb = d, c[0], c[1]
+		}
+	}
+	return best, pa.point(), pb.point()
+}
+
+// Distance returns the length in metres of the geodesic between two
+// points given in longitude and latitude degrees, using Vincenty's inverse
+// formula, or the haversine formula when the ellipsoid is a sphere.
+func (e Ellipsoid) Distance(p, q Point) float64 {
+	if e.F == 0 {
+		dLat, dLon := (q.Y-p.Y)*deg, (q.X-p.X)*deg
+		h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(p.Y*deg)*math.Cos(q.Y*deg)*math.Pow(math.Sin(dLon/2), 2)
+		return 2 * e.A * math.Asin(math.Min(1, math.Sqrt(h)))
+	}
+
+	f, b := e.F, e.B()
+	l := (q.X - p.X) * deg
+	u1, u2 := math.Atan((1-f)*math.Tan(p.Y*deg)), math.Atan((1-f)*math.Tan(q.Y*deg))
+	sinU1, cosU1 := math.Sincos(u1)
+	sinU2, cosU2 := math.Sincos(u2)
+
+	lambda := l
+	for iter := 0; iter < 200 && math.Abs(lambda) <= math.Pi; iter++ {
+		sinL, cosL := math.Sincos(lambda)
+		sinSigma := math.Hypot(cosU2*sinL, cosU1*sinU2-sinU1*cosU2*cosL)
+		if sinSigma == 0 {
+			return 0
+		}
+		cosSigma := sinU1*sinU2 + cosU1*cosU2*cosL
+		sigma := math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinL / sinSigma
+		cos2Alpha := 1 - sinAlpha*sinAlpha
+		cos2SigmaM := 0.0
+		if cos2Alpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha
+		}
+		c := f / 16 * cos2Alpha * (4 + f*(4-3*cos2Alpha))
+		prev := lambda
+		lambda = l + (1-c)*f*sinAlpha*(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) > 1e-12 {
+			continue
+		}
+
+		u2 := cos2Alpha * (e.A*e.A - b*b) / (b * b)
+		ca := 1 + u2/16384*(4096+u2*(-768+u2*(320-175*u2)))
+		cb := u2 / 1024 * (256 + u2*(-128+u2*(74-47*u2)))
+		dSigma := cb * sinSigma * (cos2SigmaM + cb/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			cb/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		return b * ca * (sigma - dSigma)
+	}
+
+	// Vincenty fails to converge for nearly antipodal points, fall back to
+	// a sphere of the same mean radius.
+	return Ellipsoid{A: (2*e.A + b) / 3}.Distance(p, q)
+}
@@ -0,0 +1,191 @@
+package geometry
+
+import "math"
+
+// EqualsExact reports whether the points differ by at most tolerance in
+// each coordinate.
+func (p *Point) EqualsExact(q Point, tolerance float64) bool {
+	return math.Abs(p.X-q.X) <= tolerance && math.Abs(p.Y-q.Y) <= tolerance && math.Abs(p.Z-q.Z) <= tolerance
+}
+
+func pointsEqualExact(a, b []Point, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].EqualsExact(b[i], tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualsExact reports whether both lines have the same vertices in the
+// same order, to within tolerance.
+func (l LineString) EqualsExact(ls LineString, tolerance float64) bool {
+	return pointsEqualExact(l, ls, tolerance)
+}
+
+// EqualsExact reports whether both rings have the same vertices in the
+// same order, to within tolerance.
+func (r LinearRing) EqualsExact(lr LinearRing, tolerance float64) bool {
+	return pointsEqualExact(r, lr, tolerance)
+}
+
+// EqualsExact reports whether both polygons have the same rings in the
+// same order, to within tolerance.
+func (p Polygon) EqualsExact(q Polygon, tolerance float64) bool {
+	if len(p) != len(q) {
+		return false
+	}
+	for i := range p {
+		if !p[i].EqualsExact(q[i], tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualsExact reports whether both collections have the same lines in the
+// same order, to within tolerance.
+func (m MultiLineString) EqualsExact(n MultiLineString, tolerance float64) bool {
+	if len(m) != len(n) {
+		return false
+	}
+	for i := range m {
+		if !m[i].EqualsExact(n[i], tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualsExact reports whether both collections have the same polygons in
+// the same order, to within tolerance.
+func (m MultiPolygon) EqualsExact(n MultiPolygon, tolerance float64) bool {
+	if len(m) != len(n) {
+		return false
+	}
+	for i := range m {
+		if !m[i].EqualsExact(n[i], tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+// nearXY reports whether the points differ by at most tolerance in X and
+// Y, ignoring Z.
+func nearXY(a, b Point, tolerance float64) bool {
+	return math.Abs(a.X-b.X) <= tolerance && math.Abs(a.Y-b.Y) <= tolerance
+}
+
+// dedupPoints drops consecutive points within tolerance of each other,
+// and for rings last points within tolerance of the first.
+func dedupPoints(pts []Point, ring bool, tolerance float64) []Point {
+	out := []Point{}
+	for _, pt := range pts {
+		if len(out) == 0 || !nearXY(out[len(out)-1], pt, tolerance) {
+			out = append(out, pt)
+		}
+	}
+	for ring && len(out) > 1 && nearXY(out[len(out)-1], out[0], tolerance) {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// EqualsTopo reports whether both lines pass through the same vertices,
+// ignoring repeated vertices and direction. As with the other EqualsTopo
+// methods, vertices match when their X and Y differ by at most tolerance,
+// Z being ignored.
+func (l LineString) EqualsTopo(ls LineString, tolerance float64) bool {
+	a, b := dedupPoints(l, false, tolerance), dedupPoints(ls, false, tolerance)
+	if len(a) != len(b) {
+		return false
+	}
+	forward, backward := true, true
+	for i := range a {
+		forward = forward && nearXY(a[i], b[i], tolerance)
+		backward = backward && nearXY(a[i], b[len(b)-1-i], tolerance)
+	}
+	return forward || backward
+}
+
+// EqualsTopo reports whether both rings trace the same boundary, ignoring
+// repeated vertices, the starting vertex and orientation.
+func (r LinearRing) EqualsTopo(lr LinearRing, tolerance float64) bool {
+	a, b := dedupPoints(r, true, tolerance), dedupPoints(lr, true, tolerance)
+	if len(a) != len(b) {
+		return false
+	}
+	if len(a) == 0 {
+		return true
+	}
+	n := len(a)
+	for k := range b {
+		if !nearXY(b[k], a[0], tolerance) {
+			continue
+		}
+		forward, backward := true, true
+		for i := range a {
+			forward = forward && nearXY(a[i], b[(k+i)%n], tolerance)
+			backward = backward && nearXY(a[i], b[(k-i+n)%n], tolerance)
+		}
+		if forward || backward {
+			return true
+		}
+	}
+	return false
+}
+
+// EqualsTopo reports whether both polygons have topologically equal shells
+// and the same holes in any order.
+func (p Polygon) EqualsTopo(q Polygon, tolerance float64) bool {
+	if len(p) != len(q) {
+		return false
+	}
+	if len(p) == 0 {
+		return true
+	}
+	if !p[0].EqualsTopo(q[0], tolerance) {
+		return false
+	}
+	return matchAll(len(p)-1, func(i, j int) bool { return p[i+1].EqualsTopo(q[j+1], tolerance) })
+}
+
+// EqualsTopo reports whether both collections hold topologically equal
+// lines in any order.
+func (m MultiLineString) EqualsTopo(n MultiLineString, tolerance float64) bool {
+	if len(m) != len(n) {
+		return false
+	}
+	return matchAll(len(m), func(i, j int) bool { return m[i].EqualsTopo(n[j], tolerance) })
+}
+
+// EqualsTopo reports whether both collections hold topologically equal
+// polygons in any order.
+func (m MultiPolygon) EqualsTopo(n MultiPolygon, tolerance float64) bool {
+	if len(m) != len(n) {
+		return false
+	}
+	return matchAll(len(m), func(i, j int) bool { return m[i].EqualsTopo(n[j], tolerance) })
+}
+
+// matchAll reports whether each of n elements can be paired with a
+// distinct element of another collection of n for which eq holds.
+func matchAll(n int, eq func(i, j int) bool) bool {
+	used := make([]bool, n)
+	for i := 0; i < n; i++ {
+		found := false
+		for j := 0; j < n && !found; j++ {
+			if !used[j] && eq(i, j) {
+				used[j], found = true, true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
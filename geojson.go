@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 )
 
 type Geometry interface {
@@ -18,13 +19,15 @@ type Geometry interface {
 }
 
 type TypeExtractor struct {
-	Type     string           `json:"type"`
-	Geometry *json.RawMessage `json:"geometry"`
+	Type       string                 `json:"type"`
+	Geometry   *json.RawMessage       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
 }
 
 type Feature struct {
-	Type     string   `json:"type"`
-	Geometry Geometry `json:"geometry"`
+	Type       string                 `json:"type"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
 }
 
 type FeatureCollection struct {
@@ -32,6 +35,16 @@ type FeatureCollection struct {
 	Features []Feature `json:"features"`
 }
 
+// MarshalJSON writes a feature without properties with an empty
+// properties object, as RFC 7946 requires the member.
+func (f Feature) MarshalJSON() ([]byte, error) {
+	type feature Feature
+	if f.Properties == nil {
+		f.Properties = map[string]interface{}{}
+	}
+	return json.Marshal(feature(f))
+}
+
 func (f *Feature) UnmarshalJSON(in []byte) error {
 	featType := TypeExtractor{}
 	err := json.Unmarshal(in, &featType)
@@ -62,6 +75,22 @@ func (f *Feature) UnmarshalJSON(in []byte) error {
 		}
 		*f = Feature{Type: "Feature", Geometry: &ls}
 
+	case "MultiPoint":
+		var mp MultiPoint
+		err = json.Unmarshal(*featType.Geometry, &mp)
+		if err != nil {
+			return err
+		}
+		*f = Feature{Type: "Feature", Geometry: &mp}
+
+	case "MultiLineString":
+		var mls MultiLineString
+		err = json.Unmarshal(*featType.Geometry, &mls)
+		if err != nil {
+			return err
+		}
+		*f = Feature{Type: "Feature", Geometry: &mls}
+
 	case "Polygon":
 		var poly Polygon
 		err = json.Unmarshal(*featType.Geometry, &poly)
@@ -81,6 +110,64 @@ func (f *Feature) UnmarshalJSON(in []byte) error {
 	default:
 		return fmt.Errorf("json Unmarshal Feature: Geometry %s not recognised", string(*featType.Geometry))
 	}
+	f.Properties = featType.Properties
 
 	return nil
 }
+
+// GeoJSONEncoder writes geometries, features and feature collections as
+// GeoJSON. When RightHandRule is set polygons are written following the
+// RFC 7946 right-hand rule whatever the orientation of their rings.
+type GeoJSONEncoder struct {
+	RightHandRule bool
+	enc           *json.Encoder
+}
+
+func NewGeoJSONEncoder(w io.Writer) *GeoJSONEncoder {
+	return &GeoJSONEncoder{enc: json.NewEncoder(w)}
+}
+
+func (e *GeoJSONEncoder) Encode(v interface{}) error {
+	if !e.RightHandRule {
+		return e.enc.Encode(v)
+	}
+
+	switch t := v.(type) {
+	case Geometry:
+		v = forceRHR(t)
+	case Feature:
+		t.Geometry = forceRHR(t.Geometry)
+		v = t
+	case *Feature:
+		f := *t
+		f.Geometry = forceRHR(f.Geometry)
+		v = f
+	case FeatureCollection:
+		v = rhrFeatures(t)
+	case *FeatureCollection:
+		v = rhrFeatures(*t)
+	}
+	return e.enc.Encode(v)
+}
+
+func forceRHR(g Geometry) Geometry {
+	switch t := g.(type) {
+	case *Polygon:
+		p := t.ForceRHR()
+		return &p
+	case *MultiPolygon:
+		m := t.ForceRHR()
+		return &m
+	}
+	return g
+}
+
+func rhrFeatures(fc FeatureCollection) FeatureCollection {
+	features := make([]Feature, len(fc.Features))
+	for i, f := range fc.Features {
+		f.Geometry = forceRHR(f.Geometry)
+		features[i] = f
+	}
+	fc.Features = features
+	return fc
+}
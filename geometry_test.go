@@ -1,8 +1,20 @@
 package geometry
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestPointJSON(t *testing.T) {
@@ -68,6 +80,20 @@ func TestPointWKB(t *testing.T) {
 	if !pout.Equals(*p) {
 		t.Errorf("WKB Point Test failed, expected: %+v, got: %+v", *p, pout)
 	}
+
+	// ISO WKB, with the Z only written for a Point Z.
+	expected, _ := hex.DecodeString("0101000000" + "0000000000001040" + "0000000000002340")
+	if !bytes.Equal(wkbPoint, expected) {
+		t.Errorf("WKB Point Test failed, expected: %x, got: %x", expected, wkbPoint)
+	}
+	pz := &Point{X: 4.0, Y: 9.5, Z: 2}
+	expected, _ = hex.DecodeString("00000003e9" + "4010000000000000" + "4023000000000000" + "4000000000000000")
+	if got := pz.MarshalWKB(0); !bytes.Equal(got, expected) {
+		t.Errorf("WKB Point Test failed, expected: %x, got: %x", expected, got)
+	}
+	if err := pout.UnmarshalWKB(expected); err != nil || pout != *pz {
+		t.Errorf("WKB Point Test failed, expected: %+v, got: %+v %v", *pz, pout, err)
+	}
 }
 
 /*
@@ -105,6 +131,14 @@ func TestLineStringJSON(t *testing.T) {
 	if !lsout.Equals(ls) {
 		t.Errorf("JSON LineString Test failed, expected: %+v, got: %+v", ls, lsout)
 	}
+
+	seg := LineString{p1, p2}
+	out, _ = json.Marshal(seg)
+	var segout LineString
+	err = json.Unmarshal(out, &segout)
+	if err != nil || !segout.Equals(seg) {
+		t.Errorf("JSON LineString Test failed, expected: %+v, got: %+v (%v)", seg, segout, err)
+	}
 }
 
 func TestLineStringWKT(t *testing.T) {
@@ -151,7 +185,7 @@ func TestPolygonJSON(t *testing.T) {
 	p3 := Point{X: 4.0, Y: 5.5}
 	p := Polygon{LinearRing{p1, p2, p3}}
 
-	out, err := json.Marshal(p)
+	out, err := json.Marshal(&p)
 	if err != nil {
 		t.Errorf("JSON Polygon Test failed, error in JSON serialisation: %s", err)
 	}
@@ -304,6 +338,9 @@ func TestFeaturePoint(t *testing.T) {
 	if err != nil {
 		t.Errorf("GeoJSON Feature Point Test failed, error in JSON serialisation: %s", err)
 	}
+	if !strings.Contains(string(out), `"properties":{}`) {
+		t.Errorf("GeoJSON Feature Point Test failed, expected empty properties, got: %s", out)
+	}
 	var fout Feature
 	err = json.Unmarshal(out, &fout)
 	if err != nil {
@@ -389,3 +426,1793 @@ func TestFeatureMultiPolygonGeoJSON(t *testing.T) {
 	}
 }
 */
+
+func multiPolygonArea(m MultiPolygon) float64 {
+	a := 0.0
+	for _, p := range m {
+		for _, r := range p {
+			a += signedArea(r)
+		}
+	}
+	return a
+}
+
+func TestMakeValidBowTie(t *testing.T) {
+	p := Polygon{LinearRing{{X: 0, Y: 0}, {X: 2, Y: 2}, {X: 2, Y: 0}, {X: 0, Y: 2}}}
+
+	m := p.MakeValid()
+	if len(m) != 2 {
+		t.Fatalf("MakeValid BowTie Test failed, expected 2 polygons, got: %+v", m)
+	}
+	for _, poly := range m {
+		if len(poly) != 1 || len(poly[0]) != 3 || signedArea(poly[0]) != 1 {
+			t.Errorf("MakeValid BowTie Test failed, expected CCW triangle of area 1, got: %+v", poly)
+		}
+	}
+}
+
+func TestMakeValidPinch(t *testing.T) {
+	p := Polygon{LinearRing{{X: 2, Y: 2}, {X: 0, Y: 0}, {X: 4, Y: 0}, {X: 2, Y: 2}, {X: 4, Y: 4}, {X: 0, Y: 4}}}
+
+	m := p.MakeValid()
+	if len(m) != 2 {
+		t.Fatalf("MakeValid Pinch Test failed, expected 2 polygons, got: %+v", m)
+	}
+	for _, poly := range m {
+		if len(poly) != 1 || len(poly[0]) != 3 || signedArea(poly[0]) != 4 {
+			t.Errorf("MakeValid Pinch Test failed, expected CCW triangle of area 4, got: %+v", poly)
+		}
+	}
+}
+
+func TestMakeValidOrientation(t *testing.T) {
+	shell := LinearRing{{X: 0, Y: 0}, {X: 0, Y: 0}, {X: 0, Y: 4}, {X: 4, Y: 4}, {X: 4, Y: 0}}
+	hole := LinearRing{{X: 1, Y: 1}, {X: 3, Y: 1}, {X: 3, Y: 3}, {X: 1, Y: 3}}
+	p := Polygon{shell, hole}
+
+	m := p.MakeValid()
+	if len(m) != 1 || len(m[0]) != 2 {
+		t.Fatalf("MakeValid Orientation Test failed, expected polygon with one hole, got: %+v", m)
+	}
+	if len(m[0][0]) != 4 || signedArea(m[0][0]) != 16 {
+		t.Errorf("MakeValid Orientation Test failed, expected CCW shell, got: %+v", m[0][0])
+	}
+	if signedArea(m[0][1]) != -4 {
+		t.Errorf("MakeValid Orientation Test failed, expected CW hole, got: %+v", m[0][1])
+	}
+}
+
+func TestMakeValidOverlap(t *testing.T) {
+	m := MultiPolygon{
+		Polygon{LinearRing{{X: 0, Y: 0}, {X: 2, Y: 0}, {X: 2, Y: 2}, {X: 0, Y: 2}}},
+		Polygon{LinearRing{{X: 1, Y: 1}, {X: 3, Y: 1}, {X: 3, Y: 3}, {X: 1, Y: 3}}},
+		Polygon{LinearRing{{X: 2, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 1}, {X: 2, Y: 1}}},
+	}
+
+	out := m.MakeValid()
+	if len(out) != 1 || len(out[0]) != 1 {
+		t.Fatalf("MakeValid Overlap Test failed, expected a single polygon, got: %+v", out)
+	}
+	if a := multiPolygonArea(out); a != 9 {
+		t.Errorf("MakeValid Overlap Test failed, expected area 9, got: %g", a)
+	}
+}
+
+func TestMakeValidNested(t *testing.T) {
+	m := MultiPolygon{
+		Polygon{
+			LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}},
+			LinearRing{{X: 0, Y: 5}, {X: 5, Y: 2}, {X: 8, Y: 5}, {X: 5, Y: 8}},
+		},
+		Polygon{LinearRing{{X: 4, Y: 4}, {X: 6, Y: 4}, {X: 6, Y: 6}, {X: 4, Y: 6}}},
+	}
+
+	out := m.MakeValid()
+	if len(out) != 2 {
+		t.Fatalf("MakeValid Nested Test failed, expected 2 polygons, got: %+v", out)
+	}
+	sort.Slice(out, func(i, j int) bool { return signedArea(out[i][0]) > signedArea(out[j][0]) })
+	if len(out[0]) != 2 || signedArea(out[0][0]) != 100 || signedArea(out[0][1]) != -24 {
+		t.Errorf("MakeValid Nested Test failed, expected shell with the hole touching it, got: %+v", out[0])
+	}
+	if len(out[1]) != 1 || signedArea(out[1][0]) != 4 {
+		t.Errorf("MakeValid Nested Test failed, expected island without holes, got: %+v", out[1])
+	}
+
+	grid := MultiPolygon{}
+	for i := 0; i < 40; i++ {
+		for j := 0; j < 40; j++ {
+			x, y := float64(i)*2, float64(j)*2
+			grid = append(grid, Polygon{LinearRing{{X: x, Y: y}, {X: x + 1.5, Y: y}, {X: x + 1.5, Y: y + 1.5}, {X: x, Y: y + 1.5}}})
+		}
+	}
+	if out := grid.MakeValid(); len(out) != 1600 || multiPolygonArea(out) != 3600 {
+		t.Errorf("MakeValid Nested Test failed, expected 1600 squares, got %d of area %g", len(out), multiPolygonArea(out))
+	}
+}
+
+func TestRingOrientation(t *testing.T) {
+	r := LinearRing{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 0}}
+	if !r.IsCW() || r.IsCCW() {
+		t.Errorf("Ring Orientation Test failed, expected clockwise ring: %+v", r)
+	}
+
+	rev := r.Reverse()
+	if !rev.IsCCW() || !rev[0].Equals(r[0]) {
+		t.Errorf("Ring Orientation Test failed, expected counter-clockwise ring from same start, got: %+v", rev)
+	}
+}
+
+func TestPolygonForceRHR(t *testing.T) {
+	shell := LinearRing{{X: 0, Y: 0}, {X: 0, Y: 4}, {X: 4, Y: 4}, {X: 4, Y: 0}}
+	hole := LinearRing{{X: 1, Y: 1}, {X: 3, Y: 1}, {X: 3, Y: 3}, {X: 1, Y: 3}}
+	p := Polygon{shell, hole}
+
+	rhr := p.ForceRHR()
+	if !rhr[0].IsCCW() || !rhr[1].IsCW() {
+		t.Errorf("Polygon ForceRHR Test failed, got: %+v", rhr)
+	}
+	if !p[0].IsCW() {
+		t.Errorf("Polygon ForceRHR Test failed, input polygon was modified: %+v", p)
+	}
+
+	rhr[1][0].X = 9
+	if p[0][0].X != 0 || p[1][0].X != 1 {
+		t.Errorf("Polygon ForceRHR Test failed, output shares rings with the input: %+v", p)
+	}
+
+	m := MultiPolygon{p}
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{{Type: "Feature", Geometry: &m}}}
+	var buf bytes.Buffer
+	enc := NewGeoJSONEncoder(&buf)
+	enc.RightHandRule = true
+	err := enc.Encode(fc)
+	if err != nil {
+		t.Errorf("Polygon ForceRHR Test failed, error in JSON serialisation: %s", err)
+	}
+	var fout FeatureCollection
+	err = json.Unmarshal(buf.Bytes(), &fout)
+	if err != nil {
+		t.Errorf("Polygon ForceRHR Test failed, error in JSON deserialisation: %s", err)
+	}
+	mout := *fout.Features[0].Geometry.(*MultiPolygon)
+	if !mout[0][0].IsCCW() || !mout[0][1].IsCW() {
+		t.Errorf("Polygon ForceRHR Test failed, expected right-hand rule output, got: %+v", mout)
+	}
+	if !m[0][0].IsCW() {
+		t.Errorf("Polygon ForceRHR Test failed, encoding modified the feature: %+v", m)
+	}
+
+	buf.Reset()
+	err = NewGeoJSONEncoder(&buf).Encode(&m)
+	if err != nil {
+		t.Errorf("Polygon ForceRHR Test failed, error in JSON serialisation: %s", err)
+	}
+	mout = MultiPolygon{}
+	err = json.Unmarshal(buf.Bytes(), &mout)
+	if err != nil || !mout[0][0].IsCW() {
+		t.Errorf("Polygon ForceRHR Test failed, expected rings unchanged without the option, got: %+v", mout)
+	}
+}
+
+func TestTransformMGA(t *testing.T) {
+	// Flinders Peak, from the GDA94 Technical Manual.
+	gda94, _ := EPSG(4283)
+	mga55, _ := EPSG(28355)
+	p := &Point{X: 144 + 25.0/60 + 29.5244/3600, Y: -(37 + 57.0/60 + 3.7203/3600)}
+
+	g, err := Transform(p, gda94, mga55)
+	if err != nil {
+		t.Fatalf("Transform MGA Test failed, error in transformation: %s", err)
+	}
+	out := g.(*Point)
+	if math.Abs(out.X-273741.297) > 1e-3 || math.Abs(out.Y-5796489.777) > 1e-3 {
+		t.Errorf("Transform MGA Test failed, expected: 273741.297 5796489.777, got: %f %f", out.X, out.Y)
+	}
+
+	g, err = Transform(out, mga55, gda94)
+	back := g.(*Point)
+	if err != nil || math.Abs(back.X-p.X) > 1e-9 || math.Abs(back.Y-p.Y) > 1e-9 {
+		t.Errorf("Transform MGA Test failed, expected: %+v, got: %+v", p, back)
+	}
+}
+
+func TestTransformGDA2020(t *testing.T) {
+	// Alice Springs (ALIC), from the worked example of the GDA2020
+	// Technical Manual, in geocentric coordinates.
+	gda94, _ := EPSG(4283)
+	gda2020, _ := EPSG(7844)
+	from := [3]float64{-4052051.7643, 4212836.2017, -2545106.0245}
+	expected := [3]float64{-4052052.7379, 4212835.9897, -2545104.5898}
+
+	out, err := TransformPoint(geocentricToGeodetic(from[0], from[1], from[2], GRS80Ellipsoid), gda94, gda2020)
+	if err != nil {
+		t.Fatalf("Transform GDA2020 Test failed, error in transformation: %s", err)
+	}
+	x, y, z := geodeticToGeocentric(out, GRS80Ellipsoid)
+	if math.Abs(x-expected[0]) > 1e-3 || math.Abs(y-expected[1]) > 1e-3 || math.Abs(z-expected[2]) > 1e-3 {
+		t.Errorf("Transform GDA2020 Test failed, expected: %v, got: %f %f %f", expected, x, y, z)
+	}
+
+	back, err := TransformPoint(out, gda2020, gda94)
+	x, y, z = geodeticToGeocentric(back, GRS80Ellipsoid)
+	if err != nil || math.Abs(x-from[0]) > 1e-3 || math.Abs(y-from[1]) > 1e-3 || math.Abs(z-from[2]) > 1e-3 {
+		t.Errorf("Transform GDA2020 Test failed, expected: %v, got: %f %f %f", from, x, y, z)
+	}
+}
+
+func TestTransformAustralianAlbers(t *testing.T) {
+	// The false origin of EPSG:3577 at 0, 132E, and Canberra with the
+	// GRS80 formulas of EPSG Guidance Note 7-2.
+	gda94, _ := EPSG(4283)
+	albers, _ := EPSG(3577)
+	for _, c := range []struct{ in, expected Point }{
+		{Point{X: 132, Y: 0}, Point{X: 0, Y: 0}},
+		{Point{X: 149.13, Y: -35.28}, Point{X: 1550570.6188, Y: -3957368.5942}},
+	} {
+		out, err := TransformPoint(c.in, gda94, albers)
+		if err != nil || math.Abs(out.X-c.expected.X) > 1e-3 || math.Abs(out.Y-c.expected.Y) > 1e-3 {
+			t.Errorf("Transform Albers Test failed, expected: %+v, got: %+v %v", c.expected, out, err)
+		}
+		back, err := TransformPoint(out, albers, gda94)
+		if err != nil || math.Abs(back.X-c.in.X) > 1e-9 || math.Abs(back.Y-c.in.Y) > 1e-9 {
+			t.Errorf("Transform Albers Test failed, expected: %+v, got: %+v %v", c.in, back, err)
+		}
+	}
+}
+
+func TestProjectionSnyder(t *testing.T) {
+	// Numerical examples from Snyder, Map Projections: A Working Manual.
+	alb := NewAlbersEqualArea(Clarke1866Ellipsoid, 29.5, 45.5, 23, -96, 0, 0)
+	x, y, _ := alb.Forward(-75, 35)
+	if math.Abs(x-1885472.7) > 0.1 || math.Abs(y-1535925.0) > 0.1 {
+		t.Errorf("Albers Projection Test failed, expected: 1885472.7 1535925.0, got: %f %f", x, y)
+	}
+	lon, lat, _ := alb.Inverse(x, y)
+	if math.Abs(lon+75) > 1e-9 || math.Abs(lat-35) > 1e-9 {
+		t.Errorf("Albers Projection Test failed, expected: -75 35, got: %f %f", lon, lat)
+	}
+
+	merc := NewMercator(Clarke1866Ellipsoid, -180, 1, 0, 0)
+	x, y, _ = merc.Forward(-75, 35)
+	if math.Abs(x-11688673.7) > 0.1 || math.Abs(y-4139145.6) > 0.1 {
+		t.Errorf("Mercator Projection Test failed, expected: 11688673.7 4139145.6, got: %f %f", x, y)
+	}
+}
+
+func TestTransformPolygon(t *testing.T) {
+	wgs84, _ := EPSG(4326)
+	web, _ := EPSG(3857)
+	p := &Polygon{LinearRing{{X: 0, Y: 0}, {X: 90, Y: 0}, {X: 90, Y: 45}}}
+
+	g, err := Transform(p, wgs84, web)
+	if err != nil {
+		t.Fatalf("Transform Polygon Test failed, error in transformation: %s", err)
+	}
+	out := *g.(*Polygon)
+	if math.Abs(out[0][1].X-10018754.171394622) > 1e-6 {
+		t.Errorf("Transform Polygon Test failed, expected: 10018754.171395, got: %f", out[0][1].X)
+	}
+
+	g, _ = Transform(&out, web, wgs84)
+	back := *g.(*Polygon)
+	for i, pt := range back[0] {
+		if math.Abs(pt.X-(*p)[0][i].X) > 1e-9 || math.Abs(pt.Y-(*p)[0][i].Y) > 1e-9 {
+			t.Errorf("Transform Polygon Test failed, expected: %+v, got: %+v", *p, back)
+		}
+	}
+}
+
+func TestParseProjString(t *testing.T) {
+	crs, err := ParseProjString("+proj=aea +lat_1=29.5 +lat_2=45.5 +lat_0=23 +lon_0=-96 +x_0=0 +y_0=0 +ellps=clrk66 +units=m +no_defs")
+	if err != nil {
+		t.Fatalf("PROJ String Test failed, error in parsing: %s", err)
+	}
+	x, y, _ := crs.Projection.Forward(-75, 35)
+	if math.Abs(x-1885472.7) > 0.1 || math.Abs(y-1535925.0) > 0.1 {
+		t.Errorf("PROJ String Test failed, expected: 1885472.7 1535925.0, got: %f %f", x, y)
+	}
+
+	gda94, _ := EPSG(4283)
+	mga55, err := ParseProjString("+proj=utm +zone=55 +south +ellps=GRS80 +towgs84=0,0,0,0,0,0,0 +units=m +no_defs")
+	if err != nil {
+		t.Fatalf("PROJ String Test failed, error in parsing: %s", err)
+	}
+	p, _ := TransformPoint(Point{X: 144 + 25.0/60 + 29.5244/3600, Y: -(37 + 57.0/60 + 3.7203/3600)}, gda94, mga55)
+	if math.Abs(p.X-273741.297) > 1e-3 || math.Abs(p.Y-5796489.777) > 1e-3 {
+		t.Errorf("PROJ String Test failed, expected: 273741.297 5796489.777, got: %f %f", p.X, p.Y)
+	}
+
+	_, err = ParseProjString("+proj=lcc +lat_1=30 +ellps=WGS84")
+	if err == nil {
+		t.Errorf("PROJ String Test failed, expected error for unsupported projection")
+	}
+	_, err = ParseProjString("+proj=utm +zone=55.5 +south +ellps=GRS80")
+	if err == nil {
+		t.Errorf("PROJ String Test failed, expected error for fractional UTM zone")
+	}
+}
+
+func TestParseWKTCRS(t *testing.T) {
+	wkt1 := `PROJCS["GDA94 / MGA zone 55",GEOGCS["GDA94",DATUM["Geocentric_Datum_of_Australia_1994",SPHEROID["GRS 1980",6378137,298.257222101,AUTHORITY["EPSG","7019"]],TOWGS84[0,0,0,0,0,0,0],AUTHORITY["EPSG","6283"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4283"]],PROJECTION["Transverse_Mercator"],PARAMETER["latitude_of_origin",0],PARAMETER["central_meridian",147],PARAMETER["scale_factor",0.9996],PARAMETER["false_easting",500000],PARAMETER["false_northing",10000000],UNIT["metre",1,AUTHORITY["EPSG","9001"]],AXIS["Easting",EAST],AXIS["Northing",NORTH],AUTHORITY["EPSG","28355"]]`
+	wkt2 := `PROJCRS["GDA94 / MGA zone 55",
+    BASEGEOGCRS["GDA94",
+        DATUM["Geocentric Datum of Australia 1994",
+            ELLIPSOID["GRS 1980",6378137,298.257222101,LENGTHUNIT["metre",1]]],
+        PRIMEM["Greenwich",0,ANGLEUNIT["degree",0.0174532925199433]],
+        ID["EPSG",4283]],
+    CONVERSION["Map Grid of Australia zone 55",
+        METHOD["Transverse Mercator",ID["EPSG",9807]],
+        PARAMETER["Latitude of natural origin",0,ANGLEUNIT["degree",0.0174532925199433],ID["EPSG",8801]],
+        PARAMETER["Longitude of natural origin",147,ANGLEUNIT["degree",0.0174532925199433],ID["EPSG",8802]],
+        PARAMETER["Scale factor at natural origin",0.9996,SCALEUNIT["unity",1],ID["EPSG",8805]],
+        PARAMETER["False easting",500000,LENGTHUNIT["metre",1],ID["EPSG",8806]],
+        PARAMETER["False northing",10000000,LENGTHUNIT["metre",1],ID["EPSG",8807]]],
+    CS[Cartesian,2],
+        AXIS["easting (E)",east,ORDER[1],LENGTHUNIT["metre",1]],
+        AXIS["northing (N)",north,ORDER[2],LENGTHUNIT["metre",1]],
+    ID["EPSG",28355]]`
+
+	gda94, _ := EPSG(4283)
+	for _, wkt := range []string{wkt1, wkt2} {
+		crs, err := ParseWKTCRS(wkt)
+		if err != nil {
+			t.Fatalf("WKT CRS Test failed, error in parsing: %s", err)
+		}
+		if crs.Code != 28355 {
+			t.Errorf("WKT CRS Test failed, expected EPSG code 28355, got: %d", crs.Code)
+		}
+		p, _ := TransformPoint(Point{X: 144 + 25.0/60 + 29.5244/3600, Y: -(37 + 57.0/60 + 3.7203/3600)}, gda94, crs)
+		if math.Abs(p.X-273741.297) > 1e-3 || math.Abs(p.Y-5796489.777) > 1e-3 {
+			t.Errorf("WKT CRS Test failed, expected: 273741.297 5796489.777, got: %f %f", p.X, p.Y)
+		}
+	}
+
+	// Units and prime meridians the CRS cannot represent are rejected
+	// rather than silently read as degrees, metres and Greenwich.
+	for _, wkt := range []string{
+		`GEOGCS["NTF (Paris)",DATUM["Nouvelle_Triangulation_Francaise_Paris",SPHEROID["Clarke 1880 (IGN)",6378249.2,293.4660212936269]],PRIMEM["Paris",2.33722917],UNIT["grad",0.01570796326794897]]`,
+		`GEOGCS["GDA94",DATUM["GDA94",SPHEROID["GRS 1980",6378137,298.257222101]],PRIMEM["Greenwich",0],UNIT["grad",0.01570796326794897]]`,
+		strings.Replace(wkt2, `AXIS["easting (E)",east,ORDER[1],LENGTHUNIT["metre",1]]`, `AXIS["easting (E)",east,ORDER[1],LENGTHUNIT["US survey foot",0.304800609601219]]`, 1),
+	} {
+		if _, err := ParseWKTCRS(wkt); err == nil {
+			t.Errorf("WKT CRS Test failed, expected error for unsupported unit in: %s", wkt)
+		}
+	}
+
+	// Datums are known by name or TOWGS84, others being rejected rather
+	// than taken as WGS84.
+	agd66 := `GEOGCS["AGD66",DATUM["Australian_Geodetic_Datum_1966",SPHEROID["Australian National Spheroid",6378160,298.25]%s],PRIMEM["Greenwich",0],UNIT["degree",0.0174532925199433]]`
+	if _, err := ParseWKTCRS(fmt.Sprintf(agd66, "")); err == nil {
+		t.Errorf("WKT CRS Test failed, expected error for unknown datum without TOWGS84")
+	}
+	crs, err := ParseWKTCRS(fmt.Sprintf(agd66, `,TOWGS84[-117.808,-51.536,137.784,0.303,0.446,0.234,-0.29]`))
+	if err != nil || crs.Datum.ToWGS84.TX != -117.808 {
+		t.Errorf("WKT CRS Test failed, expected AGD66 with TOWGS84, got: %+v %v", crs, err)
+	}
+	esri := `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`
+	if crs, err := ParseWKTCRS(esri); err != nil || crs.Datum.Ellipsoid != WGS84Ellipsoid {
+		t.Errorf("WKT CRS Test failed, expected ESRI WGS84, got: %+v %v", crs, err)
+	}
+}
+
+func TestAffineGeoTransform(t *testing.T) {
+	gt := [6]float64{112, 0.05, 0, -10, 0, -0.05}
+	a := AffineFromGeoTransform(gt)
+
+	p := a.ApplyPoint(Point{X: 20, Y: 40})
+	if !p.Equals(Point{X: 113, Y: -12, Z: 0}) {
+		t.Errorf("Affine GeoTransform Test failed, expected: {113 -12 0}, got: %+v", p)
+	}
+
+	inv, err := a.Inverse()
+	if err != nil {
+		t.Fatalf("Affine GeoTransform Test failed, error in inverse: %s", err)
+	}
+	q := inv.ApplyPoint(p)
+	if math.Abs(q.X-20) > 1e-9 || math.Abs(q.Y-40) > 1e-9 {
+		t.Errorf("Affine GeoTransform Test failed, expected: {20 40 0}, got: %+v", q)
+	}
+	if a.GeoTransform() != gt {
+		t.Errorf("Affine GeoTransform Test failed, expected: %v, got: %v", gt, a.GeoTransform())
+	}
+}
+
+func TestAffineApply(t *testing.T) {
+	p := &Polygon{LinearRing{{X: 1, Y: 0}, {X: 2, Y: 0}, {X: 2, Y: 1}}}
+	a := AffineTranslate(0, 0, 5).Multiply(AffineRotate(90)).Multiply(AffineScale(2, 2, 1))
+
+	g, err := a.Apply(p)
+	if err != nil {
+		t.Fatalf("Affine Apply Test failed, error in transformation: %s", err)
+	}
+	out := *g.(*Polygon)
+	expected := LinearRing{{X: 0, Y: 2, Z: 5}, {X: 0, Y: 4, Z: 5}, {X: -2, Y: 4, Z: 5}}
+	for i, pt := range out[0] {
+		if math.Abs(pt.X-expected[i].X) > 1e-9 || math.Abs(pt.Y-expected[i].Y) > 1e-9 || pt.Z != expected[i].Z {
+			t.Errorf("Affine Apply Test failed, expected: %+v, got: %+v", expected, out[0])
+		}
+	}
+
+	if _, err := AffineScale(0, 1, 1).Inverse(); err == nil {
+		t.Errorf("Affine Apply Test failed, expected error inverting singular transformation")
+	}
+}
+
+func countMask(mask []bool) int {
+	n := 0
+	for _, v := range mask {
+		if v {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRasterizePolygon(t *testing.T) {
+	grid := NewGrid(100, -10, 1, 1, 10, 10)
+	p := &Polygon{LinearRing{{X: 102.6, Y: -12.6}, {X: 105.4, Y: -12.6}, {X: 105.4, Y: -15.4}, {X: 102.6, Y: -15.4}}}
+
+	mask, err := grid.Rasterize(p, CentrePoint)
+	if err != nil {
+		t.Fatalf("Rasterize Polygon Test failed, error in rasterisation: %s", err)
+	}
+	if n := countMask(mask); n != 4 || !mask[3*10+3] || mask[2*10+2] {
+		t.Errorf("Rasterize Polygon Test failed, expected 4 centre pixels, got: %d", n)
+	}
+
+	mask, _ = grid.Rasterize(p, AllTouched)
+	if n := countMask(mask); n != 16 || !mask[2*10+2] {
+		t.Errorf("Rasterize Polygon Test failed, expected 16 touched pixels, got: %d", n)
+	}
+
+	cov, err := grid.Coverage(p)
+	if err != nil {
+		t.Fatalf("Rasterize Polygon Test failed, error in coverage: %s", err)
+	}
+	if math.Abs(cov[2*10+2]-0.16) > 1e-9 || math.Abs(cov[2*10+3]-0.4) > 1e-9 || cov[3*10+3] != 1 || cov[0] != 0 {
+		t.Errorf("Rasterize Polygon Test failed, unexpected coverage: %v", cov[20:40])
+	}
+}
+
+func TestRasterizeLineString(t *testing.T) {
+	grid := NewGrid(0, 10, 1, 1, 10, 10)
+	ls := &LineString{{X: 0.5, Y: 9.5}, {X: 9.5, Y: 9.5}, {X: 9.5, Y: 0.5}}
+
+	mask, err := grid.Rasterize(ls, CentrePoint)
+	if err != nil {
+		t.Fatalf("Rasterize LineString Test failed, error in rasterisation: %s", err)
+	}
+	if n := countMask(mask); n != 19 || !mask[9] || !mask[99] {
+		t.Errorf("Rasterize LineString Test failed, expected 19 pixels, got: %d", n)
+	}
+}
+
+func TestPolygonize(t *testing.T) {
+	data := [][]int{
+		{1, 1, 1, 1},
+		{1, 2, 1, 0},
+		{1, 1, 0, 1},
+	}
+
+	fc, err := Polygonize(data, [6]float64{100, 1, 0, -10, 0, -1}, FourConnected)
+	if err != nil {
+		t.Fatalf("Polygonize Test failed, error in polygonisation: %s", err)
+	}
+	if len(fc.Features) != 3 {
+		t.Fatalf("Polygonize Test failed, expected 3 features, got: %d", len(fc.Features))
+	}
+
+	ones := *fc.Features[1].Geometry.(*MultiPolygon)
+	if fc.Features[1].Properties["value"] != 1 || len(ones) != 2 {
+		t.Errorf("Polygonize Test failed, expected two polygons of value 1, got: %+v", ones)
+	}
+	if a := multiPolygonArea(ones); a != 9 {
+		t.Errorf("Polygonize Test failed, expected area 9 for value 1, got: %g", a)
+	}
+	holes := 0
+	for _, p := range ones {
+		holes += len(p) - 1
+		if !p[0].IsCCW() {
+			t.Errorf("Polygonize Test failed, expected counter-clockwise shell, got: %+v", p[0])
+		}
+	}
+	if holes != 1 {
+		t.Errorf("Polygonize Test failed, expected one hole in value 1, got: %d", holes)
+	}
+
+	zeros := *fc.Features[0].Geometry.(*MultiPolygon)
+	if len(zeros) != 2 {
+		t.Errorf("Polygonize Test failed, expected two 4-connected polygons of value 0, got: %+v", zeros)
+	}
+
+	fc, _ = Polygonize(data, [6]float64{100, 1, 0, -10, 0, -1}, EightConnected)
+	zeros = *fc.Features[0].Geometry.(*MultiPolygon)
+	if len(zeros) != 1 || len(zeros[0][0]) != 8 {
+		t.Errorf("Polygonize Test failed, expected one 8-connected polygon of value 0, got: %+v", zeros)
+	}
+}
+
+func TestContourLines(t *testing.T) {
+	data := [][]float64{
+		{0, 0, 0, 0, 0},
+		{0, 1, 1, 1, 0},
+		{0, 1, 2, 1, 0},
+		{0, 1, 1, 1, -9999},
+	}
+
+	nodata := -9999.0
+	fc, err := ContourLines(data, [6]float64{0, 1, 0, 0, 0, -1}, []float64{0.5, 1.5}, &nodata)
+	if err != nil {
+		t.Fatalf("Contour Lines Test failed, error in contouring: %s", err)
+	}
+	if len(fc.Features) != 2 || fc.Features[1].Properties["level"] != 1.5 {
+		t.Fatalf("Contour Lines Test failed, expected 2 levels, got: %+v", fc.Features)
+	}
+
+	inner := *fc.Features[1].Geometry.(*MultiLineString)
+	if len(inner) != 1 || len(inner[0]) != 5 || !inner[0][0].Equals(inner[0][4]) {
+		t.Errorf("Contour Lines Test failed, expected closed diamond at 1.5, got: %+v", inner)
+	}
+	if !inner[0][0].Equals(Point{X: 2.5, Y: -2, Z: 0}) && !inner[0][0].Equals(Point{X: 3, Y: -2.5, Z: 0}) {
+		t.Errorf("Contour Lines Test failed, expected diamond around the peak, got: %+v", inner)
+	}
+
+	outer := *fc.Features[0].Geometry.(*MultiLineString)
+	if len(outer) != 1 || outer[0][0].Equals(outer[0][len(outer[0])-1]) {
+		t.Errorf("Contour Lines Test failed, expected open line at 0.5, got: %+v", outer)
+	}
+}
+
+func TestContourBands(t *testing.T) {
+	data := [][]float64{
+		{0, 1, 2, 3, 4},
+		{0, 1, 2, 3, 4},
+		{0, 1, 2, 3, 4},
+	}
+
+	// Without nodata the zero samples of the first column are kept.
+	fc, err := ContourBands(data, [6]float64{0, 1, 0, 0, 0, -1}, []float64{0.5, 2.5, 10}, nil)
+	if err != nil {
+		t.Fatalf("Contour Bands Test failed, error in contouring: %s", err)
+	}
+	if len(fc.Features) != 2 {
+		t.Fatalf("Contour Bands Test failed, expected 2 bands, got: %d", len(fc.Features))
+	}
+
+	band := *fc.Features[0].Geometry.(*MultiPolygon)
+	if len(band) != 1 || len(band[0]) != 1 || len(band[0][0]) != 4 {
+		t.Errorf("Contour Bands Test failed, expected a single rectangle, got: %+v", band)
+	}
+	if a := multiPolygonArea(band); math.Abs(a-4) > 1e-9 {
+		t.Errorf("Contour Bands Test failed, expected area 4, got: %g", a)
+	}
+	if a := multiPolygonArea(*fc.Features[1].Geometry.(*MultiPolygon)); math.Abs(a-3) > 1e-9 {
+		t.Errorf("Contour Bands Test failed, expected area 3, got: %g", a)
+	}
+
+	if _, err := ContourBands(data, [6]float64{0, 1, 0, 0, 0, -1}, []float64{2, 1}, nil); err == nil {
+		t.Errorf("Contour Bands Test failed, expected error for descending levels")
+	}
+}
+
+func randomBounds(r *rand.Rand, n int) []Bounds {
+	out := make([]Bounds, n)
+	for i := range out {
+		x, y := r.Float64()*1000, r.Float64()*1000
+		out[i] = Bounds{x, y, x + r.Float64()*20, y + r.Float64()*20}
+	}
+	return out
+}
+
+func TestRTreeSearch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	boxes := randomBounds(r, 2000)
+	ids := make([]int, len(boxes))
+	for i := range ids {
+		ids[i] = i
+	}
+
+	inserted := NewRTree[int](8)
+	for i, b := range boxes {
+		inserted.Insert(b, i)
+	}
+	loaded := NewRTree[int](8)
+	loaded.BulkLoad(boxes, ids)
+
+	for q := 0; q < 50; q++ {
+		query := randomBounds(r, 1)[0]
+		query.MaxX += 50
+		query.MaxY += 50
+		expected := 0
+		for _, b := range boxes {
+			if b.Intersects(query) {
+				expected++
+			}
+		}
+		for _, tree := range []*RTree[int]{inserted, loaded} {
+			if got := len(tree.SearchItems(query)); got != expected {
+				t.Errorf("RTree Search Test failed, expected %d items, got: %d", expected, got)
+			}
+		}
+	}
+
+	for i := 0; i < len(boxes); i += 2 {
+		if !inserted.Delete(boxes[i], func(id int) bool { return id == i }) {
+			t.Errorf("RTree Search Test failed, item %d not deleted", i)
+		}
+	}
+	if inserted.Len() != len(boxes)/2 {
+		t.Errorf("RTree Search Test failed, expected %d items after delete, got: %d", len(boxes)/2, inserted.Len())
+	}
+	inserted.Each(func(_ Bounds, id int) bool {
+		if id%2 == 0 {
+			t.Errorf("RTree Search Test failed, deleted item %d still present", id)
+		}
+		return true
+	})
+}
+
+func TestRTreeNearest(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	boxes := randomBounds(r, 500)
+	tree := NewRTree[int](0)
+	for i, b := range boxes {
+		tree.Insert(b, i)
+	}
+
+	p := Point{X: 500, Y: 500}
+	near := tree.Nearest(p, 10)
+	if len(near) != 10 {
+		t.Fatalf("RTree Nearest Test failed, expected 10 items, got: %d", len(near))
+	}
+	dists := make([]float64, len(boxes))
+	for i, b := range boxes {
+		dists[i] = b.Distance(p)
+	}
+	sorted := append([]float64{}, dists...)
+	sort.Float64s(sorted)
+	for i, id := range near {
+		if dists[id] != sorted[i] {
+			t.Errorf("RTree Nearest Test failed, expected distance %g at %d, got: %g", sorted[i], i, dists[id])
+		}
+	}
+}
+
+func TestRTreeFeatures(t *testing.T) {
+	tree := NewRTree[*Feature](0)
+	for i := 0; i < 10; i++ {
+		p := Polygon{LinearRing{{X: float64(i), Y: 0}, {X: float64(i) + 1, Y: 0}, {X: float64(i) + 1, Y: 1}}}
+		f := &Feature{Type: "Feature", Geometry: &p, Properties: map[string]interface{}{"id": i}}
+		b, err := BoundsOf(f.Geometry)
+		if err != nil {
+			t.Fatalf("RTree Features Test failed, error in bounds: %s", err)
+		}
+		tree.Insert(b, f)
+	}
+
+	found := tree.SearchItems(Bounds{2.5, 0.5, 4.5, 2})
+	if len(found) != 3 {
+		t.Errorf("RTree Features Test failed, expected 3 features, got: %d", len(found))
+	}
+}
+
+func TestConcurrentRTree(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	boxes := randomBounds(r, 2000)
+	tree := NewConcurrentRTree[int](0)
+	loaded := make([]int, 1000)
+	for i := range loaded {
+		loaded[i] = i
+	}
+	tree.BulkLoad(boxes[:1000], loaded)
+	before := tree.Snapshot()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1000; i < len(boxes); i++ {
+			tree.Insert(boxes[i], i)
+			if i%10 == 0 {
+				tree.Delete(boxes[i-1000], func(id int) bool { return id == i-1000 })
+			}
+		}
+	}()
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				snap := tree.Snapshot()
+				n := 0
+				snap.Each(func(Bounds, int) bool {
+					n++
+					return true
+				})
+				if n != snap.Len() {
+					t.Errorf("Concurrent RTree Test failed, snapshot of %d items holds %d", snap.Len(), n)
+					return
+				}
+				tree.SearchItems(boxes[(g*200+i)%len(boxes)])
+				tree.Nearest(Point{X: 500, Y: 500}, 5)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if before.Len() != 1000 || len(before.SearchItems(Bounds{0, 0, 1000, 1000})) != 1000 {
+		t.Errorf("Concurrent RTree Test failed, earlier snapshot was modified")
+	}
+	if tree.Len() != 1900 {
+		t.Errorf("Concurrent RTree Test failed, expected 1900 items, got: %d", tree.Len())
+	}
+	all := tree.SearchItems(Bounds{-1, -1, 1100, 1100})
+	if len(all) != 1900 {
+		t.Errorf("Concurrent RTree Test failed, expected 1900 items in search, got: %d", len(all))
+	}
+}
+
+func TestDistance(t *testing.T) {
+	pt := Point{X: 5, Y: 5}
+	line := LineString{{X: 0, Y: 0}, {X: 10, Y: 0}}
+	square := Polygon{LinearRing{{X: 20, Y: 0}, {X: 30, Y: 0}, {X: 30, Y: 10}, {X: 20, Y: 10}}, LinearRing{{X: 22, Y: 2}, {X: 22, Y: 8}, {X: 28, Y: 8}, {X: 28, Y: 2}}}
+	cross := LineString{{X: 5, Y: -5}, {X: 5, Y: 5}}
+	inHole := Point{X: 26, Y: 5}
+	inside := Point{X: 21, Y: 5}
+	multi := MultiPolygon{square, Polygon{LinearRing{{X: -10, Y: 20}, {X: 0, Y: 20}, {X: 0, Y: 30}}}}
+
+	tests := []struct {
+		a, b Geometry
+		dist float64
+		pa   Point
+		pb   Point
+	}{
+		{&pt, &line, 5, Point{X: 5, Y: 5}, Point{X: 5, Y: 0}},
+		{&line, &square, 10, Point{X: 10, Y: 0}, Point{X: 20, Y: 0}},
+		{&line, &cross, 0, Point{X: 5, Y: 0}, Point{X: 5, Y: 0}},
+		{&inHole, &square, 2, Point{X: 26, Y: 5}, Point{X: 28, Y: 5}},
+		{&inside, &square, 0, Point{X: 21, Y: 5}, Point{X: 21, Y: 5}},
+		{&pt, &multi, 15, Point{X: 5, Y: 5}, Point{X: 20, Y: 5}},
+	}
+	for i, test := range tests {
+		d, err := Distance(test.a, test.b)
+		if err != nil {
+			t.Fatalf("Distance Test failed, error in case %d: %s", i, err)
+		}
+		if math.Abs(d-test.dist) > 1e-12 {
+			t.Errorf("Distance Test failed in case %d, expected %g, got: %g", i, test.dist, d)
+		}
+		pa, pb, _ := NearestPoints(test.a, test.b)
+		if pa != test.pa || pb != test.pb {
+			t.Errorf("Distance Test failed in case %d, expected nearest points %v %v, got: %v %v", i, test.pa, test.pb, pa, pb)
+		}
+	}
+}
+
+func TestGeodesicDistance(t *testing.T) {
+	// Flinders Peak to Buninyong, from the GDA technical manual.
+	flinders := Point{X: 144 + 25/60.0 + 29.5244/3600, Y: -(37 + 57/60.0 + 3.7203/3600)}
+	buninyong := Point{X: 143 + 55/60.0 + 35.3839/3600, Y: -(37 + 39/60.0 + 10.1561/3600)}
+	d, err := GeodesicDistance(&flinders, &buninyong, GRS80Ellipsoid)
+	if err != nil || math.Abs(d-54972.271) > 1e-3 {
+		t.Errorf("Geodesic Distance Test failed, expected 54972.271, got: %f %v", d, err)
+	}
+
+	pt := Point{X: 1, Y: 0}
+	meridian := LineString{{X: 0, Y: -10}, {X: 0, Y: 10}}
+	d, _ = GeodesicDistance(&pt, &meridian, EarthSphere)
+	if math.Abs(d-EarthSphere.A*deg) > 1e-6 {
+		t.Errorf("Geodesic Distance Test failed, expected %f, got: %f", EarthSphere.A*deg, d)
+	}
+	_, near, _ := GeodesicNearestPoints(&pt, &meridian)
+	if math.Abs(near.X) > 1e-12 || math.Abs(near.Y) > 1e-12 {
+		t.Errorf("Geodesic Distance Test failed, expected nearest point at the origin, got: %v", near)
+	}
+
+	// The great circle between the ends of the line bulges poleward of
+	// the parallel, reaching atan(2) at its midpoint.
+	arc := LineString{{X: -60, Y: 45}, {X: 60, Y: 45}}
+	north := Point{X: 0, Y: 60}
+	_, near, _ = GeodesicNearestPoints(&north, &arc)
+	if math.Abs(near.Y-math.Atan(2)/deg) > 1e-9 || math.Abs(near.X) > 1e-9 {
+		t.Errorf("Geodesic Distance Test failed, expected the arc midpoint at %f, got: %v", math.Atan(2)/deg, near)
+	}
+}
+
+func TestHausdorffDistance(t *testing.T) {
+	a := LineString{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 10, Y: 100}, {X: 10, Y: 100}}
+	b := LineString{{X: 0, Y: 100}, {X: 0, Y: 10}, {X: 80, Y: 10}}
+	d, err := HausdorffDistance(&a, &b, 0)
+	if err != nil || math.Abs(d-math.Sqrt(500)) > 1e-12 {
+		t.Errorf("Hausdorff Distance Test failed, expected %g, got: %g %v", math.Sqrt(500), d, err)
+	}
+
+	// The middle of the line is far from both posts, but only densifying
+	// the line finds it.
+	line := LineString{{X: 0, Y: 0}, {X: 10, Y: 0}}
+	posts := MultiLineString{{{X: 0, Y: 0}, {X: 0, Y: 1}}, {{X: 10, Y: 0}, {X: 10, Y: 1}}}
+	if d, _ := HausdorffDistance(&line, &posts, 0); d != 1 {
+		t.Errorf("Hausdorff Distance Test failed, expected 1, got: %g", d)
+	}
+	if d, _ := HausdorffDistance(&line, &posts, 0.5); d != 5 {
+		t.Errorf("Hausdorff Distance Test failed, expected 5 when densified, got: %g", d)
+	}
+
+	square := Polygon{LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}}
+	grown := Polygon{LinearRing{{X: -1, Y: -1}, {X: -1, Y: 11}, {X: 11, Y: 11}, {X: 11, Y: -1}}}
+	if d, _ := HausdorffDistance(&square, &grown, 0.1); math.Abs(d-math.Sqrt2) > 1e-12 {
+		t.Errorf("Hausdorff Distance Test failed, expected %g, got: %g", math.Sqrt2, d)
+	}
+	if _, err := HausdorffDistance(&square, &grown, 2); err == nil {
+		t.Errorf("Hausdorff Distance Test failed, expected error for densify fraction 2")
+	}
+}
+
+func TestFrechetDistance(t *testing.T) {
+	a := LineString{{X: 0, Y: 0}, {X: 100, Y: 0}}
+	b := LineString{{X: 0, Y: 0}, {X: 50, Y: 50}, {X: 100, Y: 0}}
+	d, err := FrechetDistance(&a, &b)
+	if err != nil || math.Abs(d-50*math.Sqrt2) > 1e-12 {
+		t.Errorf("Frechet Distance Test failed, expected %g, got: %g %v", 50*math.Sqrt2, d, err)
+	}
+
+	// Unlike Hausdorff, Fréchet distance depends on direction.
+	reversed := LineString{{X: 100, Y: 0}, {X: 0, Y: 0}}
+	if d, _ := FrechetDistance(&a, &reversed); d != 100 {
+		t.Errorf("Frechet Distance Test failed, expected 100 for reversed line, got: %g", d)
+	}
+	if d, _ := HausdorffDistance(&a, &reversed, 0); d != 0 {
+		t.Errorf("Frechet Distance Test failed, expected Hausdorff 0 for reversed line, got: %g", d)
+	}
+
+	square := Polygon{LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}}
+	shifted := Polygon{LinearRing{{X: 1, Y: 0}, {X: 11, Y: 0}, {X: 11, Y: 10}, {X: 1, Y: 10}}}
+	if d, _ := FrechetDistance(&square, &shifted); d != 1 {
+		t.Errorf("Frechet Distance Test failed, expected 1 for shifted polygon, got: %g", d)
+	}
+	mp := MultiPolygon{square}
+	if _, err := FrechetDistance(&mp, &square); err == nil {
+		t.Errorf("Frechet Distance Test failed, expected error for MultiPolygon")
+	}
+}
+
+func TestEqualsExact(t *testing.T) {
+	a := LineString{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 0}}
+	b := LineString{{X: 0, Y: 0}, {X: 1, Y: 1.0005}, {X: 2, Y: 0}}
+	if a.Equals(a[:2]) || a[:2].Equals(a) {
+		t.Errorf("Equals Exact Test failed, lines of different length compare equal")
+	}
+	if a.EqualsExact(b, 1e-4) || !a.EqualsExact(b, 1e-3) {
+		t.Errorf("Equals Exact Test failed, tolerance not applied")
+	}
+
+	p := Polygon{LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}}
+	q := Polygon{LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}, LinearRing{{X: 6, Y: 2}, {X: 8, Y: 2}, {X: 8, Y: 4}}}
+	if p.Equals(q) || q.Equals(p) || p.EqualsExact(q, 1) {
+		t.Errorf("Equals Exact Test failed, polygons with different holes compare equal")
+	}
+	if !(MultiPolygon{q}).EqualsExact(MultiPolygon{q}, 0) {
+		t.Errorf("Equals Exact Test failed, MultiPolygon not equal to itself")
+	}
+}
+
+func TestEqualsTopo(t *testing.T) {
+	line := LineString{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 0}}
+	if !line.EqualsTopo(LineString{{X: 2, Y: 0}, {X: 1, Y: 1}, {X: 1, Y: 1}, {X: 0, Y: 0}}, 0) {
+		t.Errorf("Equals Topo Test failed, reversed line with duplicate vertex not equal")
+	}
+	if line.EqualsTopo(LineString{{X: 1, Y: 1}, {X: 2, Y: 0}, {X: 0, Y: 0}}, 0) {
+		t.Errorf("Equals Topo Test failed, lines with different vertex order equal")
+	}
+
+	ring := LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}
+	rotated := LinearRing{{X: 10, Y: 10}, {X: 0, Y: 10}, {X: 0, Y: 0}, {X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}
+	if !ring.EqualsTopo(rotated, 0) || !ring.EqualsTopo(rotated.Reverse(), 0) {
+		t.Errorf("Equals Topo Test failed, rotated or reversed ring not equal")
+	}
+	if ring.EqualsTopo(LinearRing{{X: 0, Y: 0}, {X: 10, Y: 10}, {X: 10, Y: 0}, {X: 0, Y: 10}}, 0) {
+		t.Errorf("Equals Topo Test failed, bow tie equal to square")
+	}
+
+	hole1 := LinearRing{{X: 1, Y: 1}, {X: 2, Y: 1}, {X: 2, Y: 2}}
+	hole2 := LinearRing{{X: 5, Y: 5}, {X: 6, Y: 5}, {X: 6, Y: 6}}
+	p := Polygon{ring, hole1, hole2}
+	q := Polygon{rotated.Reverse(), hole2.Reverse(), hole1}
+	if !p.EqualsTopo(q, 0) || p.Equals(q) {
+		t.Errorf("Equals Topo Test failed, polygons with reordered holes")
+	}
+	if !(MultiPolygon{p, Polygon{hole1}}).EqualsTopo(MultiPolygon{Polygon{hole1}, q}, 0) {
+		t.Errorf("Equals Topo Test failed, reordered MultiPolygon not equal")
+	}
+	if (MultiPolygon{p, p}).EqualsTopo(MultiPolygon{p, Polygon{hole1}}, 0) {
+		t.Errorf("Equals Topo Test failed, MultiPolygons with different members equal")
+	}
+
+	noisy := LinearRing{{X: 1e-10, Y: 10, Z: 3}, {X: 0, Y: 0, Z: 3}, {X: 10, Y: 0, Z: 3}, {X: 10, Y: 10 - 1e-10, Z: 3}}
+	if !ring.EqualsTopo(noisy, 1e-9) || ring.EqualsTopo(noisy, 0) {
+		t.Errorf("Equals Topo Test failed, expected Z ignored and noise within tolerance only")
+	}
+}
+
+func TestCentroid(t *testing.T) {
+	pt := Point{X: 3, Y: 4}
+	if c := pt.Centroid(); c != pt {
+		t.Errorf("Centroid Test failed, expected %v, got: %v", pt, c)
+	}
+
+	// The long segment dominates the length weighted centre.
+	line := LineString{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 2}}
+	if c := line.Centroid(); math.Abs(c.X-70.0/12) > 1e-12 || math.Abs(c.Y-2.0/12) > 1e-12 {
+		t.Errorf("Centroid Test failed, expected line centroid (%g %g), got: %v", 70.0/12, 2.0/12, c)
+	}
+	mls := MultiLineString{{{X: 0, Y: 0}, {X: 2, Y: 0}}, {{X: 10, Y: 10}, {X: 10, Y: 12}}}
+	if c := mls.Centroid(); c.X != 5.5 || c.Y != 5.5 {
+		t.Errorf("Centroid Test failed, expected MultiLineString centroid (5.5 5.5), got: %v", c)
+	}
+
+	// A C shape, a 10 x 10 square with 8 x 6 cut from its right side.
+	shape := Polygon{LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 2}, {X: 2, Y: 2}, {X: 2, Y: 8}, {X: 10, Y: 8}, {X: 10, Y: 10}, {X: 0, Y: 10}}}
+	want := Point{X: (500 - 48*6) / 52.0, Y: 5}
+	if c := shape.Centroid(); math.Abs(c.X-want.X) > 1e-12 || math.Abs(c.Y-want.Y) > 1e-12 {
+		t.Errorf("Centroid Test failed, expected polygon centroid %v, got: %v", want, c)
+	}
+	if polygonContains(shape, shape.Centroid()) {
+		t.Errorf("Centroid Test failed, expected C shape centroid outside the polygon")
+	}
+	reversed := Polygon{shape[0].Reverse()}
+	if c := reversed.Centroid(); math.Abs(c.X-want.X) > 1e-12 {
+		t.Errorf("Centroid Test failed, centroid depends on orientation: %v", c)
+	}
+
+	mp := MultiPolygon{shape, Polygon{LinearRing{{X: 20, Y: 0}, {X: 30, Y: 0}, {X: 30, Y: 10}, {X: 20, Y: 10}}}}
+	if c := mp.Centroid(); math.Abs(c.X-(52*want.X+100*25)/152) > 1e-12 {
+		t.Errorf("Centroid Test failed, expected MultiPolygon centroid x %g, got: %v", (52*want.X+100*25)/152, c)
+	}
+}
+
+func TestInteriorPoint(t *testing.T) {
+	shape := Polygon{LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 2}, {X: 2, Y: 2}, {X: 2, Y: 8}, {X: 10, Y: 8}, {X: 10, Y: 10}, {X: 0, Y: 10}}}
+	holed := Polygon{LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}, LinearRing{{X: 1, Y: 1}, {X: 9, Y: 1}, {X: 9, Y: 9}, {X: 1, Y: 9}}}
+	for _, p := range []Polygon{shape, holed} {
+		if pt := p.InteriorPoint(); !polygonContains(p, pt) {
+			t.Errorf("Interior Point Test failed, %v is not inside %v", pt, p)
+		}
+	}
+
+	line := LineString{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 5, Y: 5}, {X: 10, Y: 0}}
+	if pt := line.InteriorPoint(); pt != line[1] {
+		t.Errorf("Interior Point Test failed, expected line vertex %v, got: %v", line[1], pt)
+	}
+}
+
+func TestPoleOfInaccessibility(t *testing.T) {
+	// The largest circle in the C shape touches both outer sides and the
+	// reflex corner of a junction between its bars.
+	shape := Polygon{LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 2}, {X: 2, Y: 2}, {X: 2, Y: 8}, {X: 10, Y: 8}, {X: 10, Y: 10}, {X: 0, Y: 10}}}
+	r := 2 * math.Sqrt2 / (1 + math.Sqrt2)
+	pt, d := shape.PoleOfInaccessibility(1e-3)
+	if math.Abs(d-r) > 1e-3 || !polygonContains(shape, pt) {
+		t.Errorf("Pole Of Inaccessibility Test failed, expected distance %g, got: %v %g", r, pt, d)
+	}
+
+	mp := MultiPolygon{shape, Polygon{LinearRing{{X: 20, Y: 0}, {X: 26, Y: 0}, {X: 26, Y: 6}, {X: 20, Y: 6}}}}
+	pt, d = mp.PoleOfInaccessibility(1e-3)
+	if math.Abs(pt.X-23) > 1e-2 || math.Abs(pt.Y-3) > 1e-2 || math.Abs(d-3) > 1e-3 {
+		t.Errorf("Pole Of Inaccessibility Test failed, expected (23 3) at distance 3, got: %v %g", pt, d)
+	}
+}
+
+func TestTriangulate(t *testing.T) {
+	p := Polygon{
+		LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}},
+		LinearRing{{X: 2, Y: 2}, {X: 4, Y: 2}, {X: 4, Y: 4}, {X: 2, Y: 4}},
+		LinearRing{{X: 6, Y: 6}, {X: 6, Y: 8}, {X: 8, Y: 8}, {X: 8, Y: 6}},
+	}
+	for _, method := range []TriangulationMethod{EarClipping, ConstrainedDelaunay} {
+		tr, err := p.Triangulate(method)
+		if err != nil {
+			t.Fatalf("Triangulate Test failed, error in method %d: %s", method, err)
+		}
+		if len(tr.Vertices) != 12 || len(tr.Indices) != 3*14 {
+			t.Errorf("Triangulate Test failed, expected 12 vertices and 14 triangles, got: %d %d", len(tr.Vertices), len(tr.Indices)/3)
+		}
+		area := 0.0
+		for _, tri := range tr.MultiPolygon() {
+			if !tri[0].IsCCW() {
+				t.Errorf("Triangulate Test failed, triangle %v is not counter-clockwise", tri)
+			}
+			area += signedArea(tri[0])
+		}
+		if area != 92 {
+			t.Errorf("Triangulate Test failed, expected area 92, got: %g", area)
+		}
+	}
+}
+
+func TestTriangulateDelaunay(t *testing.T) {
+	// A strip with many vertices along its long sides, which ear clipping
+	// fills with slivers.
+	ring := LinearRing{}
+	for i := 0; i <= 20; i++ {
+		ring = append(ring, Point{X: float64(i), Y: 0})
+	}
+	for i := 20; i >= 0; i-- {
+		ring = append(ring, Point{X: float64(i), Y: 2})
+	}
+	p := Polygon{ring}
+	tr, err := p.Triangulate(ConstrainedDelaunay)
+	if err != nil {
+		t.Fatalf("Triangulate Delaunay Test failed, error: %s", err)
+	}
+
+	v, idx := tr.Vertices, tr.Indices
+	boundary := map[[2]Point]bool{}
+	for i := range ring {
+		boundary[[2]Point{ring[i], ring[(i+1)%len(ring)]}] = true
+	}
+	for i := 0; i < len(idx); i += 3 {
+		for j := 0; j < len(idx); j += 3 {
+			for k := 0; k < 3; k++ {
+				a, b := idx[i+k], idx[i+(k+1)%3]
+				if boundary[[2]Point{v[a], v[b]}] {
+					continue
+				}
+				for _, d := range idx[j : j+3] {
+					if d != a && d != b && d != idx[i+(k+2)%3] && inCircle(v[a], v[b], v[idx[i+(k+2)%3]], v[d]) {
+						shared := 0
+						for _, e := range idx[j : j+3] {
+							if e == a || e == b {
+								shared++
+							}
+						}
+						if shared == 2 {
+							t.Errorf("Triangulate Delaunay Test failed, edge %v %v is not Delaunay", v[a], v[b])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDelaunayTriangulation(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	pts := []Point{}
+	for i := 0; i < 200; i++ {
+		pts = append(pts, Point{X: float64(r.Intn(40)), Y: float64(r.Intn(40))})
+	}
+	tr, err := DelaunayTriangulation(pts)
+	if err != nil {
+		t.Fatalf("Delaunay Triangulation Test failed, error: %s", err)
+	}
+	idx := tr.Indices
+	for i := 0; i < len(idx); i += 3 {
+		a, b, c := pts[idx[i]], pts[idx[i+1]], pts[idx[i+2]]
+		if orient(a, b, c) <= 0 {
+			t.Fatalf("Delaunay Triangulation Test failed, triangle %v %v %v is not counter-clockwise", a, b, c)
+		}
+		for _, p := range pts {
+			if inCircle(a, b, c, p) {
+				t.Fatalf("Delaunay Triangulation Test failed, %v inside circumcircle of %v %v %v", p, a, b, c)
+			}
+		}
+	}
+
+	if _, err := DelaunayTriangulation([]Point{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 2}}); err == nil {
+		t.Errorf("Delaunay Triangulation Test failed, expected error for collinear points")
+	}
+}
+
+func TestVoronoiDiagram(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	pts := []Point{}
+	for i := 0; i < 100; i++ {
+		pts = append(pts, Point{X: r.Float64() * 100, Y: r.Float64() * 100})
+	}
+	pts = append(pts, pts[0])
+	clip := NewGrid(0, 100, 1, 1, 100, 100)
+	env := Bounds{0, 0, 100, 100}.AsPolygon()
+	cells, err := VoronoiDiagram(pts, &env)
+	if err != nil || len(cells) != len(pts) {
+		t.Fatalf("Voronoi Diagram Test failed, expected %d cells, got: %d %v", len(pts), len(cells), err)
+	}
+	if !cells[len(pts)-1].Equals(cells[0]) {
+		t.Errorf("Voronoi Diagram Test failed, repeated point has a different cell")
+	}
+	area := 0.0
+	for i, c := range cells[:len(pts)-1] {
+		area += multiPolygonArea(c)
+		if len(c) != 1 || !polygonContains(c[0], pts[i]) {
+			t.Errorf("Voronoi Diagram Test failed, cell %d does not hold its point", i)
+		}
+		// Pixel centres in the cell are no closer to any other point.
+		mask, _ := clip.Rasterize(&c, CentrePoint)
+		for k, in := range mask {
+			if !in {
+				continue
+			}
+			px := Point{X: float64(k%100) + 0.5, Y: 99.5 - float64(k/100)}
+			own := math.Hypot(px.X-pts[i].X, px.Y-pts[i].Y)
+			for _, q := range pts {
+				if math.Hypot(px.X-q.X, px.Y-q.Y) < own-1e-9 {
+					t.Fatalf("Voronoi Diagram Test failed, %v in cell %d is closer to %v", px, i, q)
+				}
+			}
+		}
+	}
+	if math.Abs(area-10000) > 1e-6 {
+		t.Errorf("Voronoi Diagram Test failed, expected cells to cover 10000, got: %g", area)
+	}
+
+	// Cells clipped to a C shape may be split in two.
+	shape := Polygon{LinearRing{{X: 0, Y: 0}, {X: 50, Y: 0}, {X: 50, Y: 10}, {X: 10, Y: 10}, {X: 10, Y: 40}, {X: 50, Y: 40}, {X: 50, Y: 50}, {X: 0, Y: 50}}}
+	cells, _ = VoronoiDiagram([]Point{{X: 5, Y: 25}, {X: 45, Y: 25}}, &shape)
+	if len(cells[0]) != 1 || multiPolygonArea(cells[0]) != 800 || len(cells[1]) != 2 || multiPolygonArea(cells[1]) != 500 {
+		t.Errorf("Voronoi Diagram Test failed, expected C shape cells of 800 and two parts of 500, got: %v", cells)
+	}
+}
+
+func TestMultiPoint(t *testing.T) {
+	mp := MultiPoint{{X: 4, Y: 9.5}, {X: 2, Y: 9.5}}
+	if wkt := mp.MarshalWKT(); wkt != "MULTIPOINT ((4 9.5),(2 9.5))" {
+		t.Errorf("MultiPoint Test failed, unexpected WKT: %s", wkt)
+	}
+	for _, in := range []string{"MULTIPOINT ((4 9.5),(2 9.5))", "MULTIPOINT (4 9.5, 2 9.5)"} {
+		var out MultiPoint
+		if err := out.UnmarshalWKT(in); err != nil || !out.Equals(mp) {
+			t.Errorf("MultiPoint Test failed, expected %v from %s, got: %v %v", mp, in, out, err)
+		}
+	}
+
+	var wkbOut MultiPoint
+	if err := wkbOut.UnmarshalWKB(mp.MarshalWKB(1)); err != nil || !wkbOut.Equals(mp) {
+		t.Errorf("MultiPoint Test failed, WKB round trip gave: %v %v", wkbOut, err)
+	}
+
+	out, _ := json.Marshal(Feature{Type: "Feature", Geometry: &mp})
+	var f Feature
+	if err := json.Unmarshal(out, &f); err != nil {
+		t.Fatalf("MultiPoint Test failed, error in JSON: %s", err)
+	}
+	if got, ok := f.Geometry.(*MultiPoint); !ok || !got.Equals(mp) {
+		t.Errorf("MultiPoint Test failed, JSON round trip gave: %v", f.Geometry)
+	}
+}
+
+func TestShapefile(t *testing.T) {
+	shell := LinearRing{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}
+	hole := LinearRing{{X: 2, Y: 2}, {X: 2, Y: 4}, {X: 4, Y: 4}, {X: 4, Y: 2}}
+	far := LinearRing{{X: 20, Y: 0}, {X: 20, Y: 5}, {X: 25, Y: 5}}
+	p1 := Polygon{shell, hole}
+	p2 := MultiPolygon{Polygon{shell.Reverse()}, Polygon{far}}
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{
+		{Type: "Feature", Geometry: &p1, Properties: map[string]interface{}{"name": "first", "area": 96.0, "count": 3, "ok": true}},
+		{Type: "Feature", Geometry: &p2, Properties: map[string]interface{}{"name": "second", "area": 112.5, "count": 12345}},
+		{Type: "Feature", Properties: map[string]interface{}{"name": "empty"}},
+	}}
+
+	path := t.TempDir() + "/test.shp"
+	if err := WriteShapefile(path, fc, `GEOGCS["GDA94",DATUM["GDA94",SPHEROID["GRS 1980",6378137,298.257222101]],PRIMEM["Greenwich",0],UNIT["degree",0.0174532925199433]]`); err != nil {
+		t.Fatalf("Shapefile Test failed, error writing: %s", err)
+	}
+	s, err := ReadShapefile(path)
+	if err != nil {
+		t.Fatalf("Shapefile Test failed, error reading: %s", err)
+	}
+	if s.Type != PolygonShape || len(s.Features) != 3 || s.Bounds != (Bounds{0, 0, 25, 10}) {
+		t.Fatalf("Shapefile Test failed, unexpected header: %d %d %v", s.Type, len(s.Features), s.Bounds)
+	}
+
+	got, ok := s.Features[0].Geometry.(*Polygon)
+	if !ok || len(*got) != 2 || !(*got)[0].EqualsTopo(shell, 0) || !(*got)[1].EqualsTopo(hole, 0) || !(*got)[0].IsCCW() || !(*got)[1].IsCW() {
+		t.Errorf("Shapefile Test failed, expected polygon with hole, got: %v", s.Features[0].Geometry)
+	}
+	gotMulti, ok := s.Features[1].Geometry.(*MultiPolygon)
+	if !ok || !gotMulti.EqualsTopo(p2, 0) {
+		t.Errorf("Shapefile Test failed, expected MultiPolygon, got: %v", s.Features[1].Geometry)
+	}
+	if s.Features[2].Geometry != nil {
+		t.Errorf("Shapefile Test failed, expected null shape, got: %v", s.Features[2].Geometry)
+	}
+
+	props := s.Features[1].Properties
+	if props["name"] != "second" || props["area"] != 112.5 || props["count"] != 12345.0 || props["ok"] != nil {
+		t.Errorf("Shapefile Test failed, unexpected properties: %v", props)
+	}
+	if s.Features[0].Properties["ok"] != true || s.Features[0].Properties["area"] != 96.0 {
+		t.Errorf("Shapefile Test failed, unexpected properties: %v", s.Features[0].Properties)
+	}
+
+	crs, err := s.CRS()
+	if err != nil || !crs.IsGeographic() || crs.Datum.Name != GDA94Datum.Name {
+		t.Errorf("Shapefile Test failed, unexpected CRS: %+v %v", crs, err)
+	}
+
+	// Mark the first record deleted and write the second name in Windows
+	// code page 1252.
+	shp, _ := os.ReadFile(path)
+	table, _ := os.ReadFile(strings.TrimSuffix(path, ".shp") + ".dbf")
+	headerSize := int(binary.LittleEndian.Uint16(table[8:]))
+	recordSize := int(binary.LittleEndian.Uint16(table[10:]))
+	table[headerSize] = '*'
+	second := table[headerSize+recordSize : headerSize+2*recordSize]
+	copy(second[bytes.Index(second, []byte("second")):], "caf\xe9\x80 ")
+	s, err = DecodeShapefile(bytes.NewReader(shp), bytes.NewReader(table), "1252")
+	if err != nil {
+		t.Fatalf("Shapefile Test failed, error decoding: %s", err)
+	}
+	if len(s.Features) != 2 || s.Features[0].Properties["name"] != "café€" || s.Features[1].Properties["name"] != "empty" {
+		t.Errorf("Shapefile Test failed, expected deleted record dropped and text decoded, got: %+v", s.Features)
+	}
+	if _, ok := s.Features[0].Geometry.(*MultiPolygon); !ok {
+		t.Errorf("Shapefile Test failed, expected the shape of the second record, got: %v", s.Features[0].Geometry)
+	}
+	if _, err := DecodeShapefile(bytes.NewReader(shp), bytes.NewReader(table), "EBCDIC"); err == nil {
+		t.Errorf("Shapefile Test failed, expected error for unsupported code page")
+	}
+
+	// Text longer than a field is cut without splitting a character.
+	long := "a" + strings.Repeat("é", 200)
+	fc = FeatureCollection{Type: "FeatureCollection", Features: []Feature{
+		{Type: "Feature", Geometry: &p1, Properties: map[string]interface{}{"name": long}},
+	}}
+	shpBuf, dbfBuf := new(bytes.Buffer), new(bytes.Buffer)
+	if err := EncodeShapefile(fc, shpBuf, new(bytes.Buffer), dbfBuf); err != nil {
+		t.Fatalf("Shapefile Test failed, error writing: %s", err)
+	}
+	s, err = DecodeShapefile(shpBuf, dbfBuf, "")
+	if err != nil {
+		t.Fatalf("Shapefile Test failed, error decoding: %s", err)
+	}
+	if name, _ := s.Features[0].Properties["name"].(string); name != long[:253] || !utf8.ValidString(name) {
+		t.Errorf("Shapefile Test failed, expected text cut to 253 bytes, got: %q", name)
+	}
+}
+
+func TestShapefileLines(t *testing.T) {
+	line := LineString{{X: 0, Y: 0, Z: 1}, {X: 1, Y: 1, Z: 2}}
+	multi := MultiLineString{{{X: 0, Y: 0}, {X: 1, Y: 0}}, {{X: 2, Y: 2, Z: 5}, {X: 3, Y: 3, Z: 6}}}
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{
+		{Type: "Feature", Geometry: &line}, {Type: "Feature", Geometry: &multi},
+	}}
+	shp, shx, dbf := new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer)
+	if err := EncodeShapefile(fc, shp, shx, dbf); err != nil {
+		t.Fatalf("Shapefile Lines Test failed, error writing: %s", err)
+	}
+	if shx.Len() != 100+8*2 {
+		t.Errorf("Shapefile Lines Test failed, unexpected index length: %d", shx.Len())
+	}
+	s, err := DecodeShapefile(shp, dbf, "")
+	if err != nil {
+		t.Fatalf("Shapefile Lines Test failed, error reading: %s", err)
+	}
+	if s.Type != PolyLineZShape {
+		t.Errorf("Shapefile Lines Test failed, expected PolyLineZ, got: %d", s.Type)
+	}
+	if got, ok := s.Features[0].Geometry.(*LineString); !ok || !got.Equals(line) {
+		t.Errorf("Shapefile Lines Test failed, expected %v, got: %v", line, s.Features[0].Geometry)
+	}
+	if got, ok := s.Features[1].Geometry.(*MultiLineString); !ok || !got.Equals(multi) {
+		t.Errorf("Shapefile Lines Test failed, expected %v, got: %v", multi, s.Features[1].Geometry)
+	}
+
+	pt := Point{X: 1, Y: 2}
+	fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: &pt})
+	if err := EncodeShapefile(fc, shp, shx, dbf); err == nil {
+		t.Errorf("Shapefile Lines Test failed, expected error mixing shape types")
+	}
+
+	// A MultiPatch record must not be read as the Point its type code ends
+	// in.
+	fc.Features = fc.Features[2:]
+	shp.Reset()
+	if err := EncodeShapefile(fc, shp, new(bytes.Buffer), new(bytes.Buffer)); err != nil {
+		t.Fatalf("Shapefile Lines Test failed, error writing: %s", err)
+	}
+	patch := shp.Bytes()
+	binary.LittleEndian.PutUint32(patch[108:], 31)
+	if _, err := DecodeShapefile(bytes.NewReader(patch), nil, ""); err == nil || !strings.Contains(err.Error(), "unsupported shape type 31") {
+		t.Errorf("Shapefile Lines Test failed, expected MultiPatch to be unsupported, got: %v", err)
+	}
+}
+
+func TestGeoPackageGeometry(t *testing.T) {
+	poly := Polygon{{{X: 1, Y: 2, Z: 3}, {X: 5, Y: 2, Z: 4}, {X: 5, Y: 7, Z: 3}}}
+	for _, mode := range []uint8{0, 1} {
+		for _, env := range []GeoPackageEnvelope{NoEnvelope, EnvelopeXY, EnvelopeXYZ} {
+			in := GeoPackageGeometry{Geometry: &poly, SRID: 4326, Envelope: env}
+			enc, err := in.MarshalGPKG(mode)
+			if err != nil {
+				t.Fatalf("GeoPackage Test failed, error encoding: %s", err)
+			}
+			if in.Bounds != (Bounds{}) || in.Empty {
+				t.Errorf("GeoPackage Test failed, encoding changed the geometry: %+v", in)
+			}
+			if header := 8 + []int{0, 32, 48}[env]; endian[mode].Uint32(enc[header+1:]) != 1003 {
+				t.Errorf("GeoPackage Test failed, expected Polygon Z WKB after %d byte header", header)
+			}
+
+			var out GeoPackageGeometry
+			if err := out.UnmarshalGPKG(enc); err != nil {
+				t.Fatalf("GeoPackage Test failed, error decoding: %s", err)
+			}
+			got, ok := out.Geometry.(*Polygon)
+			if !ok || !got.Equals(poly) || out.SRID != 4326 || out.Envelope != env || out.Empty {
+				t.Errorf("GeoPackage Test failed, unexpected round trip: %+v", out)
+			}
+			if env != NoEnvelope && out.Bounds != (Bounds{1, 2, 5, 7}) {
+				t.Errorf("GeoPackage Test failed, unexpected envelope: %v", out.Bounds)
+			}
+			if env == EnvelopeXYZ && (out.MinZ != 3 || out.MaxZ != 4) {
+				t.Errorf("GeoPackage Test failed, unexpected Z range: %v %v", out.MinZ, out.MaxZ)
+			}
+		}
+	}
+
+	// Blobs laid out by hand following the GeoPackage and ISO WKB
+	// specifications.
+	fixtures := []struct {
+		hex    string
+		geom   Geometry
+		env    GeoPackageEnvelope
+		encode bool
+	}{
+		// POINT (1 2), SRID 4326, little endian.
+		{"47500001e6100000" + "0101000000" + "000000000000f03f" + "0000000000000040", &Point{X: 1, Y: 2}, NoEnvelope, true},
+		// LINESTRING Z (1 2 3, 4 5 6), SRID 4326, big endian with an XY envelope.
+		{"47500002000010e6" + "3ff0000000000000" + "4010000000000000" + "4000000000000000" + "4014000000000000" +
+			"00000003ea00000002" + "3ff0000000000000" + "4000000000000000" + "4008000000000000" +
+			"4010000000000000" + "4014000000000000" + "4018000000000000",
+			&LineString{{X: 1, Y: 2, Z: 3}, {X: 4, Y: 5, Z: 6}}, EnvelopeXY, true},
+		// MULTIPOINT ((1 2), (3 4)) with parts of different byte order.
+		{"4750000100000000" + "010400000002000000" + "0101000000" + "000000000000f03f" + "0000000000000040" +
+			"0000000001" + "4008000000000000" + "4010000000000000",
+			&MultiPoint{{X: 1, Y: 2}, {X: 3, Y: 4}}, NoEnvelope, false},
+		// POINT M (1 2 9), the M value being dropped.
+		{"4750000100000000" + "01d1070000" + "000000000000f03f" + "0000000000000040" + "0000000000002240", &Point{X: 1, Y: 2}, NoEnvelope, false},
+	}
+	for _, f := range fixtures {
+		blob, _ := hex.DecodeString(f.hex)
+		var out GeoPackageGeometry
+		if err := out.UnmarshalGPKG(blob); err != nil {
+			t.Errorf("GeoPackage Test failed, error decoding %s: %s", f.hex, err)
+			continue
+		}
+		if !reflect.DeepEqual(out.Geometry, f.geom) || out.Envelope != f.env {
+			t.Errorf("GeoPackage Test failed, expected %+v, got: %+v", f.geom, out.Geometry)
+		}
+		if !f.encode {
+			continue
+		}
+		in := GeoPackageGeometry{Geometry: f.geom, SRID: out.SRID, Envelope: f.env}
+		if enc, err := in.MarshalGPKG(blob[3] & 1); err != nil || !bytes.Equal(enc, blob) {
+			t.Errorf("GeoPackage Test failed, expected %s, got: %x %v", f.hex, enc, err)
+		}
+	}
+
+	empty := GeoPackageGeometry{Geometry: &MultiPolygon{}, Envelope: EnvelopeXY}
+	v, err := empty.Value()
+	if err != nil {
+		t.Fatalf("GeoPackage Test failed, error encoding empty geometry: %s", err)
+	}
+	if enc := v.([]byte); enc[3] != 0x11 {
+		t.Errorf("GeoPackage Test failed, expected empty flag without envelope, got flags %#x", enc[3])
+	}
+	var out GeoPackageGeometry
+	if err := out.Scan(v); err != nil || !out.Empty {
+		t.Errorf("GeoPackage Test failed, expected empty geometry, got: %+v %v", out, err)
+	}
+	if err := out.Scan([]byte("GP\x00\x21\x00\x00\x00\x00")); err == nil {
+		t.Errorf("GeoPackage Test failed, expected error for extended geometry type")
+	}
+}
+
+func TestKML(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+<Document>
+  <name>Survey</name>
+  <Folder>
+    <name>Sites</name>
+    <Placemark>
+      <name>Camp</name>
+      <description>Base camp</description>
+      <ExtendedData>
+        <Data name="team"><value>North</value></Data>
+        <SchemaData schemaUrl="#s"><SimpleData name="elev">612</SimpleData></SchemaData>
+      </ExtendedData>
+      <Point><coordinates>149.1,-35.3,612</coordinates></Point>
+    </Placemark>
+    <Folder>
+      <name>Tracks</name>
+      <Placemark>
+        <LineString><coordinates>
+          149.1, -35.3 149.2 ,-35.4
+        </coordinates></LineString>
+      </Placemark>
+    </Folder>
+  </Folder>
+  <Placemark>
+    <name>Paddock</name>
+    <Polygon>
+      <outerBoundaryIs><LinearRing><coordinates>0,0 10,0 10,10 0,10 0,0</coordinates></LinearRing></outerBoundaryIs>
+      <innerBoundaryIs><LinearRing><coordinates>2,2 2,4 4,4 2,2</coordinates></LinearRing></innerBoundaryIs>
+    </Polygon>
+  </Placemark>
+  <Placemark>
+    <MultiGeometry>
+      <Point><coordinates>1,2</coordinates></Point>
+      <MultiGeometry><Point><coordinates>3,4</coordinates></Point></MultiGeometry>
+    </MultiGeometry>
+  </Placemark>
+</Document>
+</kml>`
+
+	fc, err := DecodeKML(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("KML Test failed, error decoding: %s", err)
+	}
+	if len(fc.Features) != 4 {
+		t.Fatalf("KML Test failed, expected 4 features, got: %d", len(fc.Features))
+	}
+
+	props := fc.Features[0].Properties
+	if props["name"] != "Camp" || props["description"] != "Base camp" || props["team"] != "North" || props["elev"] != "612" || props["folder"] != "Sites" {
+		t.Errorf("KML Test failed, unexpected properties: %v", props)
+	}
+	if p, ok := fc.Features[0].Geometry.(*Point); !ok || !p.Equals(Point{149.1, -35.3, 612}) {
+		t.Errorf("KML Test failed, unexpected Point: %v", fc.Features[0].Geometry)
+	}
+	if l, ok := fc.Features[1].Geometry.(*LineString); !ok || !l.Equals(LineString{{X: 149.1, Y: -35.3}, {X: 149.2, Y: -35.4}}) || fc.Features[1].Properties["folder"] != "Sites/Tracks" {
+		t.Errorf("KML Test failed, unexpected LineString feature: %v %v", fc.Features[1].Geometry, fc.Features[1].Properties)
+	}
+	poly := Polygon{{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}, {{X: 2, Y: 2}, {X: 2, Y: 4}, {X: 4, Y: 4}}}
+	if p, ok := fc.Features[2].Geometry.(*Polygon); !ok || !p.Equals(poly) {
+		t.Errorf("KML Test failed, unexpected Polygon: %v", fc.Features[2].Geometry)
+	}
+	if _, ok := fc.Features[2].Properties["folder"]; ok {
+		t.Errorf("KML Test failed, unexpected folder outside Folder")
+	}
+	if m, ok := fc.Features[3].Geometry.(*MultiPoint); !ok || !m.Equals(MultiPoint{{X: 1, Y: 2}, {X: 3, Y: 4}}) {
+		t.Errorf("KML Test failed, unexpected MultiGeometry: %v", fc.Features[3].Geometry)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := EncodeKML(buf, fc); err != nil {
+		t.Fatalf("KML Test failed, error encoding: %s", err)
+	}
+	again, err := DecodeKML(buf)
+	if err != nil {
+		t.Fatalf("KML Test failed, error decoding encoded KML: %s", err)
+	}
+	for i, f := range fc.Features {
+		if !reflect.DeepEqual(f, again.Features[i]) {
+			t.Errorf("KML Test failed, expected %v after round trip, got: %v", f, again.Features[i])
+		}
+	}
+
+	mixed := `<kml><Placemark><MultiGeometry><Point><coordinates>1,2</coordinates></Point><LineString><coordinates>1,2 3,4</coordinates></LineString></MultiGeometry></Placemark></kml>`
+	if _, err := DecodeKML(strings.NewReader(mixed)); err == nil {
+		t.Errorf("KML Test failed, expected error for mixed MultiGeometry")
+	}
+}
+
+func TestGML(t *testing.T) {
+	poly := Polygon{{{X: 149, Y: -35}, {X: 150, Y: -35}, {X: 150, Y: -34}}, {{X: 149.2, Y: -34.9}, {X: 149.2, Y: -34.8}, {X: 149.4, Y: -34.8}}}
+	out, err := MarshalGML(&poly, GMLOptions{SRSName: "urn:ogc:def:crs:EPSG::4326", ID: "p"})
+	if err != nil {
+		t.Fatalf("GML Test failed, error encoding: %s", err)
+	}
+	expected := `<gml:Polygon xmlns:gml="http://www.opengis.net/gml/3.2" gml:id="p" srsName="urn:ogc:def:crs:EPSG::4326">` +
+		`<gml:exterior><gml:LinearRing gml:id="p.1"><gml:posList>-35 149 -35 150 -34 150 -35 149</gml:posList></gml:LinearRing></gml:exterior>` +
+		`<gml:interior><gml:LinearRing gml:id="p.2"><gml:posList>-34.9 149.2 -34.8 149.2 -34.8 149.4 -34.9 149.2</gml:posList></gml:LinearRing></gml:interior></gml:Polygon>`
+	if string(out) != expected {
+		t.Errorf("GML Test failed, expected %s, got: %s", expected, out)
+	}
+	g, srsName, err := UnmarshalGML(out, GMLOptions{})
+	if err != nil || srsName != "urn:ogc:def:crs:EPSG::4326" {
+		t.Fatalf("GML Test failed, error decoding: %v %s", err, srsName)
+	}
+	if p, ok := g.(*Polygon); !ok || !p.Equals(poly) {
+		t.Errorf("GML Test failed, expected %v, got: %v", poly, g)
+	}
+	if g, _, _ = UnmarshalGML(out, GMLOptions{AxisOrder: AxisXY}); g.(*Polygon).Equals(poly) {
+		t.Errorf("GML Test failed, expected axes left in file order")
+	}
+
+	etrs := `<gml:Point xmlns:gml="http://www.opengis.net/gml/3.2" srsName="urn:ogc:def:crs:EPSG::4258"><gml:pos>52 13</gml:pos></gml:Point>`
+	if g, _, err := UnmarshalGML([]byte(etrs), GMLOptions{AxisOrder: AxisYX}); err != nil || *g.(*Point) != (Point{X: 13, Y: 52}) {
+		t.Errorf("GML Test failed, expected ETRS89 latitude first, got: %v %v", g, err)
+	}
+
+	ms := `<gml:MultiSurface xmlns:gml="http://www.opengis.net/gml/3.2" srsName="EPSG:28355" srsDimension="3">
+	  <gml:surfaceMember><gml:Polygon><gml:exterior><gml:LinearRing>
+	    <gml:posList>0 0 1 10 0 2 10 10 3 0 0 1</gml:posList>
+	  </gml:LinearRing></gml:exterior></gml:Polygon></gml:surfaceMember>
+	  <gml:surfaceMembers><gml:Polygon><gml:exterior><gml:LinearRing>
+	    <gml:pos>20 0 0</gml:pos><gml:pos>30 0 0</gml:pos><gml:pos>30 10 0</gml:pos><gml:pos>20 0 0</gml:pos>
+	  </gml:LinearRing></gml:exterior></gml:Polygon></gml:surfaceMembers>
+	</gml:MultiSurface>`
+	g, srsName, err = UnmarshalGML([]byte(ms), GMLOptions{})
+	expectedMulti := MultiPolygon{{{{0, 0, 1}, {10, 0, 2}, {10, 10, 3}}}, {{{20, 0, 0}, {30, 0, 0}, {30, 10, 0}}}}
+	if m, ok := g.(*MultiPolygon); err != nil || !ok || !m.Equals(expectedMulti) || srsName != "EPSG:28355" {
+		t.Errorf("GML Test failed, expected %v, got: %v %v", expectedMulti, g, err)
+	}
+
+	for _, geom := range []Geometry{&Point{X: 1, Y: 2, Z: 3}, &LineString{{X: 1, Y: 2}, {X: 3, Y: 4}}, &MultiPoint{{X: 1, Y: 2}},
+		&MultiLineString{{{X: 1, Y: 2}, {X: 3, Y: 4}}}, &expectedMulti} {
+		out, err := MarshalGML(geom, GMLOptions{SRSName: "http://www.opengis.net/def/crs/EPSG/0/28355"})
+		if err != nil {
+			t.Fatalf("GML Test failed, error encoding %T: %s", geom, err)
+		}
+		g, _, err := UnmarshalGML(out, GMLOptions{})
+		if err != nil || !reflect.DeepEqual(g, geom) {
+			t.Errorf("GML Test failed, expected %v, got: %v %v", geom, g, err)
+		}
+	}
+
+	if _, _, err := UnmarshalGML([]byte(`<gml:MultiCurve xmlns:gml="http://www.opengis.net/gml/3.2"><gml:curveMember><gml:Point><gml:pos>1 2</gml:pos></gml:Point></gml:curveMember></gml:MultiCurve>`), GMLOptions{}); err == nil {
+		t.Errorf("GML Test failed, expected error for Point in MultiCurve")
+	}
+}
+
+func TestGPX(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="GPS unit" xmlns="http://www.topografix.com/GPX/1/1">
+  <wpt lat="-35.28" lon="149.13"><ele>578.5</ele><time>2024-03-01T01:02:03Z</time><name>Camp</name></wpt>
+  <rte><name>Out</name><rtept lat="-35.28" lon="149.13"/><rtept lat="-35.29" lon="149.14"/></rte>
+  <trk><name>Walk</name>
+    <trkseg>
+      <trkpt lat="-35.28" lon="149.13"><ele>578</ele><time>2024-03-01T01:00:00Z</time></trkpt>
+      <trkpt lat="-35.281" lon="149.131"><ele>580</ele><time>2024-03-01T01:00:10Z</time></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="-35.282" lon="149.132"><ele>581</ele></trkpt>
+      <trkpt lat="-35.283" lon="149.133"><ele>0</ele></trkpt>
+      <trkpt lat="-35.284" lon="149.134"/>
+    </trkseg>
+  </trk>
+</gpx>`
+
+	fc, err := DecodeGPX(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("GPX Test failed, error decoding: %s", err)
+	}
+	if len(fc.Features) != 3 {
+		t.Fatalf("GPX Test failed, expected 3 features, got: %d", len(fc.Features))
+	}
+	if p, ok := fc.Features[0].Geometry.(*Point); !ok || !p.Equals(Point{149.13, -35.28, 578.5}) || fc.Features[0].Properties["name"] != "Camp" {
+		t.Errorf("GPX Test failed, unexpected waypoint: %v %v", fc.Features[0].Geometry, fc.Features[0].Properties)
+	}
+	if fc.Features[0].Properties["time"] != "2024-03-01T01:02:03Z" || fc.Features[0].Properties["has_ele"] != true {
+		t.Errorf("GPX Test failed, unexpected waypoint properties: %v", fc.Features[0].Properties)
+	}
+	if l, ok := fc.Features[1].Geometry.(*LineString); !ok || len(*l) != 2 || fc.Features[1].Properties["time"] != nil || fc.Features[1].Properties["has_ele"] != nil {
+		t.Errorf("GPX Test failed, unexpected route: %v %v", fc.Features[1].Geometry, fc.Features[1].Properties)
+	}
+	trk, ok := fc.Features[2].Geometry.(*MultiLineString)
+	if !ok || len(*trk) != 2 || (*trk)[0][1] != (Point{149.131, -35.281, 580}) {
+		t.Errorf("GPX Test failed, unexpected track: %v", fc.Features[2].Geometry)
+	}
+	times := []interface{}{[]interface{}{"2024-03-01T01:00:00Z", "2024-03-01T01:00:10Z"}, []interface{}{nil, nil, nil}}
+	if !reflect.DeepEqual(fc.Features[2].Properties["time"], times) {
+		t.Errorf("GPX Test failed, unexpected track times: %v", fc.Features[2].Properties["time"])
+	}
+	eles := []interface{}{[]interface{}{true, true}, []interface{}{true, true, false}}
+	if !reflect.DeepEqual(fc.Features[2].Properties["has_ele"], eles) {
+		t.Errorf("GPX Test failed, unexpected elevation presence: %v", fc.Features[2].Properties["has_ele"])
+	}
+
+	// The properties survive a GeoJSON round trip.
+	js, err := json.Marshal(fc)
+	if err != nil {
+		t.Fatalf("GPX Test failed, error encoding GeoJSON: %s", err)
+	}
+	var viaJSON FeatureCollection
+	if err := json.Unmarshal(js, &viaJSON); err != nil {
+		t.Fatalf("GPX Test failed, error decoding GeoJSON: %s", err)
+	}
+	for i, f := range viaJSON.Features {
+		if !reflect.DeepEqual(f.Properties, fc.Features[i].Properties) {
+			t.Errorf("GPX Test failed, expected %v after GeoJSON, got: %v", fc.Features[i].Properties, f.Properties)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := EncodeGPX(buf, viaJSON); err != nil {
+		t.Fatalf("GPX Test failed, error encoding: %s", err)
+	}
+	if n := strings.Count(buf.String(), "<ele>0</ele>"); n != 1 {
+		t.Errorf("GPX Test failed, expected the sea level elevation written once, got %d", n)
+	}
+	again, err := DecodeGPX(buf)
+	if err != nil {
+		t.Fatalf("GPX Test failed, error decoding encoded GPX: %s", err)
+	}
+	if !reflect.DeepEqual(fc, again) {
+		t.Errorf("GPX Test failed, expected %v after round trip, got: %v", fc, again)
+	}
+
+	poly := Polygon{{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}}}
+	if err := EncodeGPX(buf, FeatureCollection{Features: []Feature{{Geometry: &poly}}}); err == nil {
+		t.Errorf("GPX Test failed, expected error encoding Polygon")
+	}
+}
+
+func TestTopoJSON(t *testing.T) {
+	// Two squares sharing an edge, the second with a hole holding an
+	// island, and a line along the shared edge.
+	left := Polygon{{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}}
+	right := Polygon{{{X: 10, Y: 0}, {X: 20, Y: 0}, {X: 20, Y: 10}, {X: 10, Y: 10}}, {{X: 12, Y: 2}, {X: 12, Y: 4}, {X: 14, Y: 4}, {X: 14, Y: 2}}}
+	island := MultiPolygon{{{{X: 12, Y: 2}, {X: 14, Y: 2}, {X: 14, Y: 4}, {X: 12, Y: 4}}}}
+	pt := Point{X: 5, Y: 5}
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{
+		{Type: "Feature", Geometry: &left, Properties: map[string]interface{}{"name": "left"}},
+		{Type: "Feature", Geometry: &right, Properties: map[string]interface{}{"name": "right"}},
+		{Type: "Feature", Geometry: &island},
+		{Type: "Feature", Geometry: &pt},
+		{Type: "Feature", Properties: map[string]interface{}{"name": "none"}},
+	}}
+
+	for _, q := range []int{0, 41} {
+		out, err := MarshalTopoJSON(fc, q)
+		if err != nil {
+			t.Fatalf("TopoJSON Test failed, error encoding: %s", err)
+		}
+		var raw struct {
+			Arcs [][][]float64 `json:"arcs"`
+		}
+		json.Unmarshal(out, &raw)
+		// The shared edge, the rest of each square and the hole.
+		if len(raw.Arcs) != 4 {
+			t.Errorf("TopoJSON Test failed, expected 4 arcs, got: %v", raw.Arcs)
+		}
+
+		objects, err := UnmarshalTopoJSON(out)
+		if err != nil {
+			t.Fatalf("TopoJSON Test failed, error decoding: %s", err)
+		}
+		got := objects["features"].Features
+		if len(got) != len(fc.Features) {
+			t.Fatalf("TopoJSON Test failed, expected %d features, got: %d", len(fc.Features), len(got))
+		}
+		if p, ok := got[0].Geometry.(*Polygon); !ok || !p.EqualsTopo(left, 0) || got[0].Properties["name"] != "left" {
+			t.Errorf("TopoJSON Test failed, expected %v, got: %v", left, got[0].Geometry)
+		}
+		if p, ok := got[1].Geometry.(*Polygon); !ok || !p.EqualsTopo(right, 0) {
+			t.Errorf("TopoJSON Test failed, expected %v, got: %v", right, got[1].Geometry)
+		}
+		if m, ok := got[2].Geometry.(*MultiPolygon); !ok || !m.EqualsTopo(island, 0) {
+			t.Errorf("TopoJSON Test failed, expected %v, got: %v", island, got[2].Geometry)
+		}
+		if p, ok := got[3].Geometry.(*Point); !ok || !p.Equals(pt) {
+			t.Errorf("TopoJSON Test failed, expected %v, got: %v", pt, got[3].Geometry)
+		}
+		if got[4].Geometry != nil || got[4].Properties["name"] != "none" {
+			t.Errorf("TopoJSON Test failed, expected null geometry, got: %v", got[4])
+		}
+	}
+
+	line := LineString{{X: 0, Y: 0}, {X: 0.5, Y: 0.25}, {X: 1, Y: 1}}
+	out, _ := MarshalTopoJSON(FeatureCollection{Features: []Feature{{Geometry: &line}}}, 5)
+	objects, _ := UnmarshalTopoJSON(out)
+	expected := LineString{{X: 0, Y: 0}, {X: 0.5, Y: 0.25}, {X: 1, Y: 1}}
+	if l, ok := objects["features"].Features[0].Geometry.(*LineString); !ok || !l.Equals(expected) {
+		t.Errorf("TopoJSON Test failed, expected quantized %v, got: %v", expected, objects["features"].Features[0].Geometry)
+	}
+}
+
+func TestMVT(t *testing.T) {
+	poly := Polygon{{{X: -90, Y: 20}, {X: -45, Y: 20}, {X: -45, Y: 40}, {X: -90, Y: 40}}, {{X: -80, Y: 25}, {X: -80, Y: 30}, {X: -70, Y: 30}, {X: -70, Y: 25}}}
+	line := LineString{{X: -20, Y: 10}, {X: 20, Y: 10}}
+	pts := MultiPoint{{X: -100, Y: 50}, {X: 100, Y: 50}}
+	far := Point{X: 90, Y: -45}
+	layers := []MVTLayer{{Name: "test", Features: []Feature{
+		{Geometry: &poly, Properties: map[string]interface{}{"name": "block", "pop": 12, "area": 1.5, "ok": true, "delta": -3, "count": uint8(7)}},
+		{Geometry: &line, Properties: map[string]interface{}{"name": "road"}},
+		{Geometry: &pts, Properties: map[string]interface{}{"name": "block"}},
+		{Geometry: &far},
+	}}}
+
+	// Tile 1/0/0 is the north west quarter of the world.
+	enc, err := EncodeMVT(layers, 1, 0, 0, 64)
+	if err != nil {
+		t.Fatalf("MVT Test failed, error encoding: %s", err)
+	}
+	dec, err := DecodeMVT(enc, 1, 0, 0)
+	if err != nil {
+		t.Fatalf("MVT Test failed, error decoding: %s", err)
+	}
+	if len(dec) != 1 || dec[0].Name != "test" || dec[0].Extent != 4096 || len(dec[0].Features) != 3 {
+		t.Fatalf("MVT Test failed, unexpected layers: %+v", dec)
+	}
+	feats := dec[0].Features
+
+	props := feats[0].Properties
+	if props["name"] != "block" || props["pop"] != int64(12) || props["area"] != 1.5 || props["ok"] != true || props["delta"] != int64(-3) || props["count"] != uint64(7) {
+		t.Errorf("MVT Test failed, unexpected properties: %v", props)
+	}
+	p, ok := feats[0].Geometry.(*Polygon)
+	if !ok || len(*p) != 2 || !(*p)[0].IsCCW() || !(*p)[1].IsCW() {
+		t.Fatalf("MVT Test failed, expected Polygon with hole, got: %v", feats[0].Geometry)
+	}
+	// A tile unit is 180/4096 degrees of longitude.
+	for i, r := range *p {
+		got, want := r.Bounds(), poly[i].Bounds()
+		if math.Abs(got.MinX-want.MinX) > 0.05 || math.Abs(got.MaxX-want.MaxX) > 0.05 || math.Abs(got.MinY-want.MinY) > 0.05 || math.Abs(got.MaxY-want.MaxY) > 0.05 {
+			t.Errorf("MVT Test failed, expected ring bounds %v, got: %v", want, got)
+		}
+	}
+
+	// The line is clipped at the buffer, 64 units east of the tile edge.
+	l, ok := feats[1].Geometry.(*LineString)
+	if !ok || len(*l) != 2 || math.Abs((*l)[0].X+20) > 0.05 || math.Abs((*l)[1].X-64*180.0/4096) > 1e-9 {
+		t.Errorf("MVT Test failed, unexpected clipped line: %v", feats[1].Geometry)
+	}
+	if pt, ok := feats[2].Geometry.(*Point); !ok || math.Abs(pt.X+100) > 0.05 || math.Abs(pt.Y-50) > 0.05 {
+		t.Errorf("MVT Test failed, expected the western point only, got: %v", feats[2].Geometry)
+	}
+	if feats[0].Properties["name"] != feats[2].Properties["name"] {
+		t.Errorf("MVT Test failed, expected shared values")
+	}
+
+	if _, err := DecodeMVT(enc[:len(enc)-3], 1, 0, 0); err == nil {
+		t.Errorf("MVT Test failed, expected error decoding truncated tile")
+	}
+}
+
+func TestTWKB(t *testing.T) {
+	pt := Point{X: 1, Y: 2}
+	if out, _ := MarshalTWKB(&pt, TWKBOptions{}); !bytes.Equal(out, []byte{0x01, 0x00, 0x02, 0x04}) {
+		t.Errorf("TWKB Test failed, unexpected Point encoding: %x", out)
+	}
+	line := LineString{{X: 1, Y: 1}, {X: 5, Y: 5}}
+	if out, _ := MarshalTWKB(&line, TWKBOptions{}); !bytes.Equal(out, []byte{0x02, 0x00, 0x02, 0x02, 0x02, 0x08, 0x08}) {
+		t.Errorf("TWKB Test failed, unexpected LineString encoding: %x", out)
+	}
+
+	poly := Polygon{{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}, {{X: 2.25, Y: 2.5}, {X: 2.5, Y: 4}, {X: 4, Y: 4}}}
+	multi := MultiPolygon{poly, {{{X: -20.125, Y: 5}, {X: -15, Y: 5}, {X: -15, Y: 9}}}}
+	cases := []struct {
+		g    Geometry
+		opts TWKBOptions
+	}{
+		{&Point{X: 149.12345, Y: -35.54321, Z: 612.5}, TWKBOptions{Precision: 5, ZPrecision: 1, BBox: true, Size: true}},
+		{&line, TWKBOptions{Precision: -1}},
+		{&poly, TWKBOptions{Precision: 3, BBox: true}},
+		{&MultiPoint{{X: 1, Y: 2}, {X: 3, Y: 4}}, TWKBOptions{IDs: []int64{7, -2}}},
+		{&MultiLineString{line, {{X: 0, Y: 0}, {X: -1, Y: 3}}}, TWKBOptions{Size: true}},
+		{&multi, TWKBOptions{Precision: 3, BBox: true, Size: true, IDs: []int64{100, 101}}},
+		{&MultiPolygon{}, TWKBOptions{BBox: true}},
+	}
+	for _, c := range cases {
+		out, err := MarshalTWKB(c.g, c.opts)
+		if err != nil {
+			t.Fatalf("TWKB Test failed, error encoding %T: %s", c.g, err)
+		}
+		g, ids, err := UnmarshalTWKB(out)
+		if err != nil {
+			t.Fatalf("TWKB Test failed, error decoding %T: %s", c.g, err)
+		}
+		expected := c.g
+		if c.opts.Precision < 0 {
+			expected = &LineString{{X: 0, Y: 0}, {X: 10, Y: 10}}
+		}
+		if !reflect.DeepEqual(g, expected) || !reflect.DeepEqual(ids, c.opts.IDs) {
+			t.Errorf("TWKB Test failed, expected %v %v, got: %v %v", expected, c.opts.IDs, g, ids)
+		}
+	}
+
+	// The size header lets readers skip the bounding box and body.
+	out, _ := MarshalTWKB(&multi, TWKBOptions{Precision: 3, BBox: true, Size: true})
+	if size, n := binary.Uvarint(out[2:]); int(size) != len(out)-2-n {
+		t.Errorf("TWKB Test failed, size %d does not match %d remaining bytes", size, len(out)-2-n)
+	}
+	if _, _, err := UnmarshalTWKB(out[:len(out)-1]); err == nil {
+		t.Errorf("TWKB Test failed, expected error decoding truncated TWKB")
+	}
+	if _, err := MarshalTWKB(&poly, TWKBOptions{IDs: []int64{1}}); err == nil {
+		t.Errorf("TWKB Test failed, expected error for ids on a Polygon")
+	}
+}
@@ -0,0 +1,159 @@
+package geometry
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// GeoPackageEnvelope selects the envelope stored in a GeoPackage geometry
+// header, numbered as in the header flags.
+type GeoPackageEnvelope uint8
+
+const (
+	NoEnvelope GeoPackageEnvelope = iota
+	EnvelopeXY
+	EnvelopeXYZ
+	EnvelopeXYM
+	EnvelopeXYZM
+)
+
+// GeoPackageGeometry is a geometry as stored in a GeoPackage table: a
+// "GP" header holding the SRID and an optional envelope, followed by the
+// ISO WKB of the geometry. It can be scanned from and written to a
+// geometry column with any database/sql driver.
+type GeoPackageGeometry struct {
+	Geometry Geometry
+	SRID     int32
+	// Envelope chooses the envelope written by MarshalGPKG and records the
+	// one read by UnmarshalGPKG. Bounds and the Z range hold its values,
+	// M values are not kept.
+	Envelope   GeoPackageEnvelope
+	Bounds     Bounds
+	MinZ, MaxZ float64
+	Empty      bool
+}
+
+const (
+	gpkgEmptyFlag    = 1 << 4
+	gpkgExtendedFlag = 1 << 5
+)
+
+// MarshalGPKG encodes the geometry with the byte order given by mode,
+// computing the envelope and the empty flag from the geometry rather than
+// the Bounds, Z range and Empty fields. Envelopes with M values are not
+// supported as points have no M coordinate.
+func (g *GeoPackageGeometry) MarshalGPKG(mode uint8) ([]byte, error) {
+	if g.Geometry == nil {
+		return nil, errors.New("GeoPackage: no geometry to encode")
+	}
+	if g.Envelope > EnvelopeXYZ {
+		return nil, fmt.Errorf("GeoPackage: envelope %d not supported", g.Envelope)
+	}
+
+	b, minZ, maxZ := EmptyBounds(), math.Inf(1), math.Inf(-1)
+	_, err := mapGeometry(g.Geometry, func(p Point) (Point, error) {
+		if !math.IsNaN(p.X) && !math.IsNaN(p.Y) {
+			b = b.ExtendPoint(p)
+			minZ, maxZ = math.Min(minZ, p.Z), math.Max(maxZ, p.Z)
+		}
+		return p, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	empty := b.IsEmpty()
+	env := g.Envelope
+	if empty {
+		env = NoEnvelope
+	}
+	flags := mode | uint8(env)<<1
+	if empty {
+		flags |= gpkgEmptyFlag
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("GP")
+	buf.WriteByte(0)
+	buf.WriteByte(flags)
+	binary.Write(buf, endian[mode], g.SRID)
+	if env >= EnvelopeXY {
+		binary.Write(buf, endian[mode], []float64{b.MinX, b.MaxX, b.MinY, b.MaxY})
+	}
+	if env == EnvelopeXYZ {
+		binary.Write(buf, endian[mode], []float64{minZ, maxZ})
+	}
+	buf.Write(g.Geometry.MarshalWKB(mode))
+
+	return buf.Bytes(), nil
+}
+
+func (g *GeoPackageGeometry) UnmarshalGPKG(in []byte) error {
+	if len(in) < 8 || in[0] != 'G' || in[1] != 'P' {
+		return errors.New("Not a GeoPackage geometry")
+	}
+	if in[2] != 0 {
+		return fmt.Errorf("GeoPackage: version %d not supported", in[2])
+	}
+	flags := in[3]
+	if flags&gpkgExtendedFlag != 0 {
+		return errors.New("GeoPackage: extended geometry types not supported")
+	}
+	env := GeoPackageEnvelope(flags >> 1 & 7)
+	if env > EnvelopeXYZM {
+		return fmt.Errorf("GeoPackage: invalid envelope code %d", env)
+	}
+
+	end := endian[flags&1]
+	buf := bytes.NewBuffer(in[4:])
+	out := GeoPackageGeometry{Envelope: env, Bounds: EmptyBounds(), MinZ: math.Inf(1), MaxZ: math.Inf(-1), Empty: flags&gpkgEmptyFlag != 0}
+	if err := binary.Read(buf, end, &out.SRID); err != nil {
+		return fmt.Errorf("Problem reading GeoPackage header: %s", err)
+	}
+
+	sizes := map[GeoPackageEnvelope]int{NoEnvelope: 0, EnvelopeXY: 4, EnvelopeXYZ: 6, EnvelopeXYM: 6, EnvelopeXYZM: 8}
+	values := make([]float64, sizes[env])
+	if err := binary.Read(buf, end, values); err != nil {
+		return fmt.Errorf("Problem reading GeoPackage envelope: %s", err)
+	}
+	if env >= EnvelopeXY {
+		out.Bounds = Bounds{values[0], values[2], values[1], values[3]}
+	}
+	if env == EnvelopeXYZ || env == EnvelopeXYZM {
+		out.MinZ, out.MaxZ = values[4], values[5]
+	}
+
+	var err error
+	if out.Geometry, err = unmarshalWKB(buf.Bytes()); err != nil {
+		return err
+	}
+	*g = out
+
+	return nil
+}
+
+// Value implements driver.Valuer, encoding the geometry little endian or
+// as NULL when there is none.
+func (g GeoPackageGeometry) Value() (driver.Value, error) {
+	if g.Geometry == nil {
+		return nil, nil
+	}
+	return g.MarshalGPKG(1)
+}
+
+// Scan implements sql.Scanner.
+func (g *GeoPackageGeometry) Scan(src interface{}) error {
+	switch t := src.(type) {
+	case nil:
+		*g = GeoPackageGeometry{}
+		return nil
+	case []byte:
+		return g.UnmarshalGPKG(t)
+	case string:
+		return g.UnmarshalGPKG([]byte(t))
+	}
+	return fmt.Errorf("GeoPackage: cannot scan %T", src)
+}
@@ -0,0 +1,377 @@
+package geometry
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AxisOrder is the order in which GML coordinates list the axes of a
+// point.
+type AxisOrder int
+
+const (
+	// AxisAuto reads and writes latitude before longitude when the
+	// srsName is an EPSG URN or http URI of a geographic CRS known to the
+	// EPSG function, that is 4326, 4283 or 7844, and X before Y
+	// otherwise. Other latitude first CRSs, such as ETRS89 (EPSG:4258)
+	// or NAD83 (EPSG:4269), are not recognised and need AxisYX.
+	AxisAuto AxisOrder = iota
+	AxisXY
+	AxisYX
+)
+
+// GMLOptions control the encoding of GML. SRSName is written on the
+// outermost geometry and ID, when set, gives it a gml:id with parts
+// numbered from it, which GML 3.2 requires of valid documents.
+type GMLOptions struct {
+	SRSName   string
+	AxisOrder AxisOrder
+	ID        string
+}
+
+const gmlNamespace = "http://www.opengis.net/gml/3.2"
+
+type gmlNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []gmlNode  `xml:",any"`
+}
+
+func (n *gmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+var gmlEPSGCode = regexp.MustCompile(`^(?:urn:ogc:def:crs:EPSG:[^:]*:|https?://www\.opengis\.net/def/crs/EPSG/[^/]*/)(\d+)$`)
+
+// swapAxes reports whether coordinates in srsName are listed Y first.
+func (o AxisOrder) swapAxes(srsName string) bool {
+	switch o {
+	case AxisXY:
+		return false
+	case AxisYX:
+		return true
+	}
+	match := gmlEPSGCode.FindStringSubmatch(srsName)
+	if match == nil {
+		return false
+	}
+	code, _ := strconv.Atoi(match[1])
+	crs, err := EPSG(code)
+	return err == nil && crs.IsGeographic()
+}
+
+// MarshalGML encodes a geometry as a GML 3.2 gml:Point, gml:LineString,
+// gml:Polygon, gml:MultiPoint, gml:MultiCurve or gml:MultiSurface.
+func MarshalGML(g Geometry, opts GMLOptions) ([]byte, error) {
+	e := gmlEncoder{swap: opts.AxisOrder.swapAxes(opts.SRSName), id: opts.ID}
+	n, err := e.geometry(g)
+	if err != nil {
+		return nil, err
+	}
+	n.Attrs = append([]xml.Attr{{Name: xml.Name{Local: "xmlns:gml"}, Value: gmlNamespace}}, n.Attrs...)
+	if opts.SRSName != "" {
+		n.Attrs = append(n.Attrs, xml.Attr{Name: xml.Name{Local: "srsName"}, Value: opts.SRSName})
+	}
+	return xml.Marshal(n)
+}
+
+type gmlEncoder struct {
+	swap bool
+	id   string
+	n    int
+}
+
+func (e *gmlEncoder) node(name string, children ...gmlNode) gmlNode {
+	n := gmlNode{XMLName: xml.Name{Local: "gml:" + name}, Children: children}
+	if e.id != "" {
+		id := e.id
+		if e.n > 0 {
+			id = fmt.Sprintf("%s.%d", e.id, e.n)
+		}
+		e.n++
+		n.Attrs = []xml.Attr{{Name: xml.Name{Local: "gml:id"}, Value: id}}
+	}
+	return n
+}
+
+func (e *gmlEncoder) positions(name string, pts []Point) gmlNode {
+	dim := 2
+	for _, p := range pts {
+		if p.Z != 0 {
+			dim = 3
+		}
+	}
+	vals := make([]string, 0, dim*len(pts))
+	for _, p := range pts {
+		x, y := p.X, p.Y
+		if e.swap {
+			x, y = y, x
+		}
+		vals = append(vals, strconv.FormatFloat(x, 'f', -1, 64), strconv.FormatFloat(y, 'f', -1, 64))
+		if dim == 3 {
+			vals = append(vals, strconv.FormatFloat(p.Z, 'f', -1, 64))
+		}
+	}
+	n := gmlNode{XMLName: xml.Name{Local: "gml:" + name}, Text: strings.Join(vals, " ")}
+	if dim == 3 {
+		n.Attrs = []xml.Attr{{Name: xml.Name{Local: "srsDimension"}, Value: "3"}}
+	}
+	return n
+}
+
+func (e *gmlEncoder) wrap(name string, child gmlNode) gmlNode {
+	return gmlNode{XMLName: xml.Name{Local: "gml:" + name}, Children: []gmlNode{child}}
+}
+
+func (e *gmlEncoder) polygon(p Polygon) gmlNode {
+	n := e.node("Polygon")
+	for i, r := range p {
+		closed := append(append([]Point{}, r...), r[0])
+		ring := e.node("LinearRing")
+		ring.Children = []gmlNode{e.positions("posList", closed)}
+		if i == 0 {
+			n.Children = append(n.Children, e.wrap("exterior", ring))
+		} else {
+			n.Children = append(n.Children, e.wrap("interior", ring))
+		}
+	}
+	return n
+}
+
+func (e *gmlEncoder) geometry(g Geometry) (gmlNode, error) {
+	switch t := g.(type) {
+	case *Point:
+		n := e.node("Point")
+		n.Children = []gmlNode{e.positions("pos", []Point{*t})}
+		return n, nil
+	case *LineString:
+		n := e.node("LineString")
+		n.Children = []gmlNode{e.positions("posList", *t)}
+		return n, nil
+	case *Polygon:
+		return e.polygon(*t), nil
+	case *MultiPoint:
+		n := e.node("MultiPoint")
+		for _, p := range *t {
+			pt := e.node("Point")
+			pt.Children = []gmlNode{e.positions("pos", []Point{p})}
+			n.Children = append(n.Children, e.wrap("pointMember", pt))
+		}
+		return n, nil
+	case *MultiLineString:
+		n := e.node("MultiCurve")
+		for _, l := range *t {
+			ls := e.node("LineString")
+			ls.Children = []gmlNode{e.positions("posList", l)}
+			n.Children = append(n.Children, e.wrap("curveMember", ls))
+		}
+		return n, nil
+	case *MultiPolygon:
+		n := e.node("MultiSurface")
+		for _, p := range *t {
+			n.Children = append(n.Children, e.wrap("surfaceMember", e.polygon(p)))
+		}
+		return n, nil
+	}
+	return gmlNode{}, fmt.Errorf("Geometry %T not supported in GML", g)
+}
+
+// UnmarshalGML decodes a GML geometry, returning it with the srsName of
+// the outermost geometry. Coordinates are read from gml:pos and
+// gml:posList elements, using srsDimension where given. Besides the types
+// written by MarshalGML, gml:LinearRing is read as a Polygon and the GML 2
+// gml:MultiLineString and gml:MultiPolygon are accepted.
+func UnmarshalGML(in []byte, opts GMLOptions) (Geometry, string, error) {
+	var n gmlNode
+	if err := xml.Unmarshal(in, &n); err != nil {
+		return nil, "", fmt.Errorf("Problem reading GML: %s", err)
+	}
+	srsName := n.attr("srsName")
+	if srsName == "" {
+		srsName = opts.SRSName
+	}
+	d := gmlDecoder{order: opts.AxisOrder}
+	g, err := d.geometry(n, srsName, 2)
+	return g, srsName, err
+}
+
+type gmlDecoder struct {
+	order AxisOrder
+}
+
+// geometry decodes n, with srsName and dim inherited from its ancestors.
+func (d gmlDecoder) geometry(n gmlNode, srsName string, dim int) (Geometry, error) {
+	if s := n.attr("srsName"); s != "" {
+		srsName = s
+	}
+	if s := n.attr("srsDimension"); s != "" {
+		var err error
+		if dim, err = strconv.Atoi(s); err != nil || dim < 2 || dim > 3 {
+			return nil, fmt.Errorf("GML srsDimension %s not supported", s)
+		}
+	}
+
+	switch n.XMLName.Local {
+	case "Point":
+		pts, err := d.positions(n, srsName, dim)
+		if err != nil {
+			return nil, err
+		}
+		if len(pts) != 1 {
+			return nil, fmt.Errorf("GML Point with %d positions", len(pts))
+		}
+		return &pts[0], nil
+	case "LineString":
+		pts, err := d.positions(n, srsName, dim)
+		if err != nil {
+			return nil, err
+		}
+		ls := LineString(pts)
+		return &ls, nil
+	case "LinearRing":
+		r, err := d.ring(n, srsName, dim)
+		if err != nil {
+			return nil, err
+		}
+		return &Polygon{r}, nil
+	case "Polygon":
+		p := Polygon{}
+		for _, c := range n.Children {
+			if c.XMLName.Local != "exterior" && c.XMLName.Local != "interior" {
+				continue
+			}
+			if (c.XMLName.Local == "exterior") != (len(p) == 0) {
+				return nil, errors.New("GML Polygon must have one exterior before its interiors")
+			}
+			for _, r := range c.Children {
+				ring, err := d.ring(r, srsName, dim)
+				if err != nil {
+					return nil, err
+				}
+				p = append(p, ring)
+			}
+		}
+		if len(p) == 0 {
+			return nil, errors.New("GML Polygon without exterior")
+		}
+		return &p, nil
+	case "MultiPoint":
+		m := MultiPoint{}
+		err := d.members(n, srsName, dim, func(g Geometry) bool {
+			p, ok := g.(*Point)
+			if ok {
+				m = append(m, *p)
+			}
+			return ok
+		})
+		return &m, err
+	case "MultiCurve", "MultiLineString":
+		m := MultiLineString{}
+		err := d.members(n, srsName, dim, func(g Geometry) bool {
+			l, ok := g.(*LineString)
+			if ok {
+				m = append(m, *l)
+			}
+			return ok
+		})
+		return &m, err
+	case "MultiSurface", "MultiPolygon":
+		m := MultiPolygon{}
+		err := d.members(n, srsName, dim, func(g Geometry) bool {
+			p, ok := g.(*Polygon)
+			if ok {
+				m = append(m, *p)
+			}
+			return ok
+		})
+		return &m, err
+	}
+	return nil, fmt.Errorf("GML element %s not supported", n.XMLName.Local)
+}
+
+// members decodes the geometries inside the member elements of a multi
+// geometry, passing each to add, which reports whether it has the right
+// type.
+func (d gmlDecoder) members(n gmlNode, srsName string, dim int, add func(Geometry) bool) error {
+	for _, member := range n.Children {
+		if !strings.HasSuffix(member.XMLName.Local, "Member") && !strings.HasSuffix(member.XMLName.Local, "Members") {
+			continue
+		}
+		for _, c := range member.Children {
+			g, err := d.geometry(c, srsName, dim)
+			if err != nil {
+				return err
+			}
+			if !add(g) {
+				return fmt.Errorf("GML %s cannot hold %s", n.XMLName.Local, c.XMLName.Local)
+			}
+		}
+	}
+	return nil
+}
+
+func (d gmlDecoder) ring(n gmlNode, srsName string, dim int) (LinearRing, error) {
+	if n.XMLName.Local != "LinearRing" {
+		return nil, fmt.Errorf("GML ring %s not supported", n.XMLName.Local)
+	}
+	pts, err := d.positions(n, srsName, dim)
+	if err != nil {
+		return nil, err
+	}
+	if len(pts) > 1 && pts[0] == pts[len(pts)-1] {
+		pts = pts[:len(pts)-1]
+	}
+	if len(pts) < 3 {
+		return nil, fmt.Errorf("GML LinearRing with %d points", len(pts))
+	}
+	return LinearRing(pts), nil
+}
+
+// positions reads the gml:posList or gml:pos children of n.
+func (d gmlDecoder) positions(n gmlNode, srsName string, dim int) ([]Point, error) {
+	swap := d.order.swapAxes(srsName)
+	pts := []Point{}
+	for _, c := range n.Children {
+		if c.XMLName.Local != "pos" && c.XMLName.Local != "posList" {
+			continue
+		}
+		cdim := dim
+		if s := c.attr("srsDimension"); s != "" {
+			var err error
+			if cdim, err = strconv.Atoi(s); err != nil || cdim < 2 || cdim > 3 {
+				return nil, fmt.Errorf("GML srsDimension %s not supported", s)
+			}
+		}
+		fields := strings.Fields(c.Text)
+		if c.XMLName.Local == "pos" && len(fields) >= 2 && len(fields) <= 3 {
+			cdim = len(fields)
+		}
+		if len(fields)%cdim != 0 {
+			return nil, fmt.Errorf("GML %s has %d values for dimension %d", c.XMLName.Local, len(fields), cdim)
+		}
+		for i := 0; i < len(fields); i += cdim {
+			var xyz [3]float64
+			for j := 0; j < cdim; j++ {
+				var err error
+				if xyz[j], err = strconv.ParseFloat(fields[i+j], 64); err != nil {
+					return nil, fmt.Errorf("GML coordinate not recognised: %s", fields[i+j])
+				}
+			}
+			if swap {
+				xyz[0], xyz[1] = xyz[1], xyz[0]
+			}
+			pts = append(pts, Point{xyz[0], xyz[1], xyz[2]})
+		}
+	}
+	return pts, nil
+}
@@ -0,0 +1,229 @@
+package geometry
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type gpxPoint struct {
+	Lat  float64    `xml:"lat,attr"`
+	Lon  float64    `xml:"lon,attr"`
+	Ele  *float64   `xml:"ele,omitempty"`
+	Time *time.Time `xml:"time,omitempty"`
+	Name string     `xml:"name,omitempty"`
+	Desc string     `xml:"desc,omitempty"`
+}
+
+type gpxRoute struct {
+	Name   string     `xml:"name,omitempty"`
+	Desc   string     `xml:"desc,omitempty"`
+	Points []gpxPoint `xml:"rtept"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name,omitempty"`
+	Desc     string       `xml:"desc,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxDoc struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	Namespace string     `xml:"xmlns,attr,omitempty"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Waypoints []gpxPoint `xml:"wpt"`
+	Routes    []gpxRoute `xml:"rte"`
+	Tracks    []gpxTrack `xml:"trk"`
+}
+
+func (p gpxPoint) point() Point {
+	pt := Point{X: p.Lon, Y: p.Lat}
+	if p.Ele != nil {
+		pt.Z = *p.Ele
+	}
+	return pt
+}
+
+// gpxProperties returns the name and description of a GPX element as
+// properties.
+func gpxProperties(name, desc string) map[string]interface{} {
+	props := map[string]interface{}{}
+	if name != "" {
+		props["name"] = name
+	}
+	if desc != "" {
+		props["desc"] = desc
+	}
+	return props
+}
+
+// gpxTrace holds the points of a route or track segment with the time of
+// each and whether it has an elevation, in the forms described by
+// DecodeGPX, reporting whether any point has a time or elevation.
+type gpxTrace struct {
+	points          []Point
+	times, eles     []interface{}
+	timed, elevated bool
+}
+
+func readGPXTrace(pts []gpxPoint) gpxTrace {
+	t := gpxTrace{points: make([]Point, len(pts)), times: make([]interface{}, len(pts)), eles: make([]interface{}, len(pts))}
+	for i, p := range pts {
+		t.points[i] = p.point()
+		t.eles[i] = p.Ele != nil
+		if p.Time != nil {
+			t.times[i], t.timed = p.Time.Format(time.RFC3339Nano), true
+		}
+		t.elevated = t.elevated || p.Ele != nil
+	}
+	return t
+}
+
+// DecodeGPX reads the waypoints, routes and tracks of a GPX document as
+// Point, LineString and MultiLineString features, one LineString per track
+// segment. Elevations are read as Z. Names and descriptions become the
+// "name" and "desc" properties and timestamps the "time" property, as RFC
+// 3339 strings. Likewise the "has_ele" property tells which points have an
+// elevation, so that zero elevations can be told from missing ones. For
+// waypoints these hold a single value, for routes a list with one per
+// point and for tracks a list of such lists, one per segment, with null
+// for points without a time. Lists are []interface{}, as encoding/json
+// decodes them, so that features read back from GeoJSON encode the same.
+func DecodeGPX(r io.Reader) (FeatureCollection, error) {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	var doc gpxDoc
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return fc, fmt.Errorf("Problem reading GPX: %s", err)
+	}
+
+	for _, w := range doc.Waypoints {
+		pt := w.point()
+		props := gpxProperties(w.Name, w.Desc)
+		if w.Time != nil {
+			props["time"] = w.Time.Format(time.RFC3339Nano)
+		}
+		if w.Ele != nil {
+			props["has_ele"] = true
+		}
+		fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: &pt, Properties: props})
+	}
+
+	for _, rte := range doc.Routes {
+		trace := readGPXTrace(rte.Points)
+		ls := LineString(trace.points)
+		props := gpxProperties(rte.Name, rte.Desc)
+		if trace.timed {
+			props["time"] = trace.times
+		}
+		if trace.elevated {
+			props["has_ele"] = trace.eles
+		}
+		fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: &ls, Properties: props})
+	}
+
+	for _, trk := range doc.Tracks {
+		mls := MultiLineString{}
+		segTimes, segEles := []interface{}{}, []interface{}{}
+		timed, elevated := false, false
+		for _, seg := range trk.Segments {
+			trace := readGPXTrace(seg.Points)
+			mls = append(mls, LineString(trace.points))
+			segTimes = append(segTimes, trace.times)
+			segEles = append(segEles, trace.eles)
+			timed = timed || trace.timed
+			elevated = elevated || trace.elevated
+		}
+		props := gpxProperties(trk.Name, trk.Desc)
+		if timed {
+			props["time"] = segTimes
+		}
+		if elevated {
+			props["has_ele"] = segEles
+		}
+		fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: &mls, Properties: props})
+	}
+
+	return fc, nil
+}
+
+// gpxItem returns item i of a list property, or nil past its end or when
+// the property is not a list.
+func gpxItem(v interface{}, i int) interface{} {
+	list, _ := v.([]interface{})
+	if i < len(list) {
+		return list[i]
+	}
+	return nil
+}
+
+// gpxPoints converts points to GPX with their times and elevation flags,
+// given as list properties in the forms set by DecodeGPX.
+func gpxPoints(pts []Point, times, eles interface{}) ([]gpxPoint, error) {
+	out := make([]gpxPoint, len(pts))
+	for i, p := range pts {
+		out[i] = gpxPoint{Lat: p.Y, Lon: p.X}
+		if ele, _ := gpxItem(eles, i).(bool); p.Z != 0 || ele {
+			ele := p.Z
+			out[i].Ele = &ele
+		}
+		if s, ok := gpxItem(times, i).(string); ok {
+			tm, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, fmt.Errorf("GPX time not recognised: %s", s)
+			}
+			out[i].Time = &tm
+		}
+	}
+	return out, nil
+}
+
+// EncodeGPX writes Point features as waypoints, LineString features as
+// routes and MultiLineString features as tracks, reading the properties
+// set by DecodeGPX. Z values are written as elevations when not zero or
+// when "has_ele" marks the point as having one.
+func EncodeGPX(w io.Writer, fc FeatureCollection) error {
+	doc := gpxDoc{Namespace: "http://www.topografix.com/GPX/1/1", Version: "1.1", Creator: "geometry"}
+	for _, f := range fc.Features {
+		name, _ := f.Properties["name"].(string)
+		desc, _ := f.Properties["desc"].(string)
+		times, eles := f.Properties["time"], f.Properties["has_ele"]
+		switch t := f.Geometry.(type) {
+		case *Point:
+			pts, err := gpxPoints([]Point{*t}, []interface{}{times}, []interface{}{eles})
+			if err != nil {
+				return err
+			}
+			pts[0].Name, pts[0].Desc = name, desc
+			doc.Waypoints = append(doc.Waypoints, pts[0])
+		case *LineString:
+			pts, err := gpxPoints(*t, times, eles)
+			if err != nil {
+				return err
+			}
+			doc.Routes = append(doc.Routes, gpxRoute{Name: name, Desc: desc, Points: pts})
+		case *MultiLineString:
+			trk := gpxTrack{Name: name, Desc: desc}
+			for i, l := range *t {
+				pts, err := gpxPoints(l, gpxItem(times, i), gpxItem(eles, i))
+				if err != nil {
+					return err
+				}
+				trk.Segments = append(trk.Segments, gpxSegment{pts})
+			}
+			doc.Tracks = append(doc.Tracks, trk)
+		default:
+			return fmt.Errorf("Geometry %T not supported in GPX", f.Geometry)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(doc)
+}
@@ -0,0 +1,392 @@
+package geometry
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type kmlCoords struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPolygon struct {
+	Outer kmlCoords     `xml:"outerBoundaryIs>LinearRing"`
+	Inner []kmlBoundary `xml:"innerBoundaryIs"`
+}
+
+type kmlBoundary struct {
+	Rings []kmlCoords `xml:"LinearRing"`
+}
+
+type kmlGeometry struct {
+	Point         []kmlCoords   `xml:"Point"`
+	LineString    []kmlCoords   `xml:"LineString"`
+	LinearRing    []kmlCoords   `xml:"LinearRing"`
+	Polygon       []kmlPolygon  `xml:"Polygon"`
+	MultiGeometry []kmlGeometry `xml:"MultiGeometry"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlSimpleData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type kmlExtendedData struct {
+	Data       []kmlData `xml:"Data"`
+	SchemaData []struct {
+		SimpleData []kmlSimpleData `xml:"SimpleData"`
+	} `xml:"SchemaData"`
+}
+
+type kmlPlacemark struct {
+	XMLName      xml.Name         `xml:"Placemark"`
+	Name         string           `xml:"name,omitempty"`
+	Description  string           `xml:"description,omitempty"`
+	ExtendedData *kmlExtendedData `xml:"ExtendedData"`
+	kmlGeometry
+}
+
+// KMLDecoder reads the placemarks of a KML document one at a time, so
+// large documents need not be held in memory.
+type KMLDecoder struct {
+	dec     *xml.Decoder
+	stack   []string
+	folders []string
+}
+
+func NewKMLDecoder(r io.Reader) *KMLDecoder {
+	return &KMLDecoder{dec: xml.NewDecoder(r)}
+}
+
+// Next returns the next placemark as a feature, or io.EOF at the end of
+// the document. The name and description of the placemark and its
+// ExtendedData values become string properties. Placemarks inside
+// Folders get a "folder" property holding the folder names joined by "/".
+func (d *KMLDecoder) Next() (*Feature, error) {
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "Placemark":
+				var p kmlPlacemark
+				if err := d.dec.DecodeElement(&p, &t); err != nil {
+					return nil, fmt.Errorf("Problem reading KML Placemark: %s", err)
+				}
+				return d.feature(p)
+			case t.Name.Local == "name" && len(d.stack) > 0 && d.stack[len(d.stack)-1] == "Folder":
+				var name string
+				if err := d.dec.DecodeElement(&name, &t); err != nil {
+					return nil, fmt.Errorf("Problem reading KML Folder: %s", err)
+				}
+				d.folders[len(d.folders)-1] = strings.TrimSpace(name)
+			default:
+				d.stack = append(d.stack, t.Name.Local)
+				if t.Name.Local == "Folder" {
+					d.folders = append(d.folders, "")
+				}
+			}
+		case xml.EndElement:
+			if len(d.stack) > 0 {
+				d.stack = d.stack[:len(d.stack)-1]
+			}
+			if t.Name.Local == "Folder" && len(d.folders) > 0 {
+				d.folders = d.folders[:len(d.folders)-1]
+			}
+		}
+	}
+}
+
+func (d *KMLDecoder) feature(p kmlPlacemark) (*Feature, error) {
+	g, err := p.kmlGeometry.geometry()
+	if err != nil {
+		return nil, err
+	}
+	props := map[string]interface{}{}
+	if name := strings.TrimSpace(p.Name); name != "" {
+		props["name"] = name
+	}
+	if desc := strings.TrimSpace(p.Description); desc != "" {
+		props["description"] = desc
+	}
+	if ext := p.ExtendedData; ext != nil {
+		for _, data := range ext.Data {
+			props[data.Name] = strings.TrimSpace(data.Value)
+		}
+		for _, schema := range ext.SchemaData {
+			for _, data := range schema.SimpleData {
+				props[data.Name] = strings.TrimSpace(data.Value)
+			}
+		}
+	}
+	if len(d.folders) > 0 {
+		props["folder"] = strings.Join(d.folders, "/")
+	}
+	return &Feature{Type: "Feature", Geometry: g, Properties: props}, nil
+}
+
+// DecodeKML reads every placemark of a KML document.
+func DecodeKML(r io.Reader) (FeatureCollection, error) {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	d := NewKMLDecoder(r)
+	for {
+		f, err := d.Next()
+		if err == io.EOF {
+			return fc, nil
+		}
+		if err != nil {
+			return fc, err
+		}
+		fc.Features = append(fc.Features, *f)
+	}
+}
+
+// geometry converts the geometries of a placemark, flattening
+// MultiGeometry into the multi type of its parts. A MultiGeometry mixing
+// points, lines and polygons is not supported.
+func (k kmlGeometry) geometry() (Geometry, error) {
+	var pts MultiPoint
+	var lines MultiLineString
+	var polys MultiPolygon
+	if err := k.collect(&pts, &lines, &polys); err != nil {
+		return nil, err
+	}
+
+	kinds := 0
+	for _, n := range []int{len(pts), len(lines), len(polys)} {
+		if n > 0 {
+			kinds++
+		}
+	}
+	switch {
+	case kinds == 0:
+		return nil, nil
+	case kinds > 1:
+		return nil, errors.New("KML MultiGeometry mixing geometry types not supported")
+	}
+
+	single := len(k.MultiGeometry) == 0
+	switch {
+	case len(pts) == 1 && single:
+		return &pts[0], nil
+	case len(pts) > 0:
+		return &pts, nil
+	case len(lines) == 1 && single:
+		return &lines[0], nil
+	case len(lines) > 0:
+		return &lines, nil
+	case len(polys) == 1 && single:
+		return &polys[0], nil
+	}
+	return &polys, nil
+}
+
+func (k kmlGeometry) collect(pts *MultiPoint, lines *MultiLineString, polys *MultiPolygon) error {
+	for _, c := range k.Point {
+		p, err := kmlPoints(c.Coordinates)
+		if err != nil {
+			return err
+		}
+		if len(p) != 1 {
+			return fmt.Errorf("KML Point with %d coordinates", len(p))
+		}
+		*pts = append(*pts, p[0])
+	}
+	for _, c := range k.LineString {
+		l, err := kmlPoints(c.Coordinates)
+		if err != nil {
+			return err
+		}
+		*lines = append(*lines, LineString(l))
+	}
+	for _, c := range k.LinearRing {
+		r, err := kmlRing(c)
+		if err != nil {
+			return err
+		}
+		*polys = append(*polys, Polygon{r})
+	}
+	for _, kp := range k.Polygon {
+		shell, err := kmlRing(kp.Outer)
+		if err != nil {
+			return err
+		}
+		p := Polygon{shell}
+		for _, b := range kp.Inner {
+			for _, c := range b.Rings {
+				hole, err := kmlRing(c)
+				if err != nil {
+					return err
+				}
+				p = append(p, hole)
+			}
+		}
+		*polys = append(*polys, p)
+	}
+	for _, m := range k.MultiGeometry {
+		if err := m.collect(pts, lines, polys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kmlRing reads the coordinates of a LinearRing, dropping the closing
+// point.
+func kmlRing(c kmlCoords) (LinearRing, error) {
+	pts, err := kmlPoints(c.Coordinates)
+	if err != nil {
+		return nil, err
+	}
+	if len(pts) > 1 && pts[0] == pts[len(pts)-1] {
+		pts = pts[:len(pts)-1]
+	}
+	if len(pts) < 3 {
+		return nil, fmt.Errorf("KML LinearRing with %d points", len(pts))
+	}
+	return LinearRing(pts), nil
+}
+
+// kmlPoints parses whitespace separated lon,lat[,alt] tuples.
+// kmlComma matches a comma with the spaces around it, which some files
+// put between the values of a tuple.
+var kmlComma = regexp.MustCompile(`\s*,\s*`)
+
+func kmlPoints(in string) ([]Point, error) {
+	pts := []Point{}
+	for _, tuple := range strings.Fields(kmlComma.ReplaceAllString(in, ",")) {
+		vals := strings.Split(tuple, ",")
+		if len(vals) < 2 || len(vals) > 3 {
+			return nil, fmt.Errorf("KML coordinates not recognised: %s", tuple)
+		}
+		var xyz [3]float64
+		for i, v := range vals {
+			var err error
+			if xyz[i], err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("KML coordinates not recognised: %s", tuple)
+			}
+		}
+		pts = append(pts, Point{xyz[0], xyz[1], xyz[2]})
+	}
+	return pts, nil
+}
+
+func kmlCoordinates(pts []Point, closed bool) kmlCoords {
+	if closed && len(pts) > 0 {
+		pts = append(pts[:len(pts):len(pts)], pts[0])
+	}
+	tuples := make([]string, len(pts))
+	for i, p := range pts {
+		tuples[i] = strconv.FormatFloat(p.X, 'f', -1, 64) + "," + strconv.FormatFloat(p.Y, 'f', -1, 64)
+		if p.Z != 0 {
+			tuples[i] += "," + strconv.FormatFloat(p.Z, 'f', -1, 64)
+		}
+	}
+	return kmlCoords{strings.Join(tuples, " ")}
+}
+
+func kmlPolygonOf(p Polygon) kmlPolygon {
+	kp := kmlPolygon{}
+	for i, r := range p {
+		if i == 0 {
+			kp.Outer = kmlCoordinates(r, true)
+		} else {
+			kp.Inner = append(kp.Inner, kmlBoundary{[]kmlCoords{kmlCoordinates(r, true)}})
+		}
+	}
+	return kp
+}
+
+func kmlGeometryOf(g Geometry) (kmlGeometry, error) {
+	k := kmlGeometry{}
+	switch t := g.(type) {
+	case nil:
+	case *Point:
+		k.Point = []kmlCoords{kmlCoordinates([]Point{*t}, false)}
+	case *LineString:
+		k.LineString = []kmlCoords{kmlCoordinates(*t, false)}
+	case *Polygon:
+		k.Polygon = []kmlPolygon{kmlPolygonOf(*t)}
+	case *MultiPoint:
+		m := kmlGeometry{}
+		for _, p := range *t {
+			m.Point = append(m.Point, kmlCoordinates([]Point{p}, false))
+		}
+		k.MultiGeometry = []kmlGeometry{m}
+	case *MultiLineString:
+		m := kmlGeometry{}
+		for _, l := range *t {
+			m.LineString = append(m.LineString, kmlCoordinates(l, false))
+		}
+		k.MultiGeometry = []kmlGeometry{m}
+	case *MultiPolygon:
+		m := kmlGeometry{}
+		for _, p := range *t {
+			m.Polygon = append(m.Polygon, kmlPolygonOf(p))
+		}
+		k.MultiGeometry = []kmlGeometry{m}
+	default:
+		return k, fmt.Errorf("Geometry %T not supported in KML", g)
+	}
+	return k, nil
+}
+
+// EncodeKML writes the features as Placemarks of a KML document. The
+// "name" and "description" properties fill the matching elements, the
+// other properties are written as ExtendedData.
+func EncodeKML(w io.Writer, fc FeatureCollection) error {
+	if _, err := io.WriteString(w, xml.Header+`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>`); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	for _, f := range fc.Features {
+		k, err := kmlGeometryOf(f.Geometry)
+		if err != nil {
+			return err
+		}
+		p := kmlPlacemark{kmlGeometry: k}
+		data := []kmlData{}
+		keys := []string{}
+		for key := range f.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			v := f.Properties[key]
+			switch {
+			case v == nil:
+			case key == "name":
+				p.Name = fmt.Sprint(v)
+			case key == "description":
+				p.Description = fmt.Sprint(v)
+			default:
+				data = append(data, kmlData{Name: key, Value: fmt.Sprint(v)})
+			}
+		}
+		if len(data) > 0 {
+			p.ExtendedData = &kmlExtendedData{Data: data}
+		}
+		if err := enc.Encode(p); err != nil {
+			return err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "</Document></kml>\n")
+	return err
+}
@@ -18,6 +18,9 @@ type LineStringView struct {
 }
 
 func (l LineString) Equals(ls LineString) bool {
+	if len(l) != len(ls) {
+		return false
+	}
 	for i, point := range l {
 		if !point.Equals(ls[i]) {
 			return false
@@ -27,13 +30,7 @@ func (l LineString) Equals(ls LineString) bool {
 }
 
 func (l LineString) WKB(end binary.ByteOrder) []byte {
-	buf := new(bytes.Buffer)
-	numPoints := uint32(len(l))
-	binary.Write(buf, end, &numPoints)
-	for _, point := range l {
-		binary.Write(buf, end, point.WKB(end))
-	}
-	return buf.Bytes()
+	return wkbBody(&l, end)
 }
 
 func (l LineString) WKT() string {
@@ -62,37 +59,21 @@ func (r LineString) AsArray() [][]float64 {
 }
 
 func (l LineString) MarshalWKB(mode uint8) []byte {
-	buf := new(bytes.Buffer)
-	binary.Write(buf, endian[mode], &mode)
-
-	lsId := uint32(2)
-	binary.Write(buf, endian[mode], &lsId)
-
-	enc := l.WKB(endian[mode])
-	binary.Write(buf, endian[mode], &enc)
-
-	return buf.Bytes()
+	return marshalWKB(&l, mode)
 }
 
 func (l *LineString) UnmarshalWKB(in []byte) error {
-	buf := bytes.NewBuffer(in)
-
-	var end uint8
-	err := binary.Read(buf, binary.BigEndian, &end)
+	g, err := unmarshalWKB(in)
 	if err != nil {
-		return fmt.Errorf("Error reading geometry: %s", err)
+		return err
 	}
-
-	var wkbType uint32
-	//err = binary.Read(buf, binary.BigEndian, &wkbType)
-	err = binary.Read(buf, endian[end], &wkbType)
-	if err != nil || wkbType != 2 {
-		return fmt.Errorf("Not a LineString: %s", err)
+	ls, ok := g.(*LineString)
+	if !ok {
+		return errors.New("Not a LineString")
 	}
+	*l = *ls
 
-	*l, err = ExtractWKBLineString(buf, endian[end])
-
-	return err
+	return nil
 }
 
 func (l LineString) MarshalWKT() string {
@@ -136,6 +117,9 @@ type LinearRingView struct {
 }
 
 func (r LinearRing) Equals(lr LinearRing) bool {
+	if len(r) != len(lr) {
+		return false
+	}
 	for i, point := range r {
 		if !point.Equals(lr[i]) {
 			return false
@@ -144,15 +128,10 @@ func (r LinearRing) Equals(lr LinearRing) bool {
 	return true
 }
 
+// WKB returns the point count and points of the ring, closed by its
+// first point, as in the WKB of a Polygon.
 func (r LinearRing) WKB(end binary.ByteOrder) []byte {
-	buf := new(bytes.Buffer)
-	numPoints := uint32(len(r) + 1)
-	binary.Write(buf, end, &numPoints)
-	for _, point := range r {
-		binary.Write(buf, end, point.WKB(end))
-	}
-	binary.Write(buf, end, r[0].WKB(end))
-	return buf.Bytes()
+	return wkbBody(&Polygon{r}, end)[4:]
 }
 
 func (r LinearRing) WKT() string {
@@ -181,6 +160,26 @@ func (r LinearRing) AsArray() [][]float64 {
 	return out
 }
 
+// IsCCW reports whether the ring is oriented counter-clockwise.
+func (r LinearRing) IsCCW() bool {
+	return signedArea(r) > 0
+}
+
+// IsCW reports whether the ring is oriented clockwise.
+func (r LinearRing) IsCW() bool {
+	return signedArea(r) < 0
+}
+
+// Reverse returns the ring with its orientation flipped, keeping the
+// same starting point.
+func (r LinearRing) Reverse() LinearRing {
+	out := make(LinearRing, len(r))
+	for i := range r {
+		out[i] = r[(len(r)-i)%len(r)]
+	}
+	return out
+}
+
 /*
 func (r LinearRing) GetBSON() (interface{}, error) {
 	return LinearRingView{"LinearRing", r.AsArray()}, nil
@@ -201,8 +200,8 @@ func (r *LinearRing) SetBSON(raw bson.Raw) error {
 */
 
 func Slice2LineString(ffSlice [][]float64) (LineString, error) {
-	if len(ffSlice) < 3 {
-		return nil, errors.New("LineString of wrong dimension. Should have at least 3 Points")
+	if len(ffSlice) < 2 {
+		return nil, errors.New("LineString of wrong dimension. Should have at least 2 Points")
 	}
 
 	ls := LineString{}
@@ -260,44 +259,22 @@ func ExtractWKTLinearRing(in string) (LinearRing, error) {
 	return ring, nil
 }
 
+// ExtractWKBLineString reads the XY points of a WKB LineString following
+// its header.
 func ExtractWKBLineString(buf *bytes.Buffer, end binary.ByteOrder) (LineString, error) {
-	var numPoints uint32
-	err := binary.Read(buf, end, &numPoints)
+	g, err := extractWKB(buf, end, 2)
 	if err != nil {
 		return nil, err
 	}
-
-	ls := make([]Point, int(numPoints))
-
-	for i := 0; i < int(numPoints); i++ {
-		point, err := ExtractWKBPoint(buf, end)
-		if err != nil {
-			return nil, err
-		}
-		ls[i] = *point
-	}
-
-	return LineString(ls), nil
+	return *g.(*LineString), nil
 }
 
+// ExtractWKBLinearRing reads the XY points of a ring of a WKB Polygon,
+// dropping the closing point.
 func ExtractWKBLinearRing(buf *bytes.Buffer, end binary.ByteOrder) (LinearRing, error) {
-	var numPoints uint32
-	err := binary.Read(buf, end, &numPoints)
+	l, err := ExtractWKBLineString(buf, end)
 	if err != nil {
 		return nil, err
 	}
-
-	lr := make([]Point, int(numPoints)-1)
-
-	for i := 0; i < int(numPoints); i++ {
-		point, err := ExtractWKBPoint(buf, end)
-		if err != nil {
-			return nil, err
-		}
-		if i < int(numPoints)-1 {
-			lr[i] = *point
-		}
-	}
-
-	return LinearRing(lr), nil
+	return wkbRing(l), nil
 }
@@ -0,0 +1,480 @@
+package geometry
+
+import (
+	"math"
+	"sort"
+)
+
+// MakeValid repairs an invalid Polygon, returning a valid MultiPolygon
+// covering the same area. Self-intersecting rings are split at their
+// crossings, duplicate vertices removed and rings reoriented so shells
+// are counter-clockwise and holes clockwise.
+func (p *Polygon) MakeValid() MultiPolygon {
+	return makeValid([]Polygon{*p})
+}
+
+// MakeValid repairs an invalid MultiPolygon. Overlapping parts are merged
+// so the result is a valid MultiPolygon covering the union of the parts.
+func (m *MultiPolygon) MakeValid() MultiPolygon {
+	return makeValid(*m)
+}
+
+type segment struct {
+	a, b   Point
+	poly   int
+	splits []Point
+}
+
+type edge struct {
+	a, b int
+}
+
+// planarGraph holds the noded linework of a set of rings.
+type planarGraph struct {
+	tol   float64
+	nodes []Point
+	orig  []bool
+	grid  map[[2]int64][]int
+	edges []edge
+}
+
+func makeValid(polys []Polygon) MultiPolygon {
+	segs := []*segment{}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for k, poly := range polys {
+		for _, ring := range poly {
+			for i := range ring {
+				a, b := ring[i], ring[(i+1)%len(ring)]
+				minX, maxX = math.Min(minX, a.X), math.Max(maxX, a.X)
+				minY, maxY = math.Min(minY, a.Y), math.Max(maxY, a.Y)
+				if a.X == b.X && a.Y == b.Y {
+					continue
+				}
+				segs = append(segs, &segment{a: a, b: b, poly: k})
+			}
+		}
+	}
+	if len(segs) == 0 {
+		return MultiPolygon{}
+	}
+
+	segIndex := NewRTree[int](0)
+	bounds := make([]Bounds, len(segs))
+	ids := make([]int, len(segs))
+	for i, s := range segs {
+		bounds[i], ids[i] = pointsBounds([]Point{s.a, s.b}), i
+	}
+	segIndex.BulkLoad(bounds, ids)
+
+	scale := math.Max(math.Max(maxX-minX, maxY-minY), math.Max(math.Abs(maxX), math.Abs(maxY)))
+	g := &planarGraph{tol: 1e-12 * math.Max(scale, 1), grid: map[[2]int64][]int{}}
+	g.node(segs, segIndex)
+
+	// inside counts the ring edges of each polygon crossed by a ray to the
+	// right of pt, the point being inside a polygon when the count is odd.
+	inside := func(pt Point) bool {
+		odd := map[int]bool{}
+		segIndex.Search(Bounds{MinX: pt.X, MinY: pt.Y, MaxX: math.Inf(1), MaxY: pt.Y}, func(_ Bounds, i int) bool {
+			a, b := segs[i].a, segs[i].b
+			if (a.Y > pt.Y) != (b.Y > pt.Y) && pt.X < (b.X-a.X)*(pt.Y-a.Y)/(b.Y-a.Y)+a.X {
+				odd[segs[i].poly] = !odd[segs[i].poly]
+			}
+			return true
+		})
+		for _, o := range odd {
+			if o {
+				return true
+			}
+		}
+		return false
+	}
+
+	edgeIndex := NewRTree[int](0)
+	bounds = make([]Bounds, len(g.edges))
+	ids = make([]int, len(g.edges))
+	for i, e := range g.edges {
+		bounds[i], ids[i] = pointsBounds([]Point{g.nodes[e.a], g.nodes[e.b]}), i
+	}
+	edgeIndex.BulkLoad(bounds, ids)
+
+	// Keep the edges separating the interior from the exterior, directed
+	// so that the interior lies on their left.
+	bound := []edge{}
+	for i, e := range g.edges {
+		a, b := g.nodes[e.a], g.nodes[e.b]
+		dx, dy := b.X-a.X, b.Y-a.Y
+		l := math.Hypot(dx, dy)
+		mid := Point{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}
+		// Sample either side of the edge closer than the nearest other
+		// edge.
+		dist := func(j int) float64 {
+			if j == i {
+				return math.Inf(1)
+			}
+			return segmentDistance(mid, g.nodes[g.edges[j].a], g.nodes[g.edges[j].b])
+		}
+		off := l / 2
+		if near := edgeIndex.NearestFunc(mid, 1, dist); len(near) > 0 && near[0] != i {
+			off = math.Min(off, dist(near[0]))
+		}
+		off /= 2
+		nx, ny := -dy/l*off, dx/l*off
+		left := inside(Point{X: mid.X + nx, Y: mid.Y + ny})
+		right := inside(Point{X: mid.X - nx, Y: mid.Y - ny})
+		switch {
+		case left && !right:
+			bound = append(bound, e)
+		case right && !left:
+			bound = append(bound, edge{e.b, e.a})
+		}
+	}
+
+	return g.polygons(bound, false)
+}
+
+func (g *planarGraph) cell(v float64) int64 {
+	return int64(math.Floor(v / g.tol))
+}
+
+// addNode returns the index of the node at pt, merging it with any
+// existing node closer than the graph tolerance.
+func (g *planarGraph) addNode(pt Point, orig bool) int {
+	cx, cy := g.cell(pt.X), g.cell(pt.Y)
+	for i := cx - 1; i <= cx+1; i++ {
+		for j := cy - 1; j <= cy+1; j++ {
+			for _, n := range g.grid[[2]int64{i, j}] {
+				if math.Abs(g.nodes[n].X-pt.X) <= g.tol && math.Abs(g.nodes[n].Y-pt.Y) <= g.tol {
+					g.orig[n] = g.orig[n] || orig
+					return n
+				}
+			}
+		}
+	}
+	g.nodes = append(g.nodes, pt)
+	g.orig = append(g.orig, orig)
+	g.grid[[2]int64{cx, cy}] = append(g.grid[[2]int64{cx, cy}], len(g.nodes)-1)
+	return len(g.nodes) - 1
+}
+
+// node splits the segments at every mutual intersection and builds the
+// set of unique edges between the resulting nodes.
+func (g *planarGraph) node(segs []*segment, index *RTree[int]) {
+	for i, s := range segs {
+		b := pointsBounds([]Point{s.a, s.b})
+		b = Bounds{MinX: b.MinX - g.tol, MinY: b.MinY - g.tol, MaxX: b.MaxX + g.tol, MaxY: b.MaxY + g.tol}
+		index.Search(b, func(_ Bounds, j int) bool {
+			if j > i {
+				intersectSegments(s, segs[j], g.tol)
+			}
+			return true
+		})
+	}
+
+	seen := map[edge]bool{}
+	for _, s := range segs {
+		dx, dy := s.b.X-s.a.X, s.b.Y-s.a.Y
+		pts := append([]Point{s.a}, s.splits...)
+		pts = append(pts, s.b)
+		sort.SliceStable(pts, func(i, j int) bool {
+			return (pts[i].X-s.a.X)*dx+(pts[i].Y-s.a.Y)*dy < (pts[j].X-s.a.X)*dx+(pts[j].Y-s.a.Y)*dy
+		})
+		prev := g.addNode(pts[0], true)
+		for k, pt := range pts[1:] {
+			n := g.addNode(pt, k == len(pts)-2)
+			if n == prev {
+				continue
+			}
+			key := edge{prev, n}
+			if n < prev {
+				key = edge{n, prev}
+			}
+			if !seen[key] {
+				seen[key] = true
+				g.edges = append(g.edges, edge{prev, n})
+			}
+			prev = n
+		}
+	}
+}
+
+func intersectSegments(s, t *segment, tol float64) {
+	rx, ry := s.b.X-s.a.X, s.b.Y-s.a.Y
+	sx, sy := t.b.X-t.a.X, t.b.Y-t.a.Y
+	qx, qy := t.a.X-s.a.X, t.a.Y-s.a.Y
+	d := rx*sy - ry*sx
+	if math.Abs(d) <= tol*tol {
+		if math.Abs(qx*ry-qy*rx) > tol*math.Hypot(rx, ry) {
+			return
+		}
+		// Collinear segments: each splits at the other's end points.
+		for _, pt := range []Point{t.a, t.b} {
+			if onSegmentInterior(pt, s.a, s.b, tol) {
+				s.splits = append(s.splits, pt)
+			}
+		}
+		for _, pt := range []Point{s.a, s.b} {
+			if onSegmentInterior(pt, t.a, t.b, tol) {
+				t.splits = append(t.splits, pt)
+			}
+		}
+		return
+	}
+
+	u := (qx*sy - qy*sx) / d
+	v := (qx*ry - qy*rx) / d
+	eu := tol / math.Hypot(rx, ry)
+	ev := tol / math.Hypot(sx, sy)
+	if u < -eu || u > 1+eu || v < -ev || v > 1+ev {
+		return
+	}
+
+	var pt Point
+	switch {
+	case u <= eu:
+		pt = s.a
+	case u >= 1-eu:
+		pt = s.b
+	case v <= ev:
+		pt = t.a
+	case v >= 1-ev:
+		pt = t.b
+	default:
+		pt = Point{X: s.a.X + u*rx, Y: s.a.Y + u*ry, Z: s.a.Z + u*(s.b.Z-s.a.Z)}
+	}
+	if u > eu && u < 1-eu {
+		s.splits = append(s.splits, pt)
+	}
+	if v > ev && v < 1-ev {
+		t.splits = append(t.splits, pt)
+	}
+}
+
+func onSegmentInterior(pt, a, b Point, tol float64) bool {
+	if (pt.X == a.X && pt.Y == a.Y) || (pt.X == b.X && pt.Y == b.Y) {
+		return false
+	}
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := ((pt.X-a.X)*dx + (pt.Y-a.Y)*dy) / (dx*dx + dy*dy)
+	return t > 0 && t < 1 && segmentDistance(pt, a, b) <= tol
+}
+
+// polygons assembles directed boundary edges, interior on the left, into
+// polygons. Rings touching themselves at a node are split into simple
+// rings unless joinPinches is set.
+func (g *planarGraph) polygons(bound []edge, joinPinches bool) MultiPolygon {
+	shells, holes := [][]int{}, [][]int{}
+	for _, ring := range g.traceRings(bound, joinPinches) {
+		rings := [][]int{ring}
+		if !joinPinches {
+			rings = splitRing(ring)
+		}
+		for _, r := range rings {
+			r = g.dropCollinear(r)
+			if len(r) < 3 {
+				continue
+			}
+			switch a := g.area(r); {
+			case a > 0:
+				shells = append(shells, r)
+			case a < 0:
+				holes = append(holes, r)
+			}
+		}
+	}
+
+	out := make(MultiPolygon, len(shells))
+	shellRings := make([]LinearRing, len(shells))
+	shellAreas := make([]float64, len(shells))
+	for i, s := range shells {
+		shellRings[i] = g.ring(s)
+		shellAreas[i] = g.area(s)
+		out[i] = Polygon{shellRings[i]}
+	}
+	for _, h := range holes {
+		// A hole belongs to the smallest shell larger than it containing a
+		// point strictly inside the hole, which cannot lie on the boundary
+		// of any such shell.
+		pt := g.interiorPoint(h)
+		area := -g.area(h)
+		best := -1
+		for i, s := range shellRings {
+			if shellAreas[i] > area && pointInRing(pt, s) && (best < 0 || shellAreas[i] < shellAreas[best]) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			out[best] = append(out[best], g.ring(h))
+		}
+	}
+
+	return out
+}
+
+// traceRings walks the directed boundary edges into closed rings, turning
+// as tightly as possible at every node, or as widely as possible if wide
+// is set.
+func (g *planarGraph) traceRings(bound []edge, wide bool) [][]int {
+	out := map[int][]int{}
+	for i, e := range bound {
+		out[e.a] = append(out[e.a], i)
+	}
+	used := make([]bool, len(bound))
+	angle := func(from, to int) float64 {
+		return math.Atan2(g.nodes[to].Y-g.nodes[from].Y, g.nodes[to].X-g.nodes[from].X)
+	}
+
+	rings := [][]int{}
+	for i := range bound {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		start := bound[i].a
+		ring := []int{start}
+		prev, cur := start, bound[i].b
+		// A ring is closed on returning to its start node, unless wide is
+		// set, when the walk may pass through the start node and only
+		// ends on taking the starting edge again.
+		for wide || cur != start {
+			back := angle(cur, prev)
+			next, best := -1, math.Inf(1)
+			for _, j := range out[cur] {
+				if used[j] && j != i {
+					continue
+				}
+				turn := back - angle(cur, bound[j].b)
+				if turn <= 0 {
+					turn += 2 * math.Pi
+				}
+				if wide {
+					turn = -turn
+				}
+				if turn < best {
+					next, best = j, turn
+				}
+			}
+			if next < 0 || next == i {
+				break
+			}
+			ring = append(ring, cur)
+			used[next] = true
+			prev, cur = cur, bound[next].b
+		}
+		rings = append(rings, ring)
+	}
+
+	return rings
+}
+
+// interiorPoint returns a point inside the region enclosed by a simple
+// ring, offset from the middle of its longest edge towards the enclosed
+// side by less than the distance to the ring's other edges.
+func (g *planarGraph) interiorPoint(ids []int) Point {
+	r := g.ring(ids)
+	longest, l := 0, 0.0
+	for i := range r {
+		a, b := r[i], r[(i+1)%len(r)]
+		if d := math.Hypot(b.X-a.X, b.Y-a.Y); d > l {
+			longest, l = i, d
+		}
+	}
+	a, b := r[longest], r[(longest+1)%len(r)]
+	mid := Point{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}
+	off := l / 2
+	for i := range r {
+		if i != longest {
+			off = math.Min(off, segmentDistance(mid, r[i], r[(i+1)%len(r)]))
+		}
+	}
+	off /= 2
+	// The enclosed side is on the left of a counter-clockwise ring.
+	if signedArea(r) < 0 {
+		off = -off
+	}
+	return Point{X: mid.X - (b.Y-a.Y)/l*off, Y: mid.Y + (b.X-a.X)/l*off}
+}
+
+// splitRing breaks a ring that revisits a node into simple rings.
+func splitRing(ring []int) [][]int {
+	out := [][]int{}
+	path := []int{}
+	pos := map[int]int{}
+	for _, n := range append(ring, ring[0]) {
+		if i, ok := pos[n]; ok {
+			loop := append([]int{}, path[i:]...)
+			for _, m := range loop {
+				delete(pos, m)
+			}
+			path = path[:i]
+			out = append(out, loop)
+		}
+		pos[n] = len(path)
+		path = append(path, n)
+	}
+	return out
+}
+
+// dropCollinear removes nodes introduced by noding that lie on a straight
+// line between their neighbours.
+func (g *planarGraph) dropCollinear(ring []int) []int {
+	for changed := true; changed && len(ring) > 3; {
+		changed = false
+		for i := 0; i < len(ring) && len(ring) > 3; i++ {
+			n := ring[i]
+			if g.orig[n] {
+				continue
+			}
+			a, b, c := g.nodes[ring[(i+len(ring)-1)%len(ring)]], g.nodes[n], g.nodes[ring[(i+1)%len(ring)]]
+			if math.Abs((b.X-a.X)*(c.Y-a.Y)-(b.Y-a.Y)*(c.X-a.X)) <= g.tol*math.Hypot(c.X-a.X, c.Y-a.Y) {
+				ring = append(ring[:i:i], ring[i+1:]...)
+				changed = true
+			}
+		}
+	}
+	return ring
+}
+
+func (g *planarGraph) ring(ids []int) LinearRing {
+	r := make(LinearRing, len(ids))
+	for i, n := range ids {
+		r[i] = g.nodes[n]
+	}
+	return r
+}
+
+func (g *planarGraph) area(ids []int) float64 {
+	return signedArea(g.ring(ids))
+}
+
+// signedArea returns the area of the ring, positive when the ring is
+// oriented counter-clockwise.
+func signedArea(r LinearRing) float64 {
+	a := 0.0
+	for i := range r {
+		p, q := r[i], r[(i+1)%len(r)]
+		a += p.X*q.Y - q.X*p.Y
+	}
+	return a / 2
+}
+
+func pointInRing(pt Point, r LinearRing) bool {
+	in := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[i], r[j]
+		if (a.Y > pt.Y) != (b.Y > pt.Y) && pt.X < (b.X-a.X)*(pt.Y-a.Y)/(b.Y-a.Y)+a.X {
+			in = !in
+		}
+	}
+	return in
+}
+
+func segmentDistance(pt, a, b Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	l2 := dx*dx + dy*dy
+	if l2 == 0 {
+		return math.Hypot(pt.X-a.X, pt.Y-a.Y)
+	}
+	t := math.Max(0, math.Min(1, ((pt.X-a.X)*dx+(pt.Y-a.Y)*dy)/l2))
+	return math.Hypot(pt.X-a.X-t*dx, pt.Y-a.Y-t*dy)
+}
@@ -0,0 +1,148 @@
+package geometry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type MultiLineString []LineString
+
+type MultiLineStringView struct {
+	Type   string        `json:"type" bson:"type"`
+	Coords [][][]float64 `json:"coordinates" bson:"coordinates"`
+}
+
+func (m MultiLineString) Equals(n MultiLineString) bool {
+	if len(m) != len(n) {
+		return false
+	}
+	for i, l := range m {
+		if !l.Equals(n[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *MultiLineString) AsArray() [][][]float64 {
+	out := [][][]float64{}
+
+	for _, l := range *m {
+		out = append(out, l.AsArray())
+	}
+
+	return out
+}
+
+func (m *MultiLineString) WKB(end binary.ByteOrder) []byte {
+	return wkbBody(m, end)
+}
+
+func (m *MultiLineString) WKT() string {
+	out := "("
+
+	for i, l := range *m {
+		if i > 0 {
+			out += ","
+		}
+		out += l.WKT()
+	}
+	out += ")"
+
+	return out
+}
+
+func (m *MultiLineString) MarshalWKB(mode uint8) []byte {
+	return marshalWKB(m, mode)
+}
+
+func (m *MultiLineString) UnmarshalWKB(in []byte) error {
+	g, err := unmarshalWKB(in)
+	if err != nil {
+		return err
+	}
+	multi, ok := g.(*MultiLineString)
+	if !ok {
+		return errors.New("Not a MultiLineString")
+	}
+	*m = *multi
+
+	return nil
+}
+
+func (m *MultiLineString) MarshalWKT() string {
+	return fmt.Sprintf("MULTILINESTRING %s", m.WKT())
+}
+
+func (m *MultiLineString) UnmarshalWKT(in string) error {
+	//MULTILINESTRING ((4 9.5, 2 9.5, 4 5.5), (8 9.5, 6 9.5))
+	regExp := `^MULTILINESTRING\s+(?P<multilinestring>\(\(.*\)\))$`
+
+	r := regexp.MustCompile(regExp)
+	match := r.FindStringSubmatch(in)
+	if match == nil {
+		return fmt.Errorf("Not a MultiLineString: %s", in)
+	}
+	var err error
+	*m, err = ExtractWKTMultiLineString(match[1])
+
+	return err
+}
+
+func (m *MultiLineString) MarshalJSON() ([]byte, error) {
+	mExp := MultiLineStringView{"MultiLineString", m.AsArray()}
+	return json.Marshal(mExp)
+}
+
+func (m *MultiLineString) UnmarshalJSON(in []byte) error {
+	mView := MultiLineStringView{}
+	err := json.Unmarshal(in, &mView)
+	if err != nil {
+		return err
+	}
+	*m, err = Slice2MultiLineString(mView.Coords)
+
+	return err
+}
+
+func Slice2MultiLineString(fffSlice [][][]float64) (MultiLineString, error) {
+	m := MultiLineString{}
+	for _, ffSlice := range fffSlice {
+		l, err := Slice2LineString(ffSlice)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, l)
+	}
+
+	return m, nil
+}
+
+func ExtractWKTMultiLineString(in string) (MultiLineString, error) {
+	//((4 9.5, 2 9.5, 4 5.5), (8 9.5, 6 9.5))
+	lines := strings.SplitAfter(strings.TrimSuffix(strings.TrimPrefix(in, "("), ")"), "),")
+	m := MultiLineString{}
+	for _, lineStr := range lines {
+		l, err := ExtractWKTLineString(strings.Trim(lineStr, ", "))
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, l)
+	}
+
+	return m, nil
+}
+
+// ExtractWKBMultiLineString reads the parts of a WKB MultiLineString following its header.
+func ExtractWKBMultiLineString(buf *bytes.Buffer, end binary.ByteOrder) (MultiLineString, error) {
+	g, err := extractWKB(buf, end, 5)
+	if err != nil {
+		return nil, err
+	}
+	return *g.(*MultiLineString), nil
+}
@@ -0,0 +1,148 @@
+package geometry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type MultiPoint []Point
+
+type MultiPointView struct {
+	Type   string      `json:"type" bson:"type"`
+	Coords [][]float64 `json:"coordinates" bson:"coordinates"`
+}
+
+func (m MultiPoint) Equals(n MultiPoint) bool {
+	if len(m) != len(n) {
+		return false
+	}
+	for i, p := range m {
+		if !p.Equals(n[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *MultiPoint) AsArray() [][]float64 {
+	out := [][]float64{}
+
+	for _, p := range *m {
+		out = append(out, p.AsArray())
+	}
+
+	return out
+}
+
+func (m *MultiPoint) WKB(end binary.ByteOrder) []byte {
+	return wkbBody(m, end)
+}
+
+func (m *MultiPoint) WKT() string {
+	out := "("
+
+	for i, p := range *m {
+		if i > 0 {
+			out += ","
+		}
+		out += fmt.Sprintf("(%s)", p.WKT())
+	}
+	out += ")"
+
+	return out
+}
+
+func (m *MultiPoint) MarshalWKB(mode uint8) []byte {
+	return marshalWKB(m, mode)
+}
+
+func (m *MultiPoint) UnmarshalWKB(in []byte) error {
+	g, err := unmarshalWKB(in)
+	if err != nil {
+		return err
+	}
+	multi, ok := g.(*MultiPoint)
+	if !ok {
+		return errors.New("Not a MultiPoint")
+	}
+	*m = *multi
+
+	return nil
+}
+
+func (m *MultiPoint) MarshalWKT() string {
+	return fmt.Sprintf("MULTIPOINT %s", m.WKT())
+}
+
+func (m *MultiPoint) UnmarshalWKT(in string) error {
+	//MULTIPOINT ((4 9.5), (2 9.5)) or MULTIPOINT (4 9.5, 2 9.5)
+	regExp := `^MULTIPOINT\s+(?P<multipoint>\(.*\))$`
+
+	r := regexp.MustCompile(regExp)
+	match := r.FindStringSubmatch(in)
+	if match == nil {
+		return fmt.Errorf("Not a MultiPoint: %s", in)
+	}
+	var err error
+	*m, err = ExtractWKTMultiPoint(match[1])
+
+	return err
+}
+
+func (m *MultiPoint) MarshalJSON() ([]byte, error) {
+	mExp := MultiPointView{"MultiPoint", m.AsArray()}
+	return json.Marshal(mExp)
+}
+
+func (m *MultiPoint) UnmarshalJSON(in []byte) error {
+	mView := MultiPointView{}
+	err := json.Unmarshal(in, &mView)
+	if err != nil {
+		return err
+	}
+	*m, err = Slice2MultiPoint(mView.Coords)
+
+	return err
+}
+
+func Slice2MultiPoint(ffSlice [][]float64) (MultiPoint, error) {
+	m := MultiPoint{}
+	for _, fSlice := range ffSlice {
+		p, err := Slice2Point(fSlice)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, *p)
+	}
+
+	return m, nil
+}
+
+func ExtractWKTMultiPoint(in string) (MultiPoint, error) {
+	//((4 9.5), (2 9.5)) or (4 9.5, 2 9.5)
+	points := strings.Split(strings.TrimSuffix(strings.TrimPrefix(in, "("), ")"), ",")
+	m := MultiPoint{}
+	for _, pointStr := range points {
+		p, err := ExtractWKTPoint(strings.Trim(pointStr, " ()"))
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, *p)
+	}
+
+	return m, nil
+}
+
+// ExtractWKBMultiPoint reads the parts of a WKB MultiPoint following its header.
+func ExtractWKBMultiPoint(buf *bytes.Buffer, end binary.ByteOrder) (MultiPoint, error) {
+	g, err := extractWKB(buf, end, 4)
+	if err != nil {
+		return nil, err
+	}
+	return *g.(*MultiPoint), nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -17,6 +18,9 @@ type MultiPolygonView struct {
 }
 
 func (m MultiPolygon) Equals(n MultiPolygon) bool {
+	if len(m) != len(n) {
+		return false
+	}
 	for i, p := range m {
 		if !p.Equals(n[i]) {
 			return false
@@ -36,13 +40,7 @@ func (m *MultiPolygon) AsArray() [][][][]float64 {
 }
 
 func (m *MultiPolygon) WKB(end binary.ByteOrder) []byte {
-	buf := new(bytes.Buffer)
-	numPolys := uint32(len(*m))
-	binary.Write(buf, end, &numPolys)
-	for _, p := range *m {
-		binary.Write(buf, end, p.WKB(end))
-	}
-	return buf.Bytes()
+	return wkbBody(m, end)
 }
 
 func (m *MultiPolygon) WKT() string {
@@ -60,37 +58,21 @@ func (m *MultiPolygon) WKT() string {
 }
 
 func (m *MultiPolygon) MarshalWKB(mode uint8) []byte {
-	buf := new(bytes.Buffer)
-	binary.Write(buf, endian[mode], &mode)
-
-	mId := uint32(6)
-	binary.Write(buf, endian[mode], &mId)
-
-	enc := m.WKB(endian[mode])
-	binary.Write(buf, endian[mode], &enc)
-
-	return buf.Bytes()
+	return marshalWKB(m, mode)
 }
 
 func (m *MultiPolygon) UnmarshalWKB(in []byte) error {
-	buf := bytes.NewBuffer(in)
-
-	var end uint8
-	err := binary.Read(buf, binary.BigEndian, &end)
+	g, err := unmarshalWKB(in)
 	if err != nil {
-		return fmt.Errorf("Problem reading geometry: %s", err)
+		return err
 	}
-
-	var wkbType uint32
-	//err = binary.Read(buf, binary.BigEndian, &wkbType)
-	err = binary.Read(buf, binary.LittleEndian, &wkbType)
-	if err != nil || wkbType != 6 {
-		return fmt.Errorf("Not a MultiPolygon: %s", err)
+	multi, ok := g.(*MultiPolygon)
+	if !ok {
+		return errors.New("Not a MultiPolygon")
 	}
+	*m = *multi
 
-	*m, err = ExtractWKBMultiPolygon(buf, endian[end])
-
-	return err
+	return nil
 }
 
 func (p *MultiPolygon) MarshalWKT() string {
@@ -128,6 +110,16 @@ func (m *MultiPolygon) SetBSON(raw bson.Raw) error {
 }
 */
 
+// ForceRHR returns a copy of the multipolygon with every part following
+// the RFC 7946 right-hand rule.
+func (m *MultiPolygon) ForceRHR() MultiPolygon {
+	out := make(MultiPolygon, len(*m))
+	for i, p := range *m {
+		out[i] = p.ForceRHR()
+	}
+	return out
+}
+
 func (m *MultiPolygon) MarshalJSON() ([]byte, error) {
 	mExp := MultiPolygonView{"MultiPolygon", m.AsArray()}
 	return json.Marshal(mExp)
@@ -169,22 +161,11 @@ func ExtractWKTMultiPolygon(in string) (MultiPolygon, error) {
 	return m, nil
 }
 
+// ExtractWKBMultiPolygon reads the parts of a WKB MultiPolygon following its header.
 func ExtractWKBMultiPolygon(buf *bytes.Buffer, end binary.ByteOrder) (MultiPolygon, error) {
-	var numPolys uint32
-	err := binary.Read(buf, end, &numPolys)
+	g, err := extractWKB(buf, end, 6)
 	if err != nil {
 		return nil, err
 	}
-
-	ps := make([]Polygon, int(numPolys))
-
-	for i := 0; i < int(numPolys); i++ {
-		poly, err := ExtractWKBPolygon(buf)
-		if err != nil {
-			return nil, err
-		}
-		ps[i] = poly
-	}
-
-	return MultiPolygon(ps), nil
+	return *g.(*MultiPolygon), nil
 }
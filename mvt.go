@@ -0,0 +1,695 @@
+package geometry
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MVTLayer is a named layer of a Mapbox Vector Tile. Extent is the number
+// of tile coordinate units across the tile, 4096 when zero.
+type MVTLayer struct {
+	Name     string
+	Extent   int
+	Features []Feature
+}
+
+// MaxMercatorLat is the latitude at which Web Mercator tiles end.
+const MaxMercatorLat = 85.0511287798066
+
+const (
+	mvtMoveTo    = 1
+	mvtLineTo    = 2
+	mvtClosePath = 7
+)
+
+const (
+	mvtPoint      = 1
+	mvtLineString = 2
+	mvtPolygon    = 3
+)
+
+// pbWriter appends protocol buffer fields to a byte slice.
+type pbWriter struct {
+	buf []byte
+}
+
+func (w *pbWriter) varint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *pbWriter) key(field, wire int) {
+	w.varint(uint64(field<<3 | wire))
+}
+
+func (w *pbWriter) uint(field int, v uint64) {
+	w.key(field, 0)
+	w.varint(v)
+}
+
+func (w *pbWriter) bytes(field int, b []byte) {
+	w.key(field, 2)
+	w.varint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *pbWriter) packed(field int, vs []uint32) {
+	var p pbWriter
+	for _, v := range vs {
+		p.varint(uint64(v))
+	}
+	w.bytes(field, p.buf)
+}
+
+// pbReader iterates over the fields of a protocol buffer message.
+type pbReader struct {
+	buf []byte
+	err error
+}
+
+func (r *pbReader) varint() uint64 {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = errors.New("MVT: bad varint")
+		r.buf = nil
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *pbReader) fixed(n int) []byte {
+	if len(r.buf) < n {
+		r.err = errors.New("MVT: message truncated")
+		r.buf = nil
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+// next returns the number and wire type of the next field, with its value
+// as a varint, or its bytes for length delimited and fixed width fields.
+func (r *pbReader) next() (int, int, uint64, []byte, bool) {
+	if len(r.buf) == 0 || r.err != nil {
+		return 0, 0, 0, nil, false
+	}
+	key := r.varint()
+	field, wire := int(key>>3), int(key&7)
+	switch wire {
+	case 0:
+		return field, wire, r.varint(), nil, r.err == nil
+	case 1:
+		return field, wire, 0, r.fixed(8), r.err == nil
+	case 2:
+		n := r.varint()
+		if n > uint64(len(r.buf)) {
+			r.err = errors.New("MVT: message truncated")
+			return 0, 0, 0, nil, false
+		}
+		return field, wire, 0, r.fixed(int(n)), r.err == nil
+	case 5:
+		return field, wire, 0, r.fixed(4), r.err == nil
+	}
+	r.err = fmt.Errorf("MVT: wire type %d not supported", wire)
+	return 0, 0, 0, nil, false
+}
+
+func unpack(b []byte) ([]uint32, error) {
+	r := pbReader{buf: b}
+	out := []uint32{}
+	for len(r.buf) > 0 && r.err == nil {
+		out = append(out, uint32(r.varint()))
+	}
+	return out, r.err
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// MercatorTileXY returns the position of a longitude and latitude in tile
+// units at zoom z, clamping the latitude to MaxMercatorLat.
+func MercatorTileXY(lon, lat float64, z int) (float64, float64) {
+	n := math.Exp2(float64(z))
+	lat = math.Max(-MaxMercatorLat, math.Min(MaxMercatorLat, lat))
+	x := (lon + 180) / 360 * n
+	y := (1 - math.Asinh(math.Tan(lat*deg))/math.Pi) / 2 * n
+	return x, y
+}
+
+// MercatorTileLonLat returns the longitude and latitude of a position in
+// tile units at zoom z.
+func MercatorTileLonLat(x, y float64, z int) (float64, float64) {
+	n := math.Exp2(float64(z))
+	return x/n*360 - 180, math.Atan(math.Sinh(math.Pi*(1-2*y/n))) / deg
+}
+
+// tileTransform converts longitude and latitude to the coordinates of
+// tile z/x/y with the given extent, Y pointing down.
+func tileTransform(z, x, y, extent int) func(Point) (Point, error) {
+	return func(p Point) (Point, error) {
+		wx, wy := MercatorTileXY(p.X, p.Y, z)
+		return Point{X: (wx - float64(x)) * float64(extent), Y: (wy - float64(y)) * float64(extent)}, nil
+	}
+}
+
+func tileInverse(z, x, y, extent int) func(Point) (Point, error) {
+	return func(p Point) (Point, error) {
+		lon, lat := MercatorTileLonLat(float64(x)+p.X/float64(extent), float64(y)+p.Y/float64(extent), z)
+		return Point{X: lon, Y: lat}, nil
+	}
+}
+
+// clipLine clips a line to the box, returning the parts inside it.
+func clipLine(l []Point, b Bounds) [][]Point {
+	parts := [][]Point{}
+	cur := []Point{}
+	for i := 0; i+1 < len(l); i++ {
+		p, q := l[i], l[i+1]
+		// Liang-Barsky clipping of the segment pq.
+		t0, t1 := 0.0, 1.0
+		dx, dy := q.X-p.X, q.Y-p.Y
+		ok := true
+		for _, e := range [4][2]float64{{-dx, p.X - b.MinX}, {dx, b.MaxX - p.X}, {-dy, p.Y - b.MinY}, {dy, b.MaxY - p.Y}} {
+			if e[0] == 0 {
+				if e[1] < 0 {
+					ok = false
+				}
+				continue
+			}
+			r := e[1] / e[0]
+			if e[0] < 0 {
+				t0 = math.Max(t0, r)
+			} else {
+				t1 = math.Min(t1, r)
+			}
+		}
+		if !ok || t0 > t1 {
+			if len(cur) > 1 {
+				parts = append(parts, cur)
+			}
+			cur = []Point{}
+			continue
+		}
+		a := Point{X: p.X + t0*dx, Y: p.Y + t0*dy}
+		c := Point{X: p.X + t1*dx, Y: p.Y + t1*dy}
+		if len(cur) == 0 || cur[len(cur)-1] != a {
+			if len(cur) > 1 {
+				parts = append(parts, cur)
+			}
+			cur = []Point{a}
+		}
+		cur = append(cur, c)
+		if t1 < 1 {
+			parts = append(parts, cur)
+			cur = []Point{}
+		}
+	}
+	if len(cur) > 1 {
+		parts = append(parts, cur)
+	}
+	return parts
+}
+
+// mvtGeometry clips a geometry in tile coordinates to the box and encodes
+// it as MVT commands, returning the geometry type and no commands if
+// nothing is left.
+func mvtGeometry(g Geometry, b Bounds) (int, []uint32, error) {
+	var cx, cy int64
+	cmds := []uint32{}
+	// path encodes integer points, the first with a MoveTo and the rest
+	// with a LineTo.
+	path := func(pts [][2]int64, closed bool) {
+		cmds = append(cmds, mvtMoveTo|1<<3, uint32(zigzag(pts[0][0]-cx)), uint32(zigzag(pts[0][1]-cy)))
+		cx, cy = pts[0][0], pts[0][1]
+		cmds = append(cmds, mvtLineTo|uint32(len(pts)-1)<<3)
+		for _, p := range pts[1:] {
+			cmds = append(cmds, uint32(zigzag(p[0]-cx)), uint32(zigzag(p[1]-cy)))
+			cx, cy = p[0], p[1]
+		}
+		if closed {
+			cmds = append(cmds, mvtClosePath|1<<3)
+		}
+	}
+	round := func(pts []Point) [][2]int64 {
+		out := [][2]int64{}
+		for _, p := range pts {
+			q := [2]int64{int64(math.Round(p.X)), int64(math.Round(p.Y))}
+			if len(out) == 0 || out[len(out)-1] != q {
+				out = append(out, q)
+			}
+		}
+		return out
+	}
+
+	switch t := g.(type) {
+	case *Point, *MultiPoint:
+		pts := []Point{}
+		if p, ok := t.(*Point); ok {
+			pts = append(pts, *p)
+		} else {
+			pts = append(pts, *t.(*MultiPoint)...)
+		}
+		inside := [][2]int64{}
+		for _, p := range pts {
+			if p.X >= b.MinX && p.X <= b.MaxX && p.Y >= b.MinY && p.Y <= b.MaxY {
+				inside = append(inside, [2]int64{int64(math.Round(p.X)), int64(math.Round(p.Y))})
+			}
+		}
+		if len(inside) > 0 {
+			cmds = append(cmds, mvtMoveTo|uint32(len(inside))<<3)
+			for _, p := range inside {
+				cmds = append(cmds, uint32(zigzag(p[0]-cx)), uint32(zigzag(p[1]-cy)))
+				cx, cy = p[0], p[1]
+			}
+		}
+		return mvtPoint, cmds, nil
+
+	case *LineString, *MultiLineString:
+		lines := MultiLineString{}
+		if l, ok := t.(*LineString); ok {
+			lines = append(lines, *l)
+		} else {
+			lines = *t.(*MultiLineString)
+		}
+		for _, l := range lines {
+			for _, part := range clipLine(l, b) {
+				if pts := round(part); len(pts) > 1 {
+					path(pts, false)
+				}
+			}
+		}
+		return mvtLineString, cmds, nil
+
+	case *Polygon, *MultiPolygon:
+		polys := MultiPolygon{}
+		if p, ok := t.(*Polygon); ok {
+			polys = append(polys, *p)
+		} else {
+			polys = *t.(*MultiPolygon)
+		}
+		for _, p := range polys {
+			for i, r := range p {
+				for _, c := range [4][3]float64{{0, b.MinX, 1}, {0, b.MaxX, -1}, {1, b.MinY, 1}, {1, b.MaxY, -1}} {
+					r = clipAxis(r, int(c[0]), c[1], c[2])
+				}
+				pts := round(r)
+				for len(pts) > 1 && pts[len(pts)-1] == pts[0] {
+					pts = pts[:len(pts)-1]
+				}
+				ring := make(LinearRing, len(pts))
+				for j, q := range pts {
+					ring[j] = Point{X: float64(q[0]), Y: float64(q[1])}
+				}
+				area := signedArea(ring)
+				if len(pts) < 3 || area == 0 {
+					if i == 0 {
+						break
+					}
+					continue
+				}
+				// Exterior rings have positive area in tile coordinates,
+				// interior rings negative.
+				if (i == 0) != (area > 0) {
+					for a, z := 0, len(pts)-1; a < z; a, z = a+1, z-1 {
+						pts[a], pts[z] = pts[z], pts[a]
+					}
+				}
+				path(pts, true)
+			}
+		}
+		return mvtPolygon, cmds, nil
+	}
+	return 0, nil, fmt.Errorf("Geometry %T not supported in MVT", g)
+}
+
+type mvtValue struct {
+	wire  int
+	field int
+	v     uint64
+	s     string
+}
+
+func mvtValueOf(v interface{}) (mvtValue, bool) {
+	switch t := v.(type) {
+	case nil:
+		return mvtValue{}, false
+	case string:
+		return mvtValue{wire: 2, field: 1, s: t}, true
+	case float32:
+		return mvtValue{wire: 5, field: 2, v: uint64(math.Float32bits(t))}, true
+	case float64:
+		return mvtValue{wire: 1, field: 3, v: math.Float64bits(t)}, true
+	case bool:
+		if t {
+			return mvtValue{field: 7, v: 1}, true
+		}
+		return mvtValue{field: 7}, true
+	case int, int8, int16, int32, int64:
+		return mvtValue{field: 6, v: zigzag(toInt64(t))}, true
+	case uint, uint8, uint16, uint32, uint64:
+		return mvtValue{field: 5, v: toUint64(t)}, true
+	}
+	return mvtValue{wire: 2, field: 1, s: fmt.Sprint(v)}, true
+}
+
+func toInt64(v interface{}) int64 {
+	switch t := v.(type) {
+	case int:
+		return int64(t)
+	case int8:
+		return int64(t)
+	case int16:
+		return int64(t)
+	case int32:
+		return int64(t)
+	}
+	return v.(int64)
+}
+
+func toUint64(v interface{}) uint64 {
+	switch t := v.(type) {
+	case uint:
+		return uint64(t)
+	case uint8:
+		return uint64(t)
+	case uint16:
+		return uint64(t)
+	case uint32:
+		return uint64(t)
+	}
+	return v.(uint64)
+}
+
+func (v mvtValue) encode() []byte {
+	var w pbWriter
+	switch v.wire {
+	case 0:
+		w.uint(v.field, v.v)
+	case 1:
+		w.key(v.field, 1)
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, v.v)
+	case 2:
+		w.bytes(v.field, []byte(v.s))
+	case 5:
+		w.key(v.field, 5)
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(v.v))
+	}
+	return w.buf
+}
+
+// EncodeMVT encodes layers of features with longitude and latitude
+// coordinates as Mapbox Vector Tile z/x/y. Geometries are projected to
+// Web Mercator, scaled to the layer extent and clipped to the tile widened
+// by buffer units on each side. Features left empty by clipping are
+// dropped. Properties are stored in the layer key and value tables, nil
+// values being left out and types other than strings, numbers and bools
+// written as strings.
+func EncodeMVT(layers []MVTLayer, z, x, y, buffer int) ([]byte, error) {
+	var tile pbWriter
+	for _, l := range layers {
+		extent := l.Extent
+		if extent <= 0 {
+			extent = 4096
+		}
+		toTile := tileTransform(z, x, y, extent)
+		clip := Bounds{float64(-buffer), float64(-buffer), float64(extent + buffer), float64(extent + buffer)}
+
+		var layer pbWriter
+		layer.uint(15, 2)
+		layer.bytes(1, []byte(l.Name))
+		keys, values := map[string]int{}, map[mvtValue]int{}
+		keyList, valueList := []string{}, []mvtValue{}
+		for _, f := range l.Features {
+			if f.Geometry == nil {
+				continue
+			}
+			g, err := mapGeometry(f.Geometry, toTile)
+			if err != nil {
+				return nil, err
+			}
+			typ, cmds, err := mvtGeometry(g, clip)
+			if err != nil {
+				return nil, err
+			}
+			if len(cmds) == 0 {
+				continue
+			}
+
+			names := make([]string, 0, len(f.Properties))
+			for k := range f.Properties {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			tags := []uint32{}
+			for _, k := range names {
+				v, ok := mvtValueOf(f.Properties[k])
+				if !ok {
+					continue
+				}
+				if _, ok := keys[k]; !ok {
+					keys[k] = len(keyList)
+					keyList = append(keyList, k)
+				}
+				if _, ok := values[v]; !ok {
+					values[v] = len(valueList)
+					valueList = append(valueList, v)
+				}
+				tags = append(tags, uint32(keys[k]), uint32(values[v]))
+			}
+
+			var feat pbWriter
+			if len(tags) > 0 {
+				feat.packed(2, tags)
+			}
+			feat.uint(3, uint64(typ))
+			feat.packed(4, cmds)
+			layer.bytes(2, feat.buf)
+		}
+		for _, k := range keyList {
+			layer.bytes(3, []byte(k))
+		}
+		for _, v := range valueList {
+			layer.bytes(4, v.encode())
+		}
+		layer.uint(5, uint64(extent))
+		tile.bytes(3, layer.buf)
+	}
+	return tile.buf, nil
+}
+
+// DecodeMVT decodes the layers of Mapbox Vector Tile z/x/y, converting
+// geometries back to longitude and latitude. Numeric property values are
+// read as float64, int64 or uint64 depending on their encoded type, so
+// signed Go integers written by EncodeMVT come back as int64 and unsigned
+// ones as uint64.
+func DecodeMVT(in []byte, z, x, y int) ([]MVTLayer, error) {
+	layers := []MVTLayer{}
+	tile := pbReader{buf: in}
+	for {
+		field, _, _, b, ok := tile.next()
+		if !ok {
+			break
+		}
+		if field != 3 {
+			continue
+		}
+		l, err := decodeMVTLayer(b, z, x, y)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, l)
+	}
+	return layers, tile.err
+}
+
+func decodeMVTLayer(in []byte, z, x, y int) (MVTLayer, error) {
+	l := MVTLayer{Extent: 4096, Features: []Feature{}}
+	keys, values := []string{}, []interface{}{}
+	feats := [][]byte{}
+	r := pbReader{buf: in}
+	for {
+		field, _, v, b, ok := r.next()
+		if !ok {
+			break
+		}
+		switch field {
+		case 1:
+			l.Name = string(b)
+		case 2:
+			feats = append(feats, b)
+		case 3:
+			keys = append(keys, string(b))
+		case 4:
+			val, err := decodeMVTValue(b)
+			if err != nil {
+				return l, err
+			}
+			values = append(values, val)
+		case 5:
+			l.Extent = int(v)
+		}
+	}
+	if r.err != nil {
+		return l, r.err
+	}
+
+	toLonLat := tileInverse(z, x, y, l.Extent)
+	for _, fb := range feats {
+		var tags, cmds []uint32
+		typ := 0
+		fr := pbReader{buf: fb}
+		for {
+			field, _, v, b, ok := fr.next()
+			if !ok {
+				break
+			}
+			var err error
+			switch field {
+			case 2:
+				tags, err = unpack(b)
+			case 3:
+				typ = int(v)
+			case 4:
+				cmds, err = unpack(b)
+			}
+			if err != nil {
+				return l, err
+			}
+		}
+		if fr.err != nil {
+			return l, fr.err
+		}
+
+		props := map[string]interface{}{}
+		for i := 0; i+1 < len(tags); i += 2 {
+			if int(tags[i]) >= len(keys) || int(tags[i+1]) >= len(values) {
+				return l, errors.New("MVT: feature tag out of range")
+			}
+			props[keys[tags[i]]] = values[tags[i+1]]
+		}
+		g, err := decodeMVTGeometry(typ, cmds)
+		if err != nil {
+			return l, err
+		}
+		if g, err = mapGeometry(g, toLonLat); err != nil {
+			return l, err
+		}
+		if p, ok := g.(*Polygon); ok {
+			*p = p.ForceRHR()
+		}
+		if m, ok := g.(*MultiPolygon); ok {
+			*m = m.ForceRHR()
+		}
+		l.Features = append(l.Features, Feature{Type: "Feature", Geometry: g, Properties: props})
+	}
+	return l, nil
+}
+
+func decodeMVTValue(in []byte) (interface{}, error) {
+	r := pbReader{buf: in}
+	var out interface{}
+	for {
+		field, _, v, b, ok := r.next()
+		if !ok {
+			break
+		}
+		switch field {
+		case 1:
+			out = string(b)
+		case 2:
+			out = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		case 3:
+			out = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		case 4:
+			out = int64(v)
+		case 5:
+			out = v
+		case 6:
+			out = unzigzag(v)
+		case 7:
+			out = v != 0
+		}
+	}
+	return out, r.err
+}
+
+// decodeMVTGeometry decodes MVT commands to a geometry in tile
+// coordinates. Polygon rings with positive area start a new polygon and
+// those with negative area are holes in the last one.
+func decodeMVTGeometry(typ int, cmds []uint32) (Geometry, error) {
+	var cx, cy int64
+	paths := [][]Point{}
+	for i := 0; i < len(cmds); {
+		id, count := cmds[i]&7, int(cmds[i]>>3)
+		i++
+		switch id {
+		case mvtMoveTo, mvtLineTo:
+			if i+2*count > len(cmds) {
+				return nil, errors.New("MVT: geometry truncated")
+			}
+			for k := 0; k < count; k++ {
+				cx += unzigzag(uint64(cmds[i]))
+				cy += unzigzag(uint64(cmds[i+1]))
+				i += 2
+				p := Point{X: float64(cx), Y: float64(cy)}
+				if id == mvtMoveTo && (typ != mvtPoint || len(paths) == 0) {
+					paths = append(paths, []Point{p})
+				} else {
+					if len(paths) == 0 {
+						return nil, errors.New("MVT: LineTo before MoveTo")
+					}
+					paths[len(paths)-1] = append(paths[len(paths)-1], p)
+				}
+			}
+		case mvtClosePath:
+		default:
+			return nil, fmt.Errorf("MVT: command %d not recognised", id)
+		}
+	}
+
+	switch typ {
+	case mvtPoint:
+		if len(paths) == 0 {
+			return nil, errors.New("MVT: empty Point geometry")
+		}
+		if len(paths[0]) == 1 {
+			return &paths[0][0], nil
+		}
+		m := MultiPoint(paths[0])
+		return &m, nil
+	case mvtLineString:
+		if len(paths) == 1 {
+			l := LineString(paths[0])
+			return &l, nil
+		}
+		m := MultiLineString{}
+		for _, p := range paths {
+			m = append(m, LineString(p))
+		}
+		return &m, nil
+	case mvtPolygon:
+		m := MultiPolygon{}
+		for _, p := range paths {
+			r := LinearRing(p)
+			if signedArea(r) > 0 || len(m) == 0 {
+				m = append(m, Polygon{r})
+			} else {
+				m[len(m)-1] = append(m[len(m)-1], r)
+			}
+		}
+		if len(m) == 1 {
+			return &m[0], nil
+		}
+		return &m, nil
+	}
+	return nil, fmt.Errorf("MVT: geometry type %d not supported", typ)
+}
@@ -33,8 +33,6 @@ func (p *Point) AsArray() []float64 {
 	return []float64{p.X, p.Y, p.Z}
 }
 
-var endian map[uint8]binary.ByteOrder = map[uint8]binary.ByteOrder{0: binary.BigEndian, 1: binary.LittleEndian}
-
 /*
 // GetBSON implements bson.Getter.
 func (p *Point) GetBSON() (interface{}, error) {
@@ -57,9 +55,7 @@ func (p *Point) SetBSON(raw bson.Raw) error {
 */
 
 func (p *Point) WKB(end binary.ByteOrder) []byte {
-	buf := new(bytes.Buffer)
-	binary.Write(buf, end, *p)
-	return buf.Bytes()
+	return wkbBody(p, end)
 }
 
 func (p *Point) WKT() string {
@@ -67,37 +63,21 @@ func (p *Point) WKT() string {
 }
 
 func (p *Point) MarshalWKB(mode uint8) []byte {
-	buf := new(bytes.Buffer)
-	binary.Write(buf, endian[mode], &mode)
-
-	pointId := uint32(1)
-	binary.Write(buf, endian[mode], &pointId)
-
-	enc := p.WKB(endian[mode])
-	binary.Write(buf, endian[mode], &enc)
-
-	return buf.Bytes()
+	return marshalWKB(p, mode)
 }
 
 func (p *Point) UnmarshalWKB(in []byte) error {
-	buf := bytes.NewBuffer(in)
-
-	var end uint8
-	err := binary.Read(buf, binary.BigEndian, &end)
+	g, err := unmarshalWKB(in)
 	if err != nil {
-		return fmt.Errorf("Error reading geometry: %s", err)
+		return err
 	}
-
-	var wkbType uint32
-	err = binary.Read(buf, endian[end], &wkbType)
-	if err != nil || wkbType != 1 {
-		return fmt.Errorf("Not a Point: %s", err)
+	point, ok := g.(*Point)
+	if !ok {
+		return errors.New("Not a Point")
 	}
-
-	point, err := ExtractWKBPoint(buf, endian[end])
 	*p = *point
 
-	return err
+	return nil
 }
 
 func (p *Point) MarshalWKT() string {
@@ -144,21 +124,13 @@ func Slice2Point(fSlice []float64) (*Point, error) {
 	}
 }
 
+// ExtractWKBPoint reads the X and Y of a WKB Point following its header.
 func ExtractWKBPoint(buf *bytes.Buffer, end binary.ByteOrder) (*Point, error) {
-	var X, Y, Z float64
-	err := binary.Read(buf, end, &X)
-	if err != nil {
-		return nil, fmt.Errorf("Error reading: %s", err)
-	}
-	err = binary.Read(buf, end, &Y)
+	g, err := extractWKB(buf, end, 1)
 	if err != nil {
-		return nil, fmt.Errorf("Error reading: %s", err)
-	}
-	err = binary.Read(buf, end, &Z)
-	if err != nil {
-		return nil, fmt.Errorf("Error reading: %s", err)
+		return nil, err
 	}
-	return &Point{X, Y, Z}, nil
+	return g.(*Point), nil
 }
 
 func ExtractWKTPoint(in string) (*Point, error) {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -17,6 +18,9 @@ type PolygonView struct {
 }
 
 func (p Polygon) Equals(q Polygon) bool {
+	if len(p) != len(q) {
+		return false
+	}
 	for i, lr := range p {
 		if !lr.Equals(q[i]) {
 			return false
@@ -36,25 +40,7 @@ func (p *Polygon) AsArray() [][][]float64 {
 }
 
 func (p *Polygon) WKB(end binary.ByteOrder) []byte {
-	buf := new(bytes.Buffer)
-	var enc uint8
-	if end == binary.BigEndian {
-		enc = 0
-	} else {
-		enc = 1
-	}
-
-	binary.Write(buf, end, &enc)
-
-	pId := uint32(3)
-	binary.Write(buf, end, &pId)
-
-	numRings := uint32(len(*p))
-	binary.Write(buf, end, &numRings)
-	for _, lr := range *p {
-		binary.Write(buf, end, lr.WKB(end))
-	}
-	return buf.Bytes()
+	return wkbBody(p, end)
 }
 
 func (p *Polygon) WKT() string {
@@ -73,21 +59,21 @@ func (p *Polygon) WKT() string {
 }
 
 func (p *Polygon) MarshalWKB(mode uint8) []byte {
-	buf := new(bytes.Buffer)
-
-	enc := p.WKB(endian[mode])
-	binary.Write(buf, endian[mode], &enc)
-
-	return buf.Bytes()
+	return marshalWKB(p, mode)
 }
 
 func (p *Polygon) UnmarshalWKB(in []byte) error {
-	buf := bytes.NewBuffer(in)
-
-	var err error
-	*p, err = ExtractWKBPolygon(buf)
+	g, err := unmarshalWKB(in)
+	if err != nil {
+		return err
+	}
+	poly, ok := g.(*Polygon)
+	if !ok {
+		return errors.New("Not a Polygon")
+	}
+	*p = *poly
 
-	return err
+	return nil
 }
 
 func (p *Polygon) MarshalWKT() string {
@@ -125,6 +111,20 @@ func (p *Polygon) SetBSON(raw bson.Raw) error {
 }
 */
 
+// ForceRHR returns a copy of the polygon following the RFC 7946 right-hand
+// rule: exterior ring counter-clockwise and holes clockwise.
+func (p *Polygon) ForceRHR() Polygon {
+	out := make(Polygon, len(*p))
+	for i, lr := range *p {
+		if (i == 0 && lr.IsCW()) || (i > 0 && lr.IsCCW()) {
+			out[i] = lr.Reverse()
+		} else {
+			out[i] = append(LinearRing{}, lr...)
+		}
+	}
+	return out
+}
+
 func (p *Polygon) MarshalJSON() ([]byte, error) {
 	pView := PolygonView{"Polygon", p.AsArray()}
 	return json.Marshal(pView)
@@ -169,36 +169,15 @@ func ExtractWKTPolygon(in string) (Polygon, error) {
 	return p, nil
 }
 
+// ExtractWKBPolygon reads a WKB Polygon with its header.
 func ExtractWKBPolygon(buf *bytes.Buffer) (Polygon, error) {
-	//var bigEndian uint8
-	var end uint8
-	err := binary.Read(buf, binary.BigEndian, &end)
-	if err != nil {
-		return nil, fmt.Errorf("Problem reading geometry: %s", err)
-	}
-
-	var wkbType uint32
-	//err = binary.Read(buf, binary.BigEndian, &wkbType)
-	err = binary.Read(buf, endian[end], &wkbType)
-	if err != nil || wkbType != 3 {
-		return nil, fmt.Errorf("Not a Polygon: %s", err)
-	}
-
-	var numRings uint32
-	//err := binary.Read(buf, binary.BigEndian, &numRings)
-	err = binary.Read(buf, endian[end], &numRings)
+	g, err := extractWKB(buf, nil, 0)
 	if err != nil {
 		return nil, err
 	}
-	rs := make([]LinearRing, int(numRings))
-
-	for i := 0; i < int(numRings); i++ {
-		ring, err := ExtractWKBLinearRing(buf, endian[end])
-		if err != nil {
-			return nil, err
-		}
-		rs[i] = ring
+	p, ok := g.(*Polygon)
+	if !ok {
+		return nil, errors.New("Not a Polygon")
 	}
-
-	return Polygon(rs), nil
+	return *p, nil
 }
@@ -0,0 +1,104 @@
+package geometry
+
+import (
+	"errors"
+	"sort"
+)
+
+type Connectivity int
+
+const (
+	// FourConnected joins pixels sharing an edge.
+	FourConnected Connectivity = 4
+	// EightConnected also joins pixels touching diagonally.
+	EightConnected Connectivity = 8
+)
+
+// Polygonize converts a classified grid, indexed by row then column, into
+// one MultiPolygon feature per distinct value. The value is stored in the
+// "value" property and coordinates are placed using the GDAL geotransform.
+func Polygonize(data [][]int, gt [6]float64, conn Connectivity) (FeatureCollection, error) {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	if conn != FourConnected && conn != EightConnected {
+		return fc, errors.New("Polygonize: connectivity must be 4 or 8")
+	}
+	height := len(data)
+	if height == 0 {
+		return fc, nil
+	}
+	width := len(data[0])
+	for _, row := range data {
+		if len(row) != width {
+			return fc, errors.New("Polygonize: rows of different length")
+		}
+	}
+
+	at := func(c, r int) (int, bool) {
+		if c < 0 || r < 0 || c >= width || r >= height {
+			return 0, false
+		}
+		return data[r][c], true
+	}
+
+	// Directed pixel edges with the pixel on their left, for every side
+	// bordering a pixel of a different value.
+	type side struct {
+		dc, dr         int
+		x0, y0, x1, y1 int
+	}
+	sides := []side{
+		{0, -1, 0, 0, 1, 0},
+		{1, 0, 1, 0, 1, 1},
+		{0, 1, 1, 1, 0, 1},
+		{-1, 0, 0, 1, 0, 0},
+	}
+	graphs := map[int]*planarGraph{}
+	bounds := map[int][]edge{}
+	ids := map[int]map[int]int{}
+	node := func(v, x, y int) int {
+		g := graphs[v]
+		key := y*(width+1) + x
+		if n, ok := ids[v][key]; ok {
+			return n
+		}
+		g.nodes = append(g.nodes, Point{X: float64(x), Y: float64(y)})
+		g.orig = append(g.orig, false)
+		ids[v][key] = len(g.nodes) - 1
+		return len(g.nodes) - 1
+	}
+
+	for r := 0; r < height; r++ {
+		for c := 0; c < width; c++ {
+			v := data[r][c]
+			if _, ok := graphs[v]; !ok {
+				graphs[v] = &planarGraph{}
+				ids[v] = map[int]int{}
+			}
+			for _, s := range sides {
+				if n, ok := at(c+s.dc, r+s.dr); ok && n == v {
+					continue
+				}
+				bounds[v] = append(bounds[v], edge{node(v, c+s.x0, r+s.y0), node(v, c+s.x1, r+s.y1)})
+			}
+		}
+	}
+
+	values := []int{}
+	for v := range graphs {
+		values = append(values, v)
+	}
+	sort.Ints(values)
+
+	toWorld := AffineFromGeoTransform(gt)
+	for _, v := range values {
+		mp := graphs[v].polygons(bounds[v], conn == EightConnected)
+		g, err := toWorld.Apply(&mp)
+		if err != nil {
+			return fc, err
+		}
+		mp = g.(*MultiPolygon).ForceRHR()
+		fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: &mp, Properties: map[string]interface{}{"value": v}})
+	}
+
+	return fc, nil
+}
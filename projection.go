@@ -0,0 +1,280 @@
+package geometry
+
+import (
+	"errors"
+	"math"
+)
+
+// Projection converts between geographic coordinates in degrees and
+// projected coordinates on the plane.
+type Projection interface {
+	Forward(lon, lat float64) (x, y float64, err error)
+	Inverse(x, y float64) (lon, lat float64, err error)
+}
+
+type Ellipsoid struct {
+	A float64
+	F float64
+}
+
+var (
+	WGS84Ellipsoid      = Ellipsoid{A: 6378137, F: 1 / 298.257223563}
+	GRS80Ellipsoid      = Ellipsoid{A: 6378137, F: 1 / 298.257222101}
+	Clarke1866Ellipsoid = Ellipsoid{A: 6378206.4, F: 1 / 294.978698214}
+)
+
+func (e Ellipsoid) B() float64 {
+	return e.A * (1 - e.F)
+}
+
+func (e Ellipsoid) E2() float64 {
+	return e.F * (2 - e.F)
+}
+
+// parallelRadius returns the radius of the parallel at lat degrees as a
+// fraction of the semi-major axis.
+func (e Ellipsoid) parallelRadius(lat float64) float64 {
+	s := math.Sin(lat * deg)
+	return math.Cos(lat*deg) / math.Sqrt(1-e.E2()*s*s)
+}
+
+const deg = math.Pi / 180
+
+var errProjection = errors.New("Coordinate outside the domain of the projection")
+
+type TransverseMercator struct {
+	Ellipsoid Ellipsoid
+	Lat0      float64
+	Lon0      float64
+	K0        float64
+	X0        float64
+	Y0        float64
+
+	e     float64
+	a     float64
+	alpha [6]float64
+	beta  [6]float64
+	q0    float64
+}
+
+// NewTransverseMercator builds a transverse Mercator projection using the
+// Krüger series to sixth order in the third flattening.
+func NewTransverseMercator(ell Ellipsoid, lat0, lon0, k0, x0, y0 float64) *TransverseMercator {
+	n := ell.F / (2 - ell.F)
+	n2, n3 := n*n, n*n*n
+	n4, n5, n6 := n3*n, n3*n2, n3*n3
+	t := &TransverseMercator{Ellipsoid: ell, Lat0: lat0, Lon0: lon0, K0: k0, X0: x0, Y0: y0}
+	t.e = math.Sqrt(ell.E2())
+	t.a = ell.A / (1 + n) * (1 + n2/4 + n4/64 + n6/256)
+	t.alpha = [6]float64{
+		n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180 - 127*n5/288 + 7891*n6/37800,
+		13*n2/48 - 3*n3/5 + 557*n4/1440 + 281*n5/630 - 1983433*n6/1935360,
+		61*n3/240 - 103*n4/140 + 15061*n5/26880 + 167603*n6/181440,
+		49561*n4/161280 - 179*n5/168 + 6601661*n6/7257600,
+		34729*n5/80640 - 3418889*n6/1995840,
+		212378941 * n6 / 319334400,
+	}
+	t.beta = [6]float64{
+		n/2 - 2*n2/3 + 37*n3/96 - n4/360 - 81*n5/512 + 96199*n6/604800,
+		n2/48 + n3/15 - 437*n4/1440 + 46*n5/105 - 1118711*n6/3870720,
+		17*n3/480 - 37*n4/840 - 209*n5/4480 + 5569*n6/90720,
+		4397*n4/161280 - 11*n5/504 - 830251*n6/7257600,
+		4583*n5/161280 - 108847*n6/3991680,
+		20648693 * n6 / 638668800,
+	}
+	xi, _ := t.gauss(lat0*deg, 0)
+	t.q0 = t.a * xi
+	return t
+}
+
+func (t *TransverseMercator) gauss(phi, lam float64) (float64, float64) {
+	tau := math.Sinh(math.Atanh(math.Sin(phi)) - t.e*math.Atanh(t.e*math.Sin(phi)))
+	xi := math.Atan2(tau, math.Cos(lam))
+	eta := math.Atanh(math.Sin(lam) / math.Sqrt(1+tau*tau))
+	x, y := xi, eta
+	for j, a := range t.alpha {
+		k := 2 * float64(j+1)
+		x += a * math.Sin(k*xi) * math.Cosh(k*eta)
+		y += a * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+	return x, y
+}
+
+func (t *TransverseMercator) Forward(lon, lat float64) (float64, float64, error) {
+	lam := normaliseLon(lon-t.Lon0) * deg
+	if math.Abs(lam) >= math.Pi/2 || math.Abs(lat) > 90 {
+		return 0, 0, errProjection
+	}
+	xi, eta := t.gauss(lat*deg, lam)
+	return t.X0 + t.K0*t.a*eta, t.Y0 + t.K0*(t.a*xi-t.q0), nil
+}
+
+func (t *TransverseMercator) Inverse(x, y float64) (float64, float64, error) {
+	xi := ((y-t.Y0)/t.K0 + t.q0) / t.a
+	eta := (x - t.X0) / t.K0 / t.a
+	xp, ep := xi, eta
+	for j, b := range t.beta {
+		k := 2 * float64(j+1)
+		xp -= b * math.Sin(k*xi) * math.Cosh(k*eta)
+		ep -= b * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+	taup := math.Sin(xp) / math.Sqrt(math.Sinh(ep)*math.Sinh(ep)+math.Cos(xp)*math.Cos(xp))
+	lam := math.Atan2(math.Sinh(ep), math.Cos(xp))
+	phi := math.Atan(conformalToGeodetic(taup, t.e))
+	return normaliseLon(t.Lon0 + lam/deg), phi / deg, nil
+}
+
+// conformalToGeodetic solves for the tangent of the geodetic latitude given
+// the tangent of the conformal latitude.
+func conformalToGeodetic(taup, e float64) float64 {
+	e2m := 1 - e*e
+	tau := taup
+	for i := 0; i < 10; i++ {
+		sig := math.Sinh(e * math.Atanh(e*tau/math.Sqrt(1+tau*tau)))
+		tp := tau*math.Sqrt(1+sig*sig) - sig*math.Sqrt(1+tau*tau)
+		d := (taup - tp) / math.Sqrt(1+tp*tp) * (1 + e2m*tau*tau) / (e2m * math.Sqrt(1+tau*tau))
+		tau += d
+		if math.Abs(d) < 1e-14*math.Max(1, math.Abs(tau)) {
+			break
+		}
+	}
+	return tau
+}
+
+// Mercator is the ellipsoidal normal Mercator projection. Setting
+// Spherical projects geographic coordinates as if they were on a sphere of
+// radius A, as used by Web Mercator.
+type Mercator struct {
+	Ellipsoid Ellipsoid
+	Lon0      float64
+	K0        float64
+	X0        float64
+	Y0        float64
+	Spherical bool
+}
+
+func NewMercator(ell Ellipsoid, lon0, k0, x0, y0 float64) *Mercator {
+	return &Mercator{Ellipsoid: ell, Lon0: lon0, K0: k0, X0: x0, Y0: y0}
+}
+
+func NewWebMercator() *Mercator {
+	return &Mercator{Ellipsoid: WGS84Ellipsoid, K0: 1, Spherical: true}
+}
+
+func (m *Mercator) e() float64 {
+	if m.Spherical {
+		return 0
+	}
+	return math.Sqrt(m.Ellipsoid.E2())
+}
+
+func (m *Mercator) Forward(lon, lat float64) (float64, float64, error) {
+	if math.Abs(lat) >= 90 {
+		return 0, 0, errProjection
+	}
+	e := m.e()
+	phi := lat * deg
+	psi := math.Atanh(math.Sin(phi)) - e*math.Atanh(e*math.Sin(phi))
+	ak := m.Ellipsoid.A * m.K0
+	return m.X0 + ak*normaliseLon(lon-m.Lon0)*deg, m.Y0 + ak*psi, nil
+}
+
+func (m *Mercator) Inverse(x, y float64) (float64, float64, error) {
+	ak := m.Ellipsoid.A * m.K0
+	taup := math.Sinh((y - m.Y0) / ak)
+	lat := math.Atan(conformalToGeodetic(taup, m.e())) / deg
+	return normaliseLon(m.Lon0 + (x-m.X0)/ak/deg), lat, nil
+}
+
+type AlbersEqualArea struct {
+	Ellipsoid Ellipsoid
+	Lat1      float64
+	Lat2      float64
+	Lat0      float64
+	Lon0      float64
+	X0        float64
+	Y0        float64
+
+	e    float64
+	n    float64
+	c    float64
+	rho0 float64
+}
+
+func NewAlbersEqualArea(ell Ellipsoid, lat1, lat2, lat0, lon0, x0, y0 float64) *AlbersEqualArea {
+	p := &AlbersEqualArea{Ellipsoid: ell, Lat1: lat1, Lat2: lat2, Lat0: lat0, Lon0: lon0, X0: x0, Y0: y0}
+	p.e = math.Sqrt(ell.E2())
+	m1, m2 := p.m(lat1*deg), p.m(lat2*deg)
+	q1, q2 := p.q(lat1*deg), p.q(lat2*deg)
+	if math.Abs(lat1-lat2) > 1e-10 {
+		p.n = (m1*m1 - m2*m2) / (q2 - q1)
+	} else {
+		p.n = math.Sin(lat1 * deg)
+	}
+	p.c = m1*m1 + p.n*q1
+	p.rho0 = ell.A * math.Sqrt(p.c-p.n*p.q(lat0*deg)) / p.n
+	return p
+}
+
+func (p *AlbersEqualArea) m(phi float64) float64 {
+	s := math.Sin(phi)
+	return math.Cos(phi) / math.Sqrt(1-p.e*p.e*s*s)
+}
+
+func (p *AlbersEqualArea) q(phi float64) float64 {
+	s := math.Sin(phi)
+	if p.e == 0 {
+		return 2 * s
+	}
+	e2 := p.e * p.e
+	return (1 - e2) * (s/(1-e2*s*s) - math.Log((1-p.e*s)/(1+p.e*s))/(2*p.e))
+}
+
+func (p *AlbersEqualArea) Forward(lon, lat float64) (float64, float64, error) {
+	r := p.c - p.n*p.q(lat*deg)
+	if r < 0 {
+		return 0, 0, errProjection
+	}
+	rho := p.Ellipsoid.A * math.Sqrt(r) / p.n
+	theta := p.n * normaliseLon(lon-p.Lon0) * deg
+	return p.X0 + rho*math.Sin(theta), p.Y0 + p.rho0 - rho*math.Cos(theta), nil
+}
+
+func (p *AlbersEqualArea) Inverse(x, y float64) (float64, float64, error) {
+	x, y = x-p.X0, p.rho0-(y-p.Y0)
+	rho := math.Hypot(x, y)
+	theta := math.Atan2(x, y)
+	if p.n < 0 {
+		rho, theta = -rho, math.Atan2(-x, -y)
+	}
+	q := (p.c - rho*rho*p.n*p.n/(p.Ellipsoid.A*p.Ellipsoid.A)) / p.n
+	e2 := p.e * p.e
+	qp := p.q(math.Pi / 2)
+	if math.Abs(math.Abs(q)-math.Abs(qp)) < 1e-12 {
+		return normaliseLon(p.Lon0 + theta/p.n/deg), math.Copysign(90, q), nil
+	}
+	phi := math.Asin(math.Max(-1, math.Min(1, q/2)))
+	for i := 0; i < 25; i++ {
+		s := math.Sin(phi)
+		d := (1 - s*s) / (2 * math.Cos(phi)) * (q - 2*s)
+		if p.e > 0 {
+			d = (1 - e2*s*s) * (1 - e2*s*s) / (2 * math.Cos(phi)) *
+				(q/(1-e2) - s/(1-e2*s*s) + math.Log((1-p.e*s)/(1+p.e*s))/(2*p.e))
+		}
+		phi += d
+		if math.Abs(d) < 1e-14 {
+			break
+		}
+	}
+	return normaliseLon(p.Lon0 + theta/p.n/deg), phi / deg, nil
+}
+
+func normaliseLon(lon float64) float64 {
+	for lon > 180 {
+		lon -= 360
+	}
+	for lon < -180 {
+		lon += 360
+	}
+	return lon
+}
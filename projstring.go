@@ -0,0 +1,178 @@
+package geometry
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var projEllipsoids = map[string]Ellipsoid{
+	"WGS84":  WGS84Ellipsoid,
+	"GRS80":  GRS80Ellipsoid,
+	"clrk66": Clarke1866Ellipsoid,
+	"sphere": {A: 6370997},
+}
+
+var projDatums = map[string]Datum{
+	"WGS84": WGS84Datum,
+	"NAD83": {Name: "NAD83", Ellipsoid: GRS80Ellipsoid},
+}
+
+// ParseProjString builds a CRS from a PROJ definition such as
+// "+proj=utm +zone=55 +south +ellps=GRS80 +units=m".
+func ParseProjString(def string) (*CRS, error) {
+	params := map[string]string{}
+	for _, tok := range strings.Fields(def) {
+		tok = strings.TrimPrefix(tok, "+")
+		kv := strings.SplitN(tok, "=", 2)
+		if len(kv) == 1 {
+			params[kv[0]] = ""
+		} else {
+			params[kv[0]] = kv[1]
+		}
+	}
+
+	num := func(key string, def float64) (float64, error) {
+		v, ok := params[key]
+		if !ok {
+			return def, nil
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid value for +%s: %s", key, v)
+		}
+		return f, nil
+	}
+
+	datum, err := projDatum(params, num)
+	if err != nil {
+		return nil, err
+	}
+	if u, ok := params["units"]; ok && u != "m" {
+		return nil, fmt.Errorf("PROJ units %s not supported", u)
+	}
+	if pm, ok := params["pm"]; ok && pm != "greenwich" && pm != "0" {
+		return nil, fmt.Errorf("PROJ prime meridian %s not supported", pm)
+	}
+
+	var vals [8]float64
+	for i, k := range []string{"lat_0", "lon_0", "x_0", "y_0", "lat_1", "lat_2", "lat_ts", "zone"} {
+		if vals[i], err = num(k, 0); err != nil {
+			return nil, err
+		}
+	}
+	lat0, lon0, x0, y0, lat1, lat2, latTS, zone := vals[0], vals[1], vals[2], vals[3], vals[4], vals[5], vals[6], vals[7]
+	k0, err := num("k_0", 1)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := params["k_0"]; !ok {
+		if k0, err = num("k", 1); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := params["lat_2"]; !ok {
+		lat2 = lat1
+	}
+
+	crs := &CRS{Name: def, Datum: datum}
+	ell := datum.Ellipsoid
+	switch proj := params["proj"]; proj {
+	case "longlat", "latlong", "lonlat", "latlon":
+	case "utm":
+		if zone < 1 || zone > 60 || zone != math.Trunc(zone) {
+			return nil, fmt.Errorf("Invalid UTM zone: %g", zone)
+		}
+		y0 = 0
+		if _, ok := params["south"]; ok {
+			y0 = 10000000
+		}
+		crs.Projection = NewTransverseMercator(ell, 0, zone*6-183, 0.9996, 500000, y0)
+	case "tmerc":
+		crs.Projection = NewTransverseMercator(ell, lat0, lon0, k0, x0, y0)
+	case "merc":
+		if _, ok := params["lat_ts"]; ok {
+			k0 = ell.parallelRadius(latTS)
+		}
+		merc := NewMercator(ell, lon0, k0, x0, y0)
+		if params["nadgrids"] == "@null" {
+			// Web Mercator style definitions project WGS84 coordinates
+			// directly onto the sphere without a datum shift.
+			merc.Spherical = true
+			crs.Datum = WGS84Datum
+		}
+		crs.Projection = merc
+	case "aea":
+		crs.Projection = NewAlbersEqualArea(ell, lat1, lat2, lat0, lon0, x0, y0)
+	default:
+		return nil, fmt.Errorf("PROJ projection %s not supported", proj)
+	}
+
+	return crs, nil
+}
+
+func projDatum(params map[string]string, num func(string, float64) (float64, error)) (Datum, error) {
+	datum := WGS84Datum
+	if name, ok := params["datum"]; ok {
+		if datum, ok = projDatums[name]; !ok {
+			return datum, fmt.Errorf("PROJ datum %s not supported", name)
+		}
+	}
+	if name, ok := params["ellps"]; ok {
+		ell, ok := projEllipsoids[name]
+		if !ok {
+			return datum, fmt.Errorf("PROJ ellipsoid %s not supported", name)
+		}
+		datum = Datum{Name: name, Ellipsoid: ell}
+	}
+
+	a, err := num("a", 0)
+	if err != nil {
+		return datum, err
+	}
+	if r, err := num("R", 0); err != nil {
+		return datum, err
+	} else if r > 0 {
+		datum.Ellipsoid = Ellipsoid{A: r}
+	}
+	if a > 0 {
+		ell := Ellipsoid{A: a}
+		switch {
+		case params["rf"] != "":
+			rf, err := num("rf", 0)
+			if err != nil {
+				return datum, err
+			}
+			ell.F = 1 / rf
+		case params["f"] != "":
+			if ell.F, err = num("f", 0); err != nil {
+				return datum, err
+			}
+		case params["b"] != "":
+			b, err := num("b", 0)
+			if err != nil {
+				return datum, err
+			}
+			ell.F = (a - b) / a
+		}
+		datum.Ellipsoid = ell
+	}
+
+	if v, ok := params["towgs84"]; ok {
+		var h [7]float64
+		for i, s := range strings.Split(v, ",") {
+			if i >= 7 {
+				return datum, fmt.Errorf("Invalid value for +towgs84: %s", v)
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return datum, fmt.Errorf("Invalid value for +towgs84: %s", v)
+			}
+			h[i] = f
+		}
+		datum.ToWGS84 = Helmert{h[0], h[1], h[2], h[3], h[4], h[5], h[6]}
+	}
+
+	return datum, nil
+}
@@ -0,0 +1,264 @@
+package geometry
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Grid describes a regular raster by its GDAL geotransform and size in
+// pixels. Masks and coverages over the grid are stored row by row.
+type Grid struct {
+	GeoTransform [6]float64
+	Width        int
+	Height       int
+}
+
+// NewGrid builds a north-up grid whose top left corner is at
+// (originX, originY) with square or rectangular pixels of resX by resY.
+func NewGrid(originX, originY, resX, resY float64, width, height int) Grid {
+	return Grid{GeoTransform: [6]float64{originX, resX, 0, originY, 0, -resY}, Width: width, Height: height}
+}
+
+type RasterRule int
+
+const (
+	// CentrePoint selects pixels whose centre falls inside the polygon.
+	CentrePoint RasterRule = iota
+	// AllTouched selects every pixel the polygon touches.
+	AllTouched
+)
+
+// toPixels converts the geometry to pixel space, where pixel (col, row)
+// covers [col, col+1) x [row, row+1).
+func (gr Grid) toPixels(g Geometry) (Geometry, error) {
+	inv, err := AffineFromGeoTransform(gr.GeoTransform).Inverse()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid grid geotransform: %s", err)
+	}
+	return inv.Apply(g)
+}
+
+// Rasterize burns the geometry into a mask over the grid. Polygons are
+// filled according to rule, while lines and points burn every pixel they
+// pass through.
+func (gr Grid) Rasterize(g Geometry, rule RasterRule) ([]bool, error) {
+	pix, err := gr.toPixels(g)
+	if err != nil {
+		return nil, err
+	}
+	mask := make([]bool, gr.Width*gr.Height)
+
+	switch t := pix.(type) {
+	case *Point:
+		gr.burnSegment(mask, *t, *t)
+	case *MultiPoint:
+		for _, p := range *t {
+			gr.burnSegment(mask, p, p)
+		}
+	case *LineString:
+		gr.burnLine(mask, *t, false)
+	case *MultiLineString:
+		for _, l := range *t {
+			gr.burnLine(mask, l, false)
+		}
+	case *Polygon:
+		gr.fillPolygon(mask, *t, rule)
+	case *MultiPolygon:
+		for _, p := range *t {
+			gr.fillPolygon(mask, p, rule)
+		}
+	default:
+		return nil, fmt.Errorf("Rasterize: Geometry %T not supported", g)
+	}
+
+	return mask, nil
+}
+
+func (gr Grid) fillPolygon(mask []bool, p Polygon, rule RasterRule) {
+	for r := 0; r < gr.Height; r++ {
+		y := float64(r) + 0.5
+		xs := []float64{}
+		for _, ring := range p {
+			for i := range ring {
+				a, b := ring[i], ring[(i+1)%len(ring)]
+				if (a.Y > y) != (b.Y > y) {
+					xs = append(xs, a.X+(y-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+				}
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			c0 := int(math.Max(0, math.Ceil(xs[i]-0.5)))
+			c1 := int(math.Min(float64(gr.Width), math.Ceil(xs[i+1]-0.5)))
+			for c := c0; c < c1; c++ {
+				mask[r*gr.Width+c] = true
+			}
+		}
+	}
+
+	if rule == AllTouched {
+		for _, ring := range p {
+			gr.burnLine(mask, ring, true)
+		}
+	}
+}
+
+func (gr Grid) burnLine(mask []bool, pts []Point, closed bool) {
+	for i := 0; i+1 < len(pts); i++ {
+		gr.burnSegment(mask, pts[i], pts[i+1])
+	}
+	if closed && len(pts) > 1 {
+		gr.burnSegment(mask, pts[len(pts)-1], pts[0])
+	}
+}
+
+// burnSegment marks every pixel crossed by the segment, after clipping it
+// to the grid extent.
+func (gr Grid) burnSegment(mask []bool, a, b Point) {
+	w, h := float64(gr.Width), float64(gr.Height)
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t0, t1 := 0.0, 1.0
+	for _, e := range [][2]float64{{-dx, a.X}, {dx, w - a.X}, {-dy, a.Y}, {dy, h - a.Y}} {
+		p, q := e[0], e[1]
+		if p == 0 {
+			if q < 0 {
+				return
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 {
+			t0 = math.Max(t0, r)
+		} else {
+			t1 = math.Min(t1, r)
+		}
+	}
+	if t0 > t1 {
+		return
+	}
+
+	x0, y0 := a.X+t0*dx, a.Y+t0*dy
+	x1, y1 := a.X+t1*dx, a.Y+t1*dy
+	clamp := func(v float64, n int) int {
+		return int(math.Max(0, math.Min(float64(n-1), math.Floor(v))))
+	}
+	c, r := clamp(x0, gr.Width), clamp(y0, gr.Height)
+	ce, re := clamp(x1, gr.Width), clamp(y1, gr.Height)
+
+	stepC, stepR := 1, 1
+	if dx < 0 {
+		stepC = -1
+	}
+	if dy < 0 {
+		stepR = -1
+	}
+	next := func(v, d float64, cell, step int) float64 {
+		if d == 0 {
+			return math.Inf(1)
+		}
+		edge := float64(cell)
+		if step > 0 {
+			edge++
+		}
+		return (edge - v) / d
+	}
+	tx, ty := next(x0, x1-x0, c, stepC), next(y0, y1-y0, r, stepR)
+	dtx, dty := math.Abs(1/(x1-x0)), math.Abs(1/(y1-y0))
+
+	mask[r*gr.Width+c] = true
+	for n := 0; (c != ce || r != re) && n < gr.Width+gr.Height; n++ {
+		if tx < ty {
+			c += stepC
+			tx += dtx
+		} else {
+			r += stepR
+			ty += dty
+		}
+		if c < 0 || c >= gr.Width || r < 0 || r >= gr.Height {
+			break
+		}
+		mask[r*gr.Width+c] = true
+	}
+}
+
+// Coverage returns the fraction of each pixel covered by a Polygon or
+// MultiPolygon.
+func (gr Grid) Coverage(g Geometry) ([]float64, error) {
+	pix, err := gr.toPixels(g)
+	if err != nil {
+		return nil, err
+	}
+	var polys []Polygon
+	switch t := pix.(type) {
+	case *Polygon:
+		polys = []Polygon{*t}
+	case *MultiPolygon:
+		polys = *t
+	default:
+		return nil, fmt.Errorf("Coverage: Geometry %T not supported", g)
+	}
+
+	cov := make([]float64, gr.Width*gr.Height)
+	for _, p := range polys {
+		for _, ring := range p.ForceRHR() {
+			minX, minY := math.Inf(1), math.Inf(1)
+			maxX, maxY := math.Inf(-1), math.Inf(-1)
+			for _, pt := range ring {
+				minX, maxX = math.Min(minX, pt.X), math.Max(maxX, pt.X)
+				minY, maxY = math.Min(minY, pt.Y), math.Max(maxY, pt.Y)
+			}
+			r0 := int(math.Max(0, math.Floor(minY)))
+			r1 := int(math.Min(float64(gr.Height-1), math.Floor(maxY)))
+			for r := r0; r <= r1; r++ {
+				strip := clipAxis(clipAxis(ring, 1, float64(r), 1), 1, float64(r+1), -1)
+				if len(strip) < 3 {
+					continue
+				}
+				sx0, sx1 := math.Inf(1), math.Inf(-1)
+				for _, pt := range strip {
+					sx0, sx1 = math.Min(sx0, pt.X), math.Max(sx1, pt.X)
+				}
+				c0 := int(math.Max(0, math.Floor(sx0)))
+				c1 := int(math.Min(float64(gr.Width-1), math.Floor(sx1)))
+				for c := c0; c <= c1; c++ {
+					cell := clipAxis(clipAxis(strip, 0, float64(c), 1), 0, float64(c+1), -1)
+					if len(cell) >= 3 {
+						cov[r*gr.Width+c] += signedArea(cell)
+					}
+				}
+			}
+		}
+	}
+	for i, v := range cov {
+		cov[i] = math.Max(0, math.Min(1, v))
+	}
+
+	return cov, nil
+}
+
+// clipAxis clips a ring to the half plane where the coordinate on axis (0
+// for X, 1 for Y) is on the side of v given by sign.
+func clipAxis(ring LinearRing, axis int, v, sign float64) LinearRing {
+	coord := func(p Point) float64 {
+		if axis == 0 {
+			return p.X
+		}
+		return p.Y
+	}
+	in := func(p Point) bool {
+		return (coord(p)-v)*sign >= 0
+	}
+	out := LinearRing{}
+	for i := range ring {
+		a, b := ring[i], ring[(i+1)%len(ring)]
+		if in(a) {
+			out = append(out, a)
+		}
+		if in(a) != in(b) {
+			t := (v - coord(a)) / (coord(b) - coord(a))
+			out = append(out, Point{X: a.X + t*(b.X-a.X), Y: a.Y + t*(b.Y-a.Y)})
+		}
+	}
+	return out
+}
@@ -0,0 +1,393 @@
+package geometry
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// RTree is an in-memory R-tree indexing items of any type by their
+// bounding boxes. Node splitting follows the R*-tree axis and index
+// choice, and BulkLoad packs the tree using Sort-Tile-Recursive.
+type RTree[T any] struct {
+	root       *rtreeNode[T]
+	size       int
+	maxEntries int
+	minEntries int
+	// persistent trees copy nodes before modifying them, so earlier
+	// versions sharing those nodes are never changed.
+	persistent bool
+}
+
+type rtreeEntry[T any] struct {
+	bounds Bounds
+	child  *rtreeNode[T]
+	item   T
+}
+
+type rtreeNode[T any] struct {
+	leaf    bool
+	height  int
+	bounds  Bounds
+	entries []rtreeEntry[T]
+}
+
+// NewRTree returns an empty tree whose nodes hold at most maxEntries
+// entries, 9 if maxEntries is less than 4.
+func NewRTree[T any](maxEntries int) *RTree[T] {
+	if maxEntries < 4 {
+		maxEntries = 9
+	}
+	t := &RTree[T]{maxEntries: maxEntries, minEntries: int(math.Max(2, math.Ceil(float64(maxEntries)*0.4)))}
+	t.Clear()
+	return t
+}
+
+func (t *RTree[T]) Clear() {
+	t.root = &rtreeNode[T]{leaf: true, height: 1, bounds: EmptyBounds()}
+	t.size = 0
+}
+
+func (t *RTree[T]) Len() int {
+	return t.size
+}
+
+func (t *RTree[T]) Bounds() Bounds {
+	return t.root.bounds
+}
+
+func (n *rtreeNode[T]) clone() *rtreeNode[T] {
+	c := *n
+	c.entries = append(make([]rtreeEntry[T], 0, len(n.entries)+1), n.entries...)
+	return &c
+}
+
+func (n *rtreeNode[T]) recalc() {
+	n.bounds = EmptyBounds()
+	for _, e := range n.entries {
+		n.bounds = n.bounds.Extend(e.bounds)
+	}
+}
+
+// Search calls fn for every item whose bounds intersect b, stopping early
+// if fn returns false.
+func (t *RTree[T]) Search(b Bounds, fn func(Bounds, T) bool) {
+	if !t.root.bounds.Intersects(b) {
+		return
+	}
+	t.search(t.root, b, fn)
+}
+
+func (t *RTree[T]) search(n *rtreeNode[T], b Bounds, fn func(Bounds, T) bool) bool {
+	for _, e := range n.entries {
+		if !b.Intersects(e.bounds) {
+			continue
+		}
+		if n.leaf {
+			if !fn(e.bounds, e.item) {
+				return false
+			}
+		} else if !t.search(e.child, b, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchItems returns every item whose bounds intersect b.
+func (t *RTree[T]) SearchItems(b Bounds) []T {
+	out := []T{}
+	t.Search(b, func(_ Bounds, item T) bool {
+		out = append(out, item)
+		return true
+	})
+	return out
+}
+
+// Each calls fn for every item in the tree, stopping early if fn returns
+// false.
+func (t *RTree[T]) Each(fn func(Bounds, T) bool) {
+	t.search(t.root, Bounds{math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(1)}, fn)
+}
+
+func (t *RTree[T]) Insert(b Bounds, item T) {
+	t.insert(rtreeEntry[T]{bounds: b, item: item}, 1)
+	t.size++
+}
+
+// insert places the entry in a node at the given height, 1 being the
+// leaves, splitting overflowing nodes on the way back up.
+func (t *RTree[T]) insert(e rtreeEntry[T], height int) {
+	path := []*rtreeNode[T]{}
+	if t.persistent {
+		t.root = t.root.clone()
+	}
+	n := t.root
+	for {
+		path = append(path, n)
+		n.bounds = n.bounds.Extend(e.bounds)
+		if n.height == height {
+			break
+		}
+		best, bestEnl, bestArea := 0, math.Inf(1), math.Inf(1)
+		for i, c := range n.entries {
+			area := c.bounds.Area()
+			enl := c.bounds.Extend(e.bounds).Area() - area
+			if enl < bestEnl || (enl == bestEnl && area < bestArea) {
+				best, bestEnl, bestArea = i, enl, area
+			}
+		}
+		n.entries[best].bounds = n.entries[best].bounds.Extend(e.bounds)
+		if t.persistent {
+			n.entries[best].child = n.entries[best].child.clone()
+		}
+		n = n.entries[best].child
+	}
+	n.entries = append(n.entries, e)
+
+	for i := len(path) - 1; i >= 0 && len(path[i].entries) > t.maxEntries; i-- {
+		sibling := t.split(path[i])
+		if i == 0 {
+			old := t.root
+			t.root = &rtreeNode[T]{height: old.height + 1, entries: []rtreeEntry[T]{
+				{bounds: old.bounds, child: old}, {bounds: sibling.bounds, child: sibling},
+			}}
+			t.root.recalc()
+			break
+		}
+		parent := path[i-1]
+		for j := range parent.entries {
+			if parent.entries[j].child == path[i] {
+				parent.entries[j].bounds = path[i].bounds
+			}
+		}
+		parent.entries = append(parent.entries, rtreeEntry[T]{bounds: sibling.bounds, child: sibling})
+	}
+}
+
+// split moves part of an overflowing node's entries into a new sibling,
+// choosing the axis with the smallest total margin and then the split
+// with the least overlap.
+func (t *RTree[T]) split(n *rtreeNode[T]) *rtreeNode[T] {
+	m, total := t.minEntries, len(n.entries)
+	groupBounds := func(es []rtreeEntry[T]) Bounds {
+		b := EmptyBounds()
+		for _, e := range es {
+			b = b.Extend(e.bounds)
+		}
+		return b
+	}
+	margin := func() float64 {
+		s := 0.0
+		for k := m; k <= total-m; k++ {
+			s += groupBounds(n.entries[:k]).Margin() + groupBounds(n.entries[k:]).Margin()
+		}
+		return s
+	}
+	byX := func(i, j int) bool { return n.entries[i].bounds.MinX < n.entries[j].bounds.MinX }
+	byY := func(i, j int) bool { return n.entries[i].bounds.MinY < n.entries[j].bounds.MinY }
+
+	sort.SliceStable(n.entries, byX)
+	mx := margin()
+	sort.SliceStable(n.entries, byY)
+	if my := margin(); mx < my {
+		sort.SliceStable(n.entries, byX)
+	}
+
+	best, bestOverlap, bestArea := m, math.Inf(1), math.Inf(1)
+	for k := m; k <= total-m; k++ {
+		b1, b2 := groupBounds(n.entries[:k]), groupBounds(n.entries[k:])
+		overlap := 0.0
+		if b1.Intersects(b2) {
+			overlap = Bounds{math.Max(b1.MinX, b2.MinX), math.Max(b1.MinY, b2.MinY), math.Min(b1.MaxX, b2.MaxX), math.Min(b1.MaxY, b2.MaxY)}.Area()
+		}
+		area := b1.Area() + b2.Area()
+		if overlap < bestOverlap || (overlap == bestOverlap && area < bestArea) {
+			best, bestOverlap, bestArea = k, overlap, area
+		}
+	}
+
+	sibling := &rtreeNode[T]{leaf: n.leaf, height: n.height, entries: append([]rtreeEntry[T]{}, n.entries[best:]...)}
+	n.entries = n.entries[:best:best]
+	n.recalc()
+	sibling.recalc()
+	return sibling
+}
+
+// Delete removes the first item within bounds b for which match returns
+// true, reporting whether an item was found.
+func (t *RTree[T]) Delete(b Bounds, match func(T) bool) bool {
+	// index holds the position of the entry followed at each level of
+	// the path, ending with the matching leaf entry.
+	index := []int{}
+	var find func(n *rtreeNode[T]) bool
+	find = func(n *rtreeNode[T]) bool {
+		for i, e := range n.entries {
+			if !e.bounds.Intersects(b) {
+				continue
+			}
+			index = append(index, i)
+			if n.leaf {
+				if match(e.item) {
+					return true
+				}
+			} else if find(e.child) {
+				return true
+			}
+			index = index[:len(index)-1]
+		}
+		return false
+	}
+	if !find(t.root) {
+		return false
+	}
+
+	if t.persistent {
+		t.root = t.root.clone()
+	}
+	path := []*rtreeNode[T]{t.root}
+	for _, i := range index[:len(index)-1] {
+		n := path[len(path)-1]
+		if t.persistent {
+			n.entries[i].child = n.entries[i].child.clone()
+		}
+		path = append(path, n.entries[i].child)
+	}
+	leaf, i := path[len(path)-1], index[len(index)-1]
+	leaf.entries = append(leaf.entries[:i], leaf.entries[i+1:]...)
+	t.size--
+
+	// Condense the path, dropping nodes that became empty and tightening
+	// the bounds of their ancestors.
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i]
+		n.recalc()
+		if i > 0 && len(n.entries) == 0 {
+			parent := path[i-1]
+			for j := range parent.entries {
+				if parent.entries[j].child == n {
+					parent.entries = append(parent.entries[:j], parent.entries[j+1:]...)
+					break
+				}
+			}
+		} else if i > 0 {
+			parent := path[i-1]
+			for j := range parent.entries {
+				if parent.entries[j].child == n {
+					parent.entries[j].bounds = n.bounds
+				}
+			}
+		}
+	}
+	for !t.root.leaf && len(t.root.entries) == 1 {
+		t.root = t.root.entries[0].child
+	}
+	if t.size == 0 {
+		t.Clear()
+	}
+	return true
+}
+
+// BulkLoad replaces the contents of the tree with the given items, packing
+// them with the Sort-Tile-Recursive algorithm.
+func (t *RTree[T]) BulkLoad(bounds []Bounds, items []T) {
+	t.Clear()
+	if len(items) == 0 {
+		return
+	}
+	entries := make([]rtreeEntry[T], len(items))
+	for i := range items {
+		entries[i] = rtreeEntry[T]{bounds: bounds[i], item: items[i]}
+	}
+	leaf, height := true, 1
+	for {
+		nodes := t.pack(entries, leaf, height)
+		if len(nodes) == 1 {
+			t.root = nodes[0]
+			break
+		}
+		entries = make([]rtreeEntry[T], len(nodes))
+		for i, n := range nodes {
+			entries[i] = rtreeEntry[T]{bounds: n.bounds, child: n}
+		}
+		leaf = false
+		height++
+	}
+	t.size = len(items)
+}
+
+func (t *RTree[T]) pack(entries []rtreeEntry[T], leaf bool, height int) []*rtreeNode[T] {
+	m := t.maxEntries
+	nodeCount := int(math.Ceil(float64(len(entries)) / float64(m)))
+	slabs := int(math.Ceil(math.Sqrt(float64(nodeCount))))
+	slabSize := slabs * m
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].bounds.MinX+entries[i].bounds.MaxX < entries[j].bounds.MinX+entries[j].bounds.MaxX
+	})
+	nodes := []*rtreeNode[T]{}
+	for s := 0; s < len(entries); s += slabSize {
+		slab := entries[s:int(math.Min(float64(s+slabSize), float64(len(entries))))]
+		sort.Slice(slab, func(i, j int) bool {
+			return slab[i].bounds.MinY+slab[i].bounds.MaxY < slab[j].bounds.MinY+slab[j].bounds.MaxY
+		})
+		for k := 0; k < len(slab); k += m {
+			end := int(math.Min(float64(k+m), float64(len(slab))))
+			n := &rtreeNode[T]{leaf: leaf, height: height, entries: append([]rtreeEntry[T]{}, slab[k:end]...)}
+			n.recalc()
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+type rtreeCandidate[T any] struct {
+	dist  float64
+	node  *rtreeNode[T]
+	entry rtreeEntry[T]
+}
+
+type rtreeQueue[T any] []rtreeCandidate[T]
+
+func (q rtreeQueue[T]) Len() int            { return len(q) }
+func (q rtreeQueue[T]) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q rtreeQueue[T]) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *rtreeQueue[T]) Push(x interface{}) { *q = append(*q, x.(rtreeCandidate[T])) }
+func (q *rtreeQueue[T]) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// Nearest returns up to k items ordered by the distance from p to their
+// bounds.
+func (t *RTree[T]) Nearest(p Point, k int) []T {
+	return t.NearestFunc(p, k, nil)
+}
+
+// NearestFunc is like Nearest but ranks items by dist, which must never be
+// less than the distance from p to the item's bounds.
+func (t *RTree[T]) NearestFunc(p Point, k int, dist func(T) float64) []T {
+	out := []T{}
+	q := &rtreeQueue[T]{{dist: t.root.bounds.Distance(p), node: t.root}}
+	for q.Len() > 0 && len(out) < k {
+		c := heap.Pop(q).(rtreeCandidate[T])
+		if c.node == nil {
+			out = append(out, c.entry.item)
+			continue
+		}
+		for _, e := range c.node.entries {
+			d := e.bounds.Distance(p)
+			if !c.node.leaf {
+				heap.Push(q, rtreeCandidate[T]{dist: d, node: e.child})
+				continue
+			}
+			if dist != nil {
+				d = dist(e.item)
+			}
+			heap.Push(q, rtreeCandidate[T]{dist: d, entry: e})
+		}
+	}
+	return out
+}
@@ -0,0 +1,532 @@
+package geometry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+type ShapeType int32
+
+const (
+	NullShape        ShapeType = 0
+	PointShape       ShapeType = 1
+	PolyLineShape    ShapeType = 3
+	PolygonShape     ShapeType = 5
+	MultiPointShape  ShapeType = 8
+	PointZShape      ShapeType = 11
+	PolyLineZShape   ShapeType = 13
+	PolygonZShape    ShapeType = 15
+	MultiPointZShape ShapeType = 18
+	PointMShape      ShapeType = 21
+	PolyLineMShape   ShapeType = 23
+	PolygonMShape    ShapeType = 25
+	MultiPointMShape ShapeType = 28
+)
+
+// base returns the shape type without Z or M values. Types other than the
+// Z and M variants, such as MultiPatch, are returned unchanged.
+func (t ShapeType) base() ShapeType {
+	switch t {
+	case PointZShape, PolyLineZShape, PolygonZShape, MultiPointZShape,
+		PointMShape, PolyLineMShape, PolygonMShape, MultiPointMShape:
+		return t % 10
+	}
+	return t
+}
+
+func (t ShapeType) hasZ() bool {
+	return t > 10 && t < 20
+}
+
+// Shapefile holds the contents of an ESRI shapefile: its geometries with
+// their DBF attributes as features, and the WKT of its .prj file.
+type Shapefile struct {
+	Type       ShapeType
+	Bounds     Bounds
+	Fields     []DBFField
+	Features   []Feature
+	Projection string
+}
+
+func (s *Shapefile) FeatureCollection() FeatureCollection {
+	return FeatureCollection{Type: "FeatureCollection", Features: s.Features}
+}
+
+// CRS parses the projection of the shapefile.
+func (s *Shapefile) CRS() (*CRS, error) {
+	if s.Projection == "" {
+		return nil, errors.New("Shapefile has no projection")
+	}
+	return ParseWKTCRS(s.Projection)
+}
+
+// ReadShapefile reads the .shp file at path, with the .dbf, .cpg and .prj
+// files next to it when present. The .shp extension may be left off.
+func ReadShapefile(path string) (*Shapefile, error) {
+	base := strings.TrimSuffix(path, ".shp")
+	shp, err := os.Open(base + ".shp")
+	if err != nil {
+		return nil, err
+	}
+	defer shp.Close()
+
+	var dbf io.Reader
+	if f, err := os.Open(base + ".dbf"); err == nil {
+		defer f.Close()
+		dbf = f
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	cpg, err := os.ReadFile(base + ".cpg")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	s, err := DecodeShapefile(shp, dbf, string(cpg))
+	if err != nil {
+		return nil, err
+	}
+	if prj, err := os.ReadFile(base + ".prj"); err == nil {
+		s.Projection = strings.TrimSpace(string(prj))
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DecodeShapefile reads a shapefile from its .shp and, unless nil, .dbf
+// contents. cpg names the code page of the DBF text as found in a .cpg
+// file, UTF-8 when empty. Polygon rings are sorted into shells and holes
+// by their orientation and returned counter-clockwise and clockwise
+// respectively. Shapes whose DBF record is marked deleted are dropped.
+func DecodeShapefile(shp, dbf io.Reader, cpg string) (*Shapefile, error) {
+	data, err := io.ReadAll(shp)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 100 || binary.BigEndian.Uint32(data) != 9994 {
+		return nil, errors.New("Not a shapefile")
+	}
+	if n := int(binary.BigEndian.Uint32(data[24:])) * 2; n >= 100 && n < len(data) {
+		data = data[:n]
+	}
+
+	s := &Shapefile{Type: ShapeType(binary.LittleEndian.Uint32(data[32:])), Fields: []DBFField{}, Features: []Feature{}}
+	r := &shpReader{b: data[36:68]}
+	s.Bounds = Bounds{r.float64(), r.float64(), r.float64(), r.float64()}
+
+	for pos := 100; pos+8 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[pos+4:])) * 2
+		if pos+8+n > len(data) {
+			return nil, fmt.Errorf("Shapefile record %d truncated", len(s.Features)+1)
+		}
+		g, err := decodeShape(data[pos+8 : pos+8+n])
+		if err != nil {
+			return nil, fmt.Errorf("Shapefile record %d: %s", len(s.Features)+1, err)
+		}
+		s.Features = append(s.Features, Feature{Type: "Feature", Geometry: g, Properties: map[string]interface{}{}})
+		pos += 8 + n
+	}
+
+	if dbf == nil {
+		return s, nil
+	}
+	text, err := dbfCharset(cpg)
+	if err != nil {
+		return nil, err
+	}
+	table, err := io.ReadAll(dbf)
+	if err != nil {
+		return nil, err
+	}
+	fields, records, err := decodeDBF(table, text)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) != len(s.Features) {
+		return nil, fmt.Errorf("Shapefile has %d shapes but %d DBF records", len(s.Features), len(records))
+	}
+	s.Fields = fields
+	live := s.Features[:0]
+	for i, f := range s.Features {
+		if records[i] != nil {
+			f.Properties = records[i]
+			live = append(live, f)
+		}
+	}
+	s.Features = live
+	return s, nil
+}
+
+// shpReader reads little endian values, remembering the first read past
+// the end of its buffer.
+type shpReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *shpReader) next(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.b) {
+		r.err = errors.New("record truncated")
+		return make([]byte, max(n, 0))
+	}
+	r.pos += n
+	return r.b[r.pos-n : r.pos]
+}
+
+func (r *shpReader) int32() int {
+	return int(int32(binary.LittleEndian.Uint32(r.next(4))))
+}
+
+func (r *shpReader) float64() float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(r.next(8)))
+}
+
+// points reads n XY pairs, checking first that they fit in the record.
+func (r *shpReader) points(n int) []Point {
+	if n < 0 || r.pos+16*n > len(r.b) {
+		r.err = errors.New("record truncated")
+		return nil
+	}
+	pts := make([]Point, n)
+	for i := range pts {
+		pts[i] = Point{X: r.float64(), Y: r.float64()}
+	}
+	return pts
+}
+
+// zs reads the Z range and values that follow the points of a Z shape.
+func (r *shpReader) zs(pts []Point) {
+	r.next(16)
+	for i := range pts {
+		pts[i].Z = r.float64()
+	}
+}
+
+func decodeShape(content []byte) (Geometry, error) {
+	r := &shpReader{b: content}
+	t := ShapeType(r.int32())
+	switch t.base() {
+	case NullShape:
+		return nil, r.err
+	case PointShape:
+		p := Point{X: r.float64(), Y: r.float64()}
+		if t.hasZ() {
+			p.Z = r.float64()
+		}
+		return &p, r.err
+	case MultiPointShape:
+		r.next(32)
+		pts := r.points(r.int32())
+		if t.hasZ() {
+			r.zs(pts)
+		}
+		mp := MultiPoint(pts)
+		return &mp, r.err
+	case PolyLineShape, PolygonShape:
+		r.next(32)
+		numParts, numPoints := r.int32(), r.int32()
+		if numParts < 0 || r.pos+4*numParts > len(content) {
+			return nil, errors.New("record truncated")
+		}
+		starts := make([]int, numParts)
+		for i := range starts {
+			starts[i] = r.int32()
+		}
+		pts := r.points(numPoints)
+		if t.hasZ() {
+			r.zs(pts)
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		parts := make([][]Point, numParts)
+		for i, s := range starts {
+			e := numPoints
+			if i+1 < numParts {
+				e = starts[i+1]
+			}
+			if s < 0 || s > e || e > numPoints {
+				return nil, errors.New("invalid part index")
+			}
+			parts[i] = pts[s:e]
+		}
+		if t.base() == PolygonShape {
+			return shapePolygons(parts), nil
+		}
+		if len(parts) == 1 {
+			ls := LineString(parts[0])
+			return &ls, nil
+		}
+		mls := make(MultiLineString, len(parts))
+		for i, p := range parts {
+			mls[i] = LineString(p)
+		}
+		return &mls, nil
+	}
+	return nil, fmt.Errorf("unsupported shape type %d", t)
+}
+
+// shapePolygons sorts shapefile rings into polygons. Clockwise rings are
+// shells and counter-clockwise rings holes of the smallest shell holding
+// them, while holes outside every shell are taken as shells.
+func shapePolygons(parts [][]Point) Geometry {
+	shells := []Polygon{}
+	holes := []LinearRing{}
+	for _, pts := range parts {
+		r := LinearRing(pts)
+		if len(r) > 1 && r[0] == r[len(r)-1] {
+			r = r[:len(r)-1]
+		}
+		if len(r) < 3 {
+			continue
+		}
+		if signedArea(r) < 0 {
+			shells = append(shells, Polygon{r.Reverse()})
+		} else {
+			holes = append(holes, r.Reverse())
+		}
+	}
+
+	for _, h := range holes {
+		in := (&Polygon{h}).InteriorPoint()
+		best, bestArea := -1, math.Inf(1)
+		for i, s := range shells {
+			if a := math.Abs(signedArea(s[0])); a < bestArea && pointInRing(in, s[0]) {
+				best, bestArea = i, a
+			}
+		}
+		if best < 0 {
+			shells = append(shells, Polygon{h.Reverse()})
+		} else {
+			shells[best] = append(shells[best], h)
+		}
+	}
+
+	if len(shells) == 1 {
+		return &shells[0]
+	}
+	mp := MultiPolygon(shells)
+	return &mp
+}
+
+// WriteShapefile writes the features to the .shp, .shx and .dbf files
+// at path, the .shp extension being optional, and prj to the .prj file
+// unless it is empty. DBF strings are written as UTF-8, as recorded in a
+// .cpg file.
+func WriteShapefile(path string, fc FeatureCollection, prj string) error {
+	base := strings.TrimSuffix(path, ".shp")
+	shp, shx, dbf := new(bytes.Buffer), new(bytes.Buffer), new(bytes.Buffer)
+	if err := EncodeShapefile(fc, shp, shx, dbf); err != nil {
+		return err
+	}
+	files := map[string][]byte{".shp": shp.Bytes(), ".shx": shx.Bytes(), ".dbf": dbf.Bytes(), ".cpg": []byte("UTF-8")}
+	if prj != "" {
+		files[".prj"] = []byte(prj)
+	}
+	for ext, data := range files {
+		if err := os.WriteFile(base+ext, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeShapefile writes the features as a shapefile. All geometries must
+// map to the same shape type: Point, MultiPoint, LineString and
+// MultiLineString as PolyLine, or Polygon and MultiPolygon. Z shape types
+// are written when any point has a Z value.
+func EncodeShapefile(fc FeatureCollection, shp, shx, dbf io.Writer) error {
+	t := NullShape
+	hasZ := false
+	for _, f := range fc.Features {
+		if f.Geometry == nil {
+			continue
+		}
+		var ft ShapeType
+		switch f.Geometry.(type) {
+		case *Point:
+			ft = PointShape
+		case *MultiPoint:
+			ft = MultiPointShape
+		case *LineString, *MultiLineString:
+			ft = PolyLineShape
+		case *Polygon, *MultiPolygon:
+			ft = PolygonShape
+		default:
+			return fmt.Errorf("Shapefile: Geometry %T not supported", f.Geometry)
+		}
+		if t != NullShape && t != ft {
+			return fmt.Errorf("Shapefile: cannot mix shape types %d and %d", t, ft)
+		}
+		t = ft
+		mapGeometry(f.Geometry, func(p Point) (Point, error) {
+			hasZ = hasZ || p.Z != 0
+			return p, nil
+		})
+	}
+	if hasZ {
+		t += 10
+	}
+
+	records := [][]byte{}
+	bounds := EmptyBounds()
+	zMin, zMax := math.Inf(1), math.Inf(-1)
+	for _, f := range fc.Features {
+		rec, pts := encodeShape(f.Geometry, t)
+		records = append(records, rec)
+		for _, p := range pts {
+			bounds = bounds.ExtendPoint(p)
+			zMin, zMax = math.Min(zMin, p.Z), math.Max(zMax, p.Z)
+		}
+	}
+	if bounds.IsEmpty() {
+		bounds = Bounds{}
+	}
+	if !hasZ {
+		zMin, zMax = 0, 0
+	}
+
+	header := func(words int) []byte {
+		h := make([]byte, 100)
+		binary.BigEndian.PutUint32(h, 9994)
+		binary.BigEndian.PutUint32(h[24:], uint32(words))
+		binary.LittleEndian.PutUint32(h[28:], 1000)
+		binary.LittleEndian.PutUint32(h[32:], uint32(t))
+		for i, v := range []float64{bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY, zMin, zMax} {
+			binary.LittleEndian.PutUint64(h[36+8*i:], math.Float64bits(v))
+		}
+		return h
+	}
+
+	shpWords := 50
+	for _, rec := range records {
+		shpWords += 4 + len(rec)/2
+	}
+	shpBuf := bytes.NewBuffer(header(shpWords))
+	shxBuf := bytes.NewBuffer(header(50 + 4*len(records)))
+	for i, rec := range records {
+		binary.Write(shxBuf, binary.BigEndian, []int32{int32(shpBuf.Len() / 2), int32(len(rec) / 2)})
+		binary.Write(shpBuf, binary.BigEndian, []int32{int32(i + 1), int32(len(rec) / 2)})
+		shpBuf.Write(rec)
+	}
+
+	props := make([]map[string]interface{}, len(fc.Features))
+	for i, f := range fc.Features {
+		props[i] = f.Properties
+	}
+	fields, err := dbfFields(props)
+	if err != nil {
+		return err
+	}
+	table, err := encodeDBF(fields, props)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range []struct {
+		w    io.Writer
+		data []byte
+	}{{shp, shpBuf.Bytes()}, {shx, shxBuf.Bytes()}, {dbf, table}} {
+		if _, err := w.w.Write(w.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeShape returns the record content for a geometry and the points
+// written. Polygon shells are written clockwise and holes
+// counter-clockwise, with closing points.
+func encodeShape(g Geometry, t ShapeType) ([]byte, []Point) {
+	buf := new(bytes.Buffer)
+	if g == nil {
+		binary.Write(buf, binary.LittleEndian, int32(NullShape))
+		return buf.Bytes(), nil
+	}
+	binary.Write(buf, binary.LittleEndian, int32(t))
+
+	var parts [][]Point
+	switch v := g.(type) {
+	case *Point:
+		coords := []float64{v.X, v.Y}
+		if t.hasZ() {
+			coords = append(coords, v.Z, 0)
+		}
+		binary.Write(buf, binary.LittleEndian, coords)
+		return buf.Bytes(), []Point{*v}
+	case *MultiPoint:
+		parts = [][]Point{*v}
+	case *LineString:
+		parts = [][]Point{*v}
+	case *MultiLineString:
+		for _, l := range *v {
+			parts = append(parts, l)
+		}
+	case *Polygon:
+		parts = shapeRings(*v)
+	case *MultiPolygon:
+		for _, p := range *v {
+			parts = append(parts, shapeRings(p)...)
+		}
+	}
+
+	pts := []Point{}
+	starts := []int32{}
+	for _, p := range parts {
+		starts = append(starts, int32(len(pts)))
+		pts = append(pts, p...)
+	}
+	b := pointsBounds(pts)
+	if b.IsEmpty() {
+		b = Bounds{}
+	}
+	binary.Write(buf, binary.LittleEndian, []float64{b.MinX, b.MinY, b.MaxX, b.MaxY})
+	if t.base() != MultiPointShape {
+		binary.Write(buf, binary.LittleEndian, int32(len(parts)))
+	}
+	binary.Write(buf, binary.LittleEndian, int32(len(pts)))
+	if t.base() != MultiPointShape {
+		binary.Write(buf, binary.LittleEndian, starts)
+	}
+	for _, p := range pts {
+		binary.Write(buf, binary.LittleEndian, []float64{p.X, p.Y})
+	}
+	if t.hasZ() {
+		zMin, zMax := math.Inf(1), math.Inf(-1)
+		for _, p := range pts {
+			zMin, zMax = math.Min(zMin, p.Z), math.Max(zMax, p.Z)
+		}
+		if len(pts) == 0 {
+			zMin, zMax = 0, 0
+		}
+		binary.Write(buf, binary.LittleEndian, []float64{zMin, zMax})
+		for _, p := range pts {
+			binary.Write(buf, binary.LittleEndian, p.Z)
+		}
+	}
+	return buf.Bytes(), pts
+}
+
+func shapeRings(p Polygon) [][]Point {
+	rings := [][]Point{}
+	for i, r := range p {
+		if len(r) == 0 {
+			continue
+		}
+		if (i == 0) == r.IsCCW() {
+			r = r.Reverse()
+		}
+		rings = append(rings, append(append([]Point{}, r...), r[0]))
+	}
+	return rings
+}
@@ -0,0 +1,105 @@
+package geometry
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// HausdorffDistance returns the discrete Hausdorff distance between the
+// linework of two geometries: the greatest distance from a vertex of one
+// to the nearest point of the other. When densifyFrac is in (0, 1] each
+// segment is first split into pieces no longer than that fraction of it,
+// bringing the result closer to the continuous Hausdorff distance.
+func HausdorffDistance(a, b Geometry, densifyFrac float64) (float64, error) {
+	if densifyFrac < 0 || densifyFrac > 1 {
+		return 0, fmt.Errorf("Hausdorff: densify fraction %g not in [0, 1]", densifyFrac)
+	}
+	pa, err := splitDistanceParts(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := splitDistanceParts(b)
+	if err != nil {
+		return 0, err
+	}
+	return math.Max(directedHausdorff(pa.segs, pb.segs, densifyFrac), directedHausdorff(pb.segs, pa.segs, densifyFrac)), nil
+}
+
+func directedHausdorff(from, to [][2]Point, densifyFrac float64) float64 {
+	steps := 1
+	if densifyFrac > 0 {
+		steps = int(math.Ceil(1 / densifyFrac))
+	}
+	nearest := func(pt Point) float64 {
+		best := math.Inf(1)
+		for _, s := range to {
+			best = math.Min(best, segmentDistance(pt, s[0], s[1]))
+		}
+		return best
+	}
+
+	max := 0.0
+	for _, s := range from {
+		for i := 0; i <= steps; i++ {
+			t := float64(i) / float64(steps)
+			pt := Point{X: s[0].X + t*(s[1].X-s[0].X), Y: s[0].Y + t*(s[1].Y-s[0].Y)}
+			max = math.Max(max, nearest(pt))
+		}
+	}
+	return max
+}
+
+// FrechetDistance returns the discrete Fréchet distance between the
+// vertices of two LineStrings, or the closed shells of two Polygons,
+// taking the order of the vertices into account.
+func FrechetDistance(a, b Geometry) (float64, error) {
+	pa, err := frechetPoints(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := frechetPoints(b)
+	if err != nil {
+		return 0, err
+	}
+
+	// Keep a single row of the coupling table, ca[j] holding the distance
+	// for the current vertex of a and vertex j of b.
+	ca := make([]float64, len(pb))
+	for i, p := range pa {
+		diag := 0.0
+		for j, q := range pb {
+			d := math.Hypot(p.X-q.X, p.Y-q.Y)
+			up := ca[j]
+			switch {
+			case i == 0 && j == 0:
+				ca[j] = d
+			case i == 0:
+				ca[j] = math.Max(ca[j-1], d)
+			case j == 0:
+				ca[j] = math.Max(up, d)
+			default:
+				ca[j] = math.Max(math.Min(math.Min(up, ca[j-1]), diag), d)
+			}
+			diag = up
+		}
+	}
+	return ca[len(ca)-1], nil
+}
+
+func frechetPoints(g Geometry) ([]Point, error) {
+	switch t := g.(type) {
+	case *LineString:
+		if len(*t) > 0 {
+			return *t, nil
+		}
+	case *Polygon:
+		if len(*t) > 0 && len((*t)[0]) > 0 {
+			shell := (*t)[0]
+			return append(append([]Point{}, shell...), shell[0]), nil
+		}
+	default:
+		return nil, fmt.Errorf("Frechet: Geometry %T not supported", g)
+	}
+	return nil, errors.New("Frechet: empty geometry")
+}
@@ -0,0 +1,295 @@
+// Package tile provides XYZ and TMS tile arithmetic on the Web Mercator
+// tiling scheme and the tiles covering geometries with longitude and
+// latitude coordinates.
+package tile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/nci/geometry"
+)
+
+// MaxLat is the latitude at which Web Mercator tiles end.
+const MaxLat = geometry.MaxMercatorLat
+
+// Tile is an XYZ tile, X counting east from longitude -180 and Y counting
+// south from MaxLat.
+type Tile struct {
+	X, Y, Z int
+}
+
+// String returns the tile as z/x/y.
+func (t Tile) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// Valid reports whether the zoom is not negative and X and Y are within
+// the tiles at that zoom.
+func (t Tile) Valid() bool {
+	n := 1 << uint(t.Z)
+	return t.Z >= 0 && t.X >= 0 && t.X < n && t.Y >= 0 && t.Y < n
+}
+
+func clampIndex(v float64, z int) int {
+	return int(math.Max(0, math.Min(math.Exp2(float64(z))-1, math.Floor(v))))
+}
+
+// FromLonLat returns the tile containing a longitude and latitude.
+func FromLonLat(lon, lat float64, z int) Tile {
+	x, y := geometry.MercatorTileXY(lon, lat, z)
+	return Tile{X: clampIndex(x, z), Y: clampIndex(y, z), Z: z}
+}
+
+// Bounds returns the longitude and latitude bounds of the tile.
+func (t Tile) Bounds() geometry.Bounds {
+	minX, minY := geometry.MercatorTileLonLat(float64(t.X), float64(t.Y+1), t.Z)
+	maxX, maxY := geometry.MercatorTileLonLat(float64(t.X+1), float64(t.Y), t.Z)
+	return geometry.Bounds{MinX: minX, MinY: minY, MaxX: maxX, MaxY: maxY}
+}
+
+// MercatorBounds returns the bounds of the tile in Web Mercator metres.
+func (t Tile) MercatorBounds() geometry.Bounds {
+	half := math.Pi * geometry.WGS84Ellipsoid.A
+	size := 2 * half / math.Exp2(float64(t.Z))
+	return geometry.Bounds{
+		MinX: -half + float64(t.X)*size, MinY: half - float64(t.Y+1)*size,
+		MaxX: -half + float64(t.X+1)*size, MaxY: half - float64(t.Y)*size,
+	}
+}
+
+// Polygon returns the longitude and latitude bounds of the tile as a
+// polygon.
+func (t Tile) Polygon() geometry.Polygon {
+	return t.Bounds().AsPolygon()
+}
+
+// TMS returns the tile numbered in the TMS scheme, with Y counting north.
+// Converting a TMS tile gives back the XYZ tile.
+func (t Tile) TMS() Tile {
+	return Tile{X: t.X, Y: (1 << uint(t.Z)) - 1 - t.Y, Z: t.Z}
+}
+
+// Parent returns the tile at the previous zoom holding t, or t itself at
+// zoom 0.
+func (t Tile) Parent() Tile {
+	if t.Z == 0 {
+		return t
+	}
+	return Tile{X: t.X >> 1, Y: t.Y >> 1, Z: t.Z - 1}
+}
+
+// Children returns the four tiles at the next zoom, north west, north
+// east, south east then south west.
+func (t Tile) Children() [4]Tile {
+	x, y, z := t.X*2, t.Y*2, t.Z+1
+	return [4]Tile{{x, y, z}, {x + 1, y, z}, {x + 1, y + 1, z}, {x, y + 1, z}}
+}
+
+// Quadkey returns the Bing Maps quadkey of the tile.
+func (t Tile) Quadkey() string {
+	key := make([]byte, t.Z)
+	for i := t.Z; i > 0; i-- {
+		digit := byte('0')
+		mask := 1 << uint(i-1)
+		if t.X&mask != 0 {
+			digit++
+		}
+		if t.Y&mask != 0 {
+			digit += 2
+		}
+		key[t.Z-i] = digit
+	}
+	return string(key)
+}
+
+// FromQuadkey returns the tile of a Bing Maps quadkey, its zoom being the
+// length of the key.
+func FromQuadkey(key string) (Tile, error) {
+	t := Tile{Z: len(key)}
+	for i, c := range key {
+		mask := 1 << uint(t.Z-i-1)
+		switch c {
+		case '0':
+		case '1':
+			t.X |= mask
+		case '2':
+			t.Y |= mask
+		case '3':
+			t.X |= mask
+			t.Y |= mask
+		default:
+			return Tile{}, fmt.Errorf("Invalid quadkey digit %q", c)
+		}
+	}
+	return t, nil
+}
+
+// Cover returns the tiles at zoom z that a Point, LineString, Polygon or
+// their multi types touch, ordered by row then column. Lines and polygon
+// edges are taken as straight in Web Mercator.
+func Cover(g geometry.Geometry, z int) ([]Tile, error) {
+	if z < 0 || z > 30 {
+		return nil, errors.New("Zoom level out of range")
+	}
+	c := cover{z: z, tiles: map[Tile]bool{}}
+	switch t := g.(type) {
+	case *geometry.Point:
+		c.points([]geometry.Point{*t})
+	case *geometry.MultiPoint:
+		c.points(*t)
+	case *geometry.LineString:
+		c.line(c.project(*t), false)
+	case *geometry.MultiLineString:
+		for _, l := range *t {
+			c.line(c.project(l), false)
+		}
+	case *geometry.Polygon:
+		c.polygon(*t)
+	case *geometry.MultiPolygon:
+		for _, p := range *t {
+			c.polygon(p)
+		}
+	default:
+		return nil, fmt.Errorf("Geometry %T not supported", g)
+	}
+
+	tiles := make([]Tile, 0, len(c.tiles))
+	for t := range c.tiles {
+		tiles = append(tiles, t)
+	}
+	sort.Slice(tiles, func(i, j int) bool {
+		return tiles[i].Y < tiles[j].Y || (tiles[i].Y == tiles[j].Y && tiles[i].X < tiles[j].X)
+	})
+	return tiles, nil
+}
+
+type cover struct {
+	z     int
+	tiles map[Tile]bool
+}
+
+func (c *cover) add(x, y int) {
+	n := 1 << uint(c.z)
+	if x >= 0 && x < n && y >= 0 && y < n {
+		c.tiles[Tile{X: x, Y: y, Z: c.z}] = true
+	}
+}
+
+// project converts points to tile units at the cover's zoom.
+func (c *cover) project(pts []geometry.Point) []geometry.Point {
+	out := make([]geometry.Point, len(pts))
+	for i, p := range pts {
+		out[i].X, out[i].Y = geometry.MercatorTileXY(p.X, p.Y, c.z)
+	}
+	return out
+}
+
+func (c *cover) points(pts []geometry.Point) {
+	for _, p := range pts {
+		t := FromLonLat(p.X, p.Y, c.z)
+		c.add(t.X, t.Y)
+	}
+}
+
+// line adds the tiles each segment passes through, stepping from tile to
+// tile along the segment.
+func (c *cover) line(pts []geometry.Point, closed bool) {
+	n := math.Exp2(float64(c.z))
+	cell := func(v float64) int {
+		return int(math.Floor(math.Min(v, n-1e-9)))
+	}
+	segs := len(pts) - 1
+	if closed {
+		segs = len(pts)
+	}
+	if len(pts) == 1 {
+		c.add(cell(pts[0].X), cell(pts[0].Y))
+	}
+	for i := 0; i < segs; i++ {
+		a, b := pts[i], pts[(i+1)%len(pts)]
+		x, y := cell(a.X), cell(a.Y)
+		ex, ey := cell(b.X), cell(b.Y)
+		c.add(x, y)
+
+		dx, dy := b.X-a.X, b.Y-a.Y
+		sx, sy := 1, 1
+		if dx < 0 {
+			sx = -1
+		}
+		if dy < 0 {
+			sy = -1
+		}
+		next := func(v float64, i, s int, d float64) float64 {
+			if d == 0 {
+				return math.Inf(1)
+			}
+			edge := float64(i)
+			if s > 0 {
+				edge++
+			}
+			return (edge - v) / d
+		}
+		tMaxX, tMaxY := next(a.X, x, sx, dx), next(a.Y, y, sy, dy)
+		tDeltaX, tDeltaY := math.Abs(1/dx), math.Abs(1/dy)
+
+		// A four connected walk from the start to the end tile takes
+		// exactly this many steps.
+		steps := abs(ex-x) + abs(ey-y)
+		for k := 0; k < steps; k++ {
+			if (tMaxX < tMaxY && x != ex) || y == ey {
+				tMaxX += tDeltaX
+				x += sx
+			} else {
+				tMaxY += tDeltaY
+				y += sy
+			}
+			c.add(x, y)
+		}
+	}
+}
+
+// polygon adds the tiles along the ring edges, then those whose centres
+// are inside the polygon, found by scanning the centre line of each row.
+func (c *cover) polygon(p geometry.Polygon) {
+	rings := make([][]geometry.Point, len(p))
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for i, r := range p {
+		rings[i] = c.project(r)
+		c.line(rings[i], true)
+		for _, pt := range rings[i] {
+			minY, maxY = math.Min(minY, pt.Y), math.Max(maxY, pt.Y)
+		}
+	}
+	if len(rings) == 0 || len(rings[0]) < 3 {
+		return
+	}
+
+	for y := int(math.Floor(minY)); float64(y) <= maxY; y++ {
+		cy := float64(y) + 0.5
+		xs := []float64{}
+		for _, r := range rings {
+			for i := range r {
+				a, b := r[i], r[(i+1)%len(r)]
+				if (a.Y <= cy) != (b.Y <= cy) {
+					xs = append(xs, a.X+(cy-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+				}
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			for x := int(math.Ceil(xs[i] - 0.5)); float64(x)+0.5 <= xs[i+1]; x++ {
+				c.add(x, y)
+			}
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
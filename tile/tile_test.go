@@ -0,0 +1,102 @@
+package tile
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nci/geometry"
+)
+
+// lonLat converts a position in tile units at zoom z to longitude and
+// latitude.
+func lonLat(x, y float64, z int) geometry.Point {
+	n := math.Exp2(float64(z))
+	return geometry.Point{X: x/n*360 - 180, Y: math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi}
+}
+
+func TestTile(t *testing.T) {
+	tl := FromLonLat(149.13, -35.28, 10)
+	if tl != (Tile{X: 936, Y: 619, Z: 10}) || !tl.Valid() {
+		t.Errorf("Tile Test failed, unexpected tile for Canberra: %v", tl)
+	}
+	b := tl.Bounds()
+	if b.MinX > 149.13 || b.MaxX < 149.13 || b.MinY > -35.28 || b.MaxY < -35.28 {
+		t.Errorf("Tile Test failed, expected bounds around Canberra, got: %v", b)
+	}
+	if FromLonLat(180, -90, 2) != (Tile{X: 3, Y: 3, Z: 2}) {
+		t.Errorf("Tile Test failed, expected corner tile clamped, got: %v", FromLonLat(180, -90, 2))
+	}
+
+	mb := Tile{X: 0, Y: 0, Z: 1}.MercatorBounds()
+	if math.Abs(mb.MinX+20037508.342789244) > 1e-6 || mb.MaxX != 0 || mb.MinY != 0 || math.Abs(mb.MaxY-20037508.342789244) > 1e-6 {
+		t.Errorf("Tile Test failed, unexpected Mercator bounds: %v", mb)
+	}
+
+	q := Tile{X: 3, Y: 5, Z: 3}
+	if q.Quadkey() != "213" {
+		t.Errorf("Tile Test failed, expected quadkey 213, got: %s", q.Quadkey())
+	}
+	if back, err := FromQuadkey("213"); err != nil || back != q {
+		t.Errorf("Tile Test failed, expected %v from quadkey, got: %v %v", q, back, err)
+	}
+	if _, err := FromQuadkey("214"); err == nil {
+		t.Errorf("Tile Test failed, expected error for invalid quadkey")
+	}
+	if q.TMS() != (Tile{X: 3, Y: 2, Z: 3}) || q.TMS().TMS() != q {
+		t.Errorf("Tile Test failed, unexpected TMS tile: %v", q.TMS())
+	}
+	for _, c := range q.Children() {
+		if c.Parent() != q {
+			t.Errorf("Tile Test failed, expected parent %v of %v, got: %v", q, c, c.Parent())
+		}
+	}
+	if q.String() != "3/3/5" {
+		t.Errorf("Tile Test failed, unexpected string: %s", q)
+	}
+}
+
+func TestCover(t *testing.T) {
+	const z = 8
+	ring := func(x0, y0, x1, y1 float64) geometry.LinearRing {
+		return geometry.LinearRing{lonLat(x0, y1, z), lonLat(x1, y1, z), lonLat(x1, y0, z), lonLat(x0, y0, z)}
+	}
+
+	// A square of 6 by 6 tiles with a hole wholly containing the centre
+	// 2 by 2 tiles.
+	poly := geometry.Polygon{ring(10.01, 10.01, 15.99, 15.99), ring(11.5, 11.5, 14.5, 14.5).Reverse()}
+	tiles, err := Cover(&poly, z)
+	if err != nil {
+		t.Fatalf("Cover Test failed, error: %s", err)
+	}
+	if len(tiles) != 32 || tiles[0] != (Tile{X: 10, Y: 10, Z: z}) || tiles[31] != (Tile{X: 15, Y: 15, Z: z}) {
+		t.Errorf("Cover Test failed, expected 32 tiles, got: %v", tiles)
+	}
+	for _, tl := range tiles {
+		if tl.X >= 12 && tl.X <= 13 && tl.Y >= 12 && tl.Y <= 13 {
+			t.Errorf("Cover Test failed, unexpected tile in hole: %v", tl)
+		}
+	}
+
+	// A diagonal polygon covers fewer tiles than its bounding box.
+	tri := geometry.Polygon{{lonLat(0.5, 0.3, z), lonLat(9.5, 9.3, z), lonLat(0.5, 9.3, z)}}
+	if tiles, _ := Cover(&tri, z); len(tiles) != 64 {
+		t.Errorf("Cover Test failed, expected 64 tiles under the triangle, got: %d", len(tiles))
+	}
+
+	line := geometry.LineString{lonLat(0.5, 0.5, z), lonLat(2.5, 1.5, z)}
+	expected := []Tile{{0, 0, z}, {1, 0, z}, {1, 1, z}, {2, 1, z}}
+	tiles, _ = Cover(&line, z)
+	if len(tiles) != len(expected) {
+		t.Fatalf("Cover Test failed, expected %v, got: %v", expected, tiles)
+	}
+	for i := range tiles {
+		if tiles[i] != expected[i] {
+			t.Errorf("Cover Test failed, expected %v, got: %v", expected, tiles)
+		}
+	}
+
+	pts := geometry.MultiPoint{lonLat(3.2, 4.7, z), lonLat(3.9, 4.1, z)}
+	if tiles, _ := Cover(&pts, z); len(tiles) != 1 || tiles[0] != (Tile{3, 4, z}) {
+		t.Errorf("Cover Test failed, expected single tile for points, got: %v", tiles)
+	}
+}
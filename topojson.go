@@ -0,0 +1,508 @@
+package geometry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type topoTransform struct {
+	Scale     [2]float64 `json:"scale"`
+	Translate [2]float64 `json:"translate"`
+}
+
+type topoGeometry struct {
+	Type        *string                `json:"type"`
+	Geometries  []topoGeometry         `json:"geometries,omitempty"`
+	Arcs        json.RawMessage        `json:"arcs,omitempty"`
+	Coordinates json.RawMessage        `json:"coordinates,omitempty"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+}
+
+type topology struct {
+	Type      string                  `json:"type"`
+	Transform *topoTransform          `json:"transform,omitempty"`
+	BBox      []float64               `json:"bbox,omitempty"`
+	Objects   map[string]topoGeometry `json:"objects"`
+	Arcs      [][][2]float64          `json:"arcs"`
+}
+
+// topoBuilder cuts lines and rings into arcs at the junctions where they
+// meet, storing each arc once and referring to it by index, or by its one's
+// complement when traversed backwards.
+type topoBuilder struct {
+	neighbours map[[2]float64][2][2]float64
+	junctions  map[[2]float64]bool
+	arcs       [][][2]float64
+	index      map[string]int
+}
+
+func arcKey(pts [][2]float64) string {
+	var b strings.Builder
+	for _, p := range pts {
+		b.WriteString(strconv.FormatFloat(p[0], 'g', -1, 64))
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(p[1], 'g', -1, 64))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// visit records the neighbours of each point of a line or, when closed, a
+// ring, marking points met with different neighbours as junctions.
+func (t *topoBuilder) visit(pts [][2]float64, closed bool) {
+	n := len(pts)
+	for i, p := range pts {
+		if !closed && (i == 0 || i == n-1) {
+			t.junctions[p] = true
+			continue
+		}
+		a, b := pts[(i+n-1)%n], pts[(i+1)%n]
+		if b[0] < a[0] || (b[0] == a[0] && b[1] < a[1]) {
+			a, b = b, a
+		}
+		if old, ok := t.neighbours[p]; ok && old != [2][2]float64{a, b} {
+			t.junctions[p] = true
+		}
+		t.neighbours[p] = [2][2]float64{a, b}
+	}
+}
+
+func (t *topoBuilder) arc(pts [][2]float64) int {
+	key := arcKey(pts)
+	if i, ok := t.index[key]; ok {
+		return i
+	}
+	rev := make([][2]float64, len(pts))
+	for i, p := range pts {
+		rev[len(pts)-1-i] = p
+	}
+	if i, ok := t.index[arcKey(rev)]; ok {
+		return ^i
+	}
+	t.index[key] = len(t.arcs)
+	t.arcs = append(t.arcs, pts)
+	return len(t.arcs) - 1
+}
+
+// cut splits a line, or a ring given without its closing point, into arcs
+// at its junctions. Rings without junctions start at their least point so
+// that the same ring is stored once.
+func (t *topoBuilder) cut(pts [][2]float64, closed bool) []int {
+	if closed {
+		start := -1
+		for i, p := range pts {
+			if t.junctions[p] {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			start = 0
+			for i, p := range pts {
+				if p[0] < pts[start][0] || (p[0] == pts[start][0] && p[1] < pts[start][1]) {
+					start = i
+				}
+			}
+		}
+		rotated := append(append([][2]float64{}, pts[start:]...), pts[:start]...)
+		pts = append(rotated, rotated[0])
+	}
+
+	ids := []int{}
+	from := 0
+	for i := 1; i < len(pts); i++ {
+		if i == len(pts)-1 || t.junctions[pts[i]] {
+			ids = append(ids, t.arc(pts[from:i+1]))
+			from = i
+		}
+	}
+	if len(pts) == 1 {
+		ids = append(ids, t.arc([][2]float64{pts[0], pts[0]}))
+	}
+	return ids
+}
+
+// MarshalTopoJSON encodes the features as a TopoJSON topology holding a
+// GeometryCollection object named "features". Boundaries shared between
+// geometries are stored once as arcs. When quantization is above one,
+// coordinates are snapped to a grid of that many steps across the bounding
+// box and arcs are delta encoded. Z values are not kept.
+func MarshalTopoJSON(fc FeatureCollection, quantization int) ([]byte, error) {
+	b := EmptyBounds()
+	for _, f := range fc.Features {
+		if f.Geometry == nil {
+			continue
+		}
+		fb, err := BoundsOf(f.Geometry)
+		if err != nil {
+			return nil, err
+		}
+		b = b.Extend(fb)
+	}
+
+	topo := topology{Type: "Topology", Objects: map[string]topoGeometry{}, Arcs: [][][2]float64{}}
+	quantize := func(p Point) [2]float64 { return [2]float64{p.X, p.Y} }
+	if !b.IsEmpty() {
+		topo.BBox = []float64{b.MinX, b.MinY, b.MaxX, b.MaxY}
+		if quantization > 1 {
+			tr := &topoTransform{Scale: [2]float64{1, 1}, Translate: [2]float64{b.MinX, b.MinY}}
+			if b.MaxX > b.MinX {
+				tr.Scale[0] = (b.MaxX - b.MinX) / float64(quantization-1)
+			}
+			if b.MaxY > b.MinY {
+				tr.Scale[1] = (b.MaxY - b.MinY) / float64(quantization-1)
+			}
+			topo.Transform = tr
+			quantize = func(p Point) [2]float64 {
+				return [2]float64{math.Round((p.X - tr.Translate[0]) / tr.Scale[0]), math.Round((p.Y - tr.Translate[1]) / tr.Scale[1])}
+			}
+		}
+	}
+
+	// Quantize every line and ring, dropping repeated points, then find the
+	// junctions before cutting any arcs.
+	line := func(pts []Point) [][2]float64 {
+		out := [][2]float64{}
+		for _, p := range pts {
+			q := quantize(p)
+			if len(out) == 0 || out[len(out)-1] != q {
+				out = append(out, q)
+			}
+		}
+		return out
+	}
+	ring := func(r LinearRing) [][2]float64 {
+		out := line(r)
+		for len(out) > 1 && out[len(out)-1] == out[0] {
+			out = out[:len(out)-1]
+		}
+		if len(out) < 3 {
+			return nil
+		}
+		return out
+	}
+	polygon := func(p Polygon) [][][2]float64 {
+		rings := [][][2]float64{}
+		for i, r := range p {
+			q := ring(r)
+			if q == nil && i == 0 {
+				return nil
+			}
+			if q != nil {
+				rings = append(rings, q)
+			}
+		}
+		return rings
+	}
+
+	type part struct {
+		lines [][][2]float64
+		polys [][][][2]float64
+	}
+	t := &topoBuilder{neighbours: map[[2]float64][2][2]float64{}, junctions: map[[2]float64]bool{}, index: map[string]int{}}
+	parts := make([]part, len(fc.Features))
+	for i, f := range fc.Features {
+		switch g := f.Geometry.(type) {
+		case *LineString:
+			parts[i].lines = [][][2]float64{line(*g)}
+		case *MultiLineString:
+			for _, l := range *g {
+				parts[i].lines = append(parts[i].lines, line(l))
+			}
+		case *Polygon:
+			if p := polygon(*g); p != nil {
+				parts[i].polys = [][][][2]float64{p}
+			}
+		case *MultiPolygon:
+			for _, p := range *g {
+				if q := polygon(p); q != nil {
+					parts[i].polys = append(parts[i].polys, q)
+				}
+			}
+		}
+		for _, l := range parts[i].lines {
+			t.visit(l, false)
+		}
+		for _, p := range parts[i].polys {
+			for _, r := range p {
+				t.visit(r, true)
+			}
+		}
+	}
+
+	collection := "GeometryCollection"
+	object := topoGeometry{Type: &collection, Geometries: []topoGeometry{}}
+	for i, f := range fc.Features {
+		tg := topoGeometry{Properties: f.Properties}
+		var typ string
+		var arcs, coords interface{}
+		switch g := f.Geometry.(type) {
+		case nil:
+		case *Point:
+			typ, coords = "Point", quantize(*g)
+		case *MultiPoint:
+			pts := [][2]float64{}
+			for _, p := range *g {
+				pts = append(pts, quantize(p))
+			}
+			typ, coords = "MultiPoint", pts
+		case *LineString:
+			typ, arcs = "LineString", t.cut(parts[i].lines[0], false)
+		case *MultiLineString:
+			lines := [][]int{}
+			for _, l := range parts[i].lines {
+				lines = append(lines, t.cut(l, false))
+			}
+			typ, arcs = "MultiLineString", lines
+		case *Polygon, *MultiPolygon:
+			polys := [][][]int{}
+			for _, p := range parts[i].polys {
+				rings := [][]int{}
+				for _, r := range p {
+					rings = append(rings, t.cut(r, true))
+				}
+				polys = append(polys, rings)
+			}
+			if _, ok := g.(*Polygon); ok {
+				typ, arcs = "Polygon", [][]int{}
+				if len(polys) > 0 {
+					arcs = polys[0]
+				}
+			} else {
+				typ, arcs = "MultiPolygon", polys
+			}
+		default:
+			return nil, fmt.Errorf("Geometry %T not supported in TopoJSON", f.Geometry)
+		}
+		if typ != "" {
+			tg.Type = &typ
+		}
+		var err error
+		if arcs != nil {
+			if tg.Arcs, err = json.Marshal(arcs); err != nil {
+				return nil, err
+			}
+		}
+		if coords != nil {
+			if tg.Coordinates, err = json.Marshal(coords); err != nil {
+				return nil, err
+			}
+		}
+		object.Geometries = append(object.Geometries, tg)
+	}
+	topo.Objects["features"] = object
+
+	for _, arc := range t.arcs {
+		if topo.Transform != nil {
+			delta := make([][2]float64, len(arc))
+			for i, p := range arc {
+				delta[i] = p
+				if i > 0 {
+					delta[i] = [2]float64{p[0] - arc[i-1][0], p[1] - arc[i-1][1]}
+				}
+			}
+			arc = delta
+		}
+		topo.Arcs = append(topo.Arcs, arc)
+	}
+
+	return json.Marshal(topo)
+}
+
+// UnmarshalTopoJSON decodes each object of a TopoJSON topology as a
+// FeatureCollection, a GeometryCollection giving a feature for each of its
+// geometries.
+func UnmarshalTopoJSON(in []byte) (map[string]FeatureCollection, error) {
+	var topo struct {
+		Type      string                  `json:"type"`
+		Transform *topoTransform          `json:"transform"`
+		Objects   map[string]topoGeometry `json:"objects"`
+		Arcs      [][][]float64           `json:"arcs"`
+	}
+	if err := json.Unmarshal(in, &topo); err != nil {
+		return nil, err
+	}
+	if topo.Type != "Topology" {
+		return nil, fmt.Errorf("Not a TopoJSON Topology: %s", topo.Type)
+	}
+
+	position := func(c []float64) (Point, error) {
+		if len(c) < 2 {
+			return Point{}, errors.New("TopoJSON position with fewer than two values")
+		}
+		p := Point{X: c[0], Y: c[1]}
+		if topo.Transform != nil {
+			p.X = p.X*topo.Transform.Scale[0] + topo.Transform.Translate[0]
+			p.Y = p.Y*topo.Transform.Scale[1] + topo.Transform.Translate[1]
+		}
+		return p, nil
+	}
+
+	arcs := make([][]Point, len(topo.Arcs))
+	for i, arc := range topo.Arcs {
+		var x, y float64
+		for j, c := range arc {
+			if len(c) < 2 {
+				return nil, fmt.Errorf("TopoJSON arc %d has a position with fewer than two values", i)
+			}
+			if topo.Transform == nil || j == 0 {
+				x, y = c[0], c[1]
+			} else {
+				x, y = x+c[0], y+c[1]
+			}
+			p, _ := position([]float64{x, y})
+			arcs[i] = append(arcs[i], p)
+		}
+	}
+
+	d := topoDecoder{arcs: arcs, position: position}
+	out := map[string]FeatureCollection{}
+	for name, obj := range topo.Objects {
+		fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+		if err := d.features(obj, &fc); err != nil {
+			return nil, fmt.Errorf("TopoJSON object %s: %s", name, err)
+		}
+		out[name] = fc
+	}
+	return out, nil
+}
+
+type topoDecoder struct {
+	arcs     [][]Point
+	position func([]float64) (Point, error)
+}
+
+func (d topoDecoder) features(tg topoGeometry, fc *FeatureCollection) error {
+	if tg.Type != nil && *tg.Type == "GeometryCollection" {
+		for _, g := range tg.Geometries {
+			if err := d.features(g, fc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	g, err := d.geometry(tg)
+	if err != nil {
+		return err
+	}
+	fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: g, Properties: tg.Properties})
+	return nil
+}
+
+// line joins arcs, dropping the point each arc shares with the one before.
+func (d topoDecoder) line(ids []int) ([]Point, error) {
+	pts := []Point{}
+	for _, id := range ids {
+		i := id
+		if id < 0 {
+			i = ^id
+		}
+		if i >= len(d.arcs) {
+			return nil, fmt.Errorf("arc %d out of range", id)
+		}
+		arc := d.arcs[i]
+		for j := range arc {
+			k := j
+			if id < 0 {
+				k = len(arc) - 1 - j
+			}
+			if j == 0 && len(pts) > 0 {
+				continue
+			}
+			pts = append(pts, arc[k])
+		}
+	}
+	return pts, nil
+}
+
+func (d topoDecoder) polygon(rings [][]int) (Polygon, error) {
+	p := Polygon{}
+	for _, r := range rings {
+		pts, err := d.line(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(pts) > 1 && pts[0] == pts[len(pts)-1] {
+			pts = pts[:len(pts)-1]
+		}
+		p = append(p, LinearRing(pts))
+	}
+	return p, nil
+}
+
+func (d topoDecoder) geometry(tg topoGeometry) (Geometry, error) {
+	if tg.Type == nil {
+		return nil, nil
+	}
+	switch *tg.Type {
+	case "Point":
+		var c []float64
+		if err := json.Unmarshal(tg.Coordinates, &c); err != nil {
+			return nil, err
+		}
+		p, err := d.position(c)
+		return &p, err
+	case "MultiPoint":
+		var cs [][]float64
+		if err := json.Unmarshal(tg.Coordinates, &cs); err != nil {
+			return nil, err
+		}
+		m := MultiPoint{}
+		for _, c := range cs {
+			p, err := d.position(c)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, p)
+		}
+		return &m, nil
+	case "LineString":
+		var ids []int
+		if err := json.Unmarshal(tg.Arcs, &ids); err != nil {
+			return nil, err
+		}
+		pts, err := d.line(ids)
+		ls := LineString(pts)
+		return &ls, err
+	case "MultiLineString":
+		var ids [][]int
+		if err := json.Unmarshal(tg.Arcs, &ids); err != nil {
+			return nil, err
+		}
+		m := MultiLineString{}
+		for _, l := range ids {
+			pts, err := d.line(l)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, LineString(pts))
+		}
+		return &m, nil
+	case "Polygon":
+		var ids [][]int
+		if err := json.Unmarshal(tg.Arcs, &ids); err != nil {
+			return nil, err
+		}
+		p, err := d.polygon(ids)
+		return &p, err
+	case "MultiPolygon":
+		var ids [][][]int
+		if err := json.Unmarshal(tg.Arcs, &ids); err != nil {
+			return nil, err
+		}
+		m := MultiPolygon{}
+		for _, rings := range ids {
+			p, err := d.polygon(rings)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, p)
+		}
+		return &m, nil
+	}
+	return nil, fmt.Errorf("geometry type %s not supported", *tg.Type)
+}
@@ -0,0 +1,333 @@
+package geometry
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+type TriangulationMethod int
+
+const (
+	// EarClipping clips ears from the polygon outline, joining holes to
+	// the shell first. It is fast but produces thin triangles.
+	EarClipping TriangulationMethod = iota
+	// ConstrainedDelaunay improves the ear clipping triangulation by
+	// flipping interior edges until every triangle is Delaunay apart from
+	// the polygon's own edges.
+	ConstrainedDelaunay
+)
+
+// Triangulation holds triangles as index triples into Vertices, listed in
+// counter-clockwise order. Vertices are the polygon rings flattened in
+// order, shell first, without closing points.
+type Triangulation struct {
+	Vertices []Point
+	Indices  []int
+}
+
+// MultiPolygon returns the triangles as polygons.
+func (t Triangulation) MultiPolygon() MultiPolygon {
+	m := make(MultiPolygon, 0, len(t.Indices)/3)
+	for i := 0; i+2 < len(t.Indices); i += 3 {
+		v := t.Vertices
+		m = append(m, Polygon{LinearRing{v[t.Indices[i]], v[t.Indices[i+1]], v[t.Indices[i+2]]}})
+	}
+	return m
+}
+
+func (p *Polygon) Triangulate(method TriangulationMethod) (Triangulation, error) {
+	t := Triangulation{Vertices: []Point{}, Indices: []int{}}
+	err := t.addPolygon(*p, method)
+	return t, err
+}
+
+func (m *MultiPolygon) Triangulate(method TriangulationMethod) (Triangulation, error) {
+	t := Triangulation{Vertices: []Point{}, Indices: []int{}}
+	for _, p := range *m {
+		if err := t.addPolygon(p, method); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+func (t *Triangulation) addPolygon(p Polygon, method TriangulationMethod) error {
+	if len(p) == 0 {
+		return nil
+	}
+	rings := make([][]int, len(p))
+	for i, r := range p {
+		ids := make([]int, len(r))
+		for j, pt := range r {
+			ids[j] = len(t.Vertices)
+			t.Vertices = append(t.Vertices, pt)
+		}
+		if (i == 0) != (signedArea(r) > 0) {
+			for a, b := 0, len(ids)-1; a < b; a, b = a+1, b-1 {
+				ids[a], ids[b] = ids[b], ids[a]
+			}
+		}
+		rings[i] = ids
+	}
+
+	outline := t.joinHoles(rings[0], rings[1:])
+	tris, err := t.earClip(outline)
+	if err != nil {
+		return err
+	}
+	if method == ConstrainedDelaunay {
+		tris = t.delaunayFlip(tris, rings)
+	}
+	for _, tri := range tris {
+		t.Indices = append(t.Indices, tri[:]...)
+	}
+	return nil
+}
+
+func orient(a, b, c Point) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+func pointInTriangle(p, a, b, c Point) bool {
+	return orient(a, b, p) >= 0 && orient(b, c, p) >= 0 && orient(c, a, p) >= 0
+}
+
+// joinHoles links each clockwise hole into the counter-clockwise shell with
+// a pair of bridge edges, giving a single outline. Holes are taken from
+// the right so each bridge only needs to be checked against the outline.
+func (t *Triangulation) joinHoles(shell []int, holes [][]int) []int {
+	v := t.Vertices
+	maxX := func(h []int) int {
+		best := 0
+		for i, id := range h {
+			if v[id].X > v[h[best]].X {
+				best = i
+			}
+		}
+		return best
+	}
+	sort.Slice(holes, func(i, j int) bool {
+		return v[holes[i][maxX(holes[i])]].X > v[holes[j][maxX(holes[j])]].X
+	})
+
+	// locallyInside reports whether the segment from outline vertex i to
+	// pt starts into the interior of the outline.
+	locallyInside := func(outline []int, i int, pt Point) bool {
+		n := len(outline)
+		a, prev, next := v[outline[i]], v[outline[(i+n-1)%n]], v[outline[(i+1)%n]]
+		if orient(prev, a, next) >= 0 {
+			return orient(a, next, pt) >= 0 && orient(a, pt, prev) >= 0
+		}
+		return orient(a, next, pt) >= 0 || orient(a, pt, prev) >= 0
+	}
+
+	outline := append([]int{}, shell...)
+	for _, h := range holes {
+		if len(h) == 0 {
+			continue
+		}
+		mi := maxX(h)
+		m := v[h[mi]]
+
+		// Cast a ray to the right of m and find the closest outline edge
+		// it hits, taking the edge end furthest right as the candidate.
+		bridge, hitX := -1, math.Inf(1)
+		for i := range outline {
+			a, b := v[outline[i]], v[outline[(i+1)%len(outline)]]
+			if math.Min(a.Y, b.Y) > m.Y || math.Max(a.Y, b.Y) < m.Y || a.Y == b.Y {
+				continue
+			}
+			x := a.X + (m.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y)
+			if x < m.X || x >= hitX {
+				continue
+			}
+			hitX = x
+			switch {
+			case x == a.X && a.Y == m.Y:
+				bridge = i
+			case x == b.X && b.Y == m.Y:
+				bridge = (i + 1) % len(outline)
+			case a.X > b.X:
+				bridge = i
+			default:
+				bridge = (i + 1) % len(outline)
+			}
+		}
+		if bridge < 0 {
+			continue
+		}
+
+		// Outline vertices inside the triangle between m, the hit and the
+		// candidate could block the bridge, choose the one closest in
+		// angle to the ray instead.
+		hit, cand := Point{X: hitX, Y: m.Y}, v[outline[bridge]]
+		if hit != cand {
+			tri := [3]Point{m, hit, cand}
+			if orient(m, hit, cand) < 0 {
+				tri[1], tri[2] = cand, hit
+			}
+			bestTan, bestDist := math.Inf(1), math.Inf(1)
+			for i, id := range outline {
+				r := v[id]
+				if r.X < m.X || r == cand || !pointInTriangle(r, tri[0], tri[1], tri[2]) || !locallyInside(outline, i, m) {
+					continue
+				}
+				tan := math.Abs(r.Y-m.Y) / (r.X - m.X)
+				dist := math.Hypot(r.X-m.X, r.Y-m.Y)
+				if tan < bestTan || (tan == bestTan && dist < bestDist) {
+					bridge, bestTan, bestDist = i, tan, dist
+				}
+			}
+		}
+
+		// Where the outline passes through the bridge point more than
+		// once, pick the pass whose interior faces the hole.
+		if !locallyInside(outline, bridge, m) {
+			for i, id := range outline {
+				if v[id] == v[outline[bridge]] && locallyInside(outline, i, m) {
+					bridge = i
+					break
+				}
+			}
+		}
+
+		joined := append([]int{}, outline[:bridge+1]...)
+		for k := 0; k <= len(h); k++ {
+			joined = append(joined, h[(mi+k)%len(h)])
+		}
+		joined = append(joined, outline[bridge:]...)
+		outline = joined
+	}
+	return outline
+}
+
+// earClip triangulates a counter-clockwise outline that may touch itself
+// along bridge edges.
+func (t *Triangulation) earClip(outline []int) ([][3]int, error) {
+	v := t.Vertices
+	tris := [][3]int{}
+	ring := append([]int{}, outline...)
+
+	isEar := func(i int) bool {
+		n := len(ring)
+		a, b, c := v[ring[(i+n-1)%n]], v[ring[i]], v[ring[(i+1)%n]]
+		if orient(a, b, c) <= 0 {
+			return false
+		}
+		for _, id := range ring {
+			p := v[id]
+			if p == a || p == b || p == c {
+				continue
+			}
+			if pointInTriangle(p, a, b, c) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Resume the search for ears where the last one was clipped.
+	start := 0
+	for len(ring) > 3 {
+		n := len(ring)
+		clipped := false
+		for j := 0; j < n && !clipped; j++ {
+			i := (start + j) % n
+			if isEar(i) {
+				tris = append(tris, [3]int{ring[(i+n-1)%n], ring[i], ring[(i+1)%n]})
+				ring = append(ring[:i], ring[i+1:]...)
+				start, clipped = i, true
+			}
+		}
+		if clipped {
+			continue
+		}
+		// Without an ear the outline is left with collinear or repeated
+		// vertices, which can be dropped without losing area.
+		for i := 0; i < n && !clipped; i++ {
+			if orient(v[ring[(i+n-1)%n]], v[ring[i]], v[ring[(i+1)%n]]) == 0 {
+				ring = append(ring[:i], ring[i+1:]...)
+				clipped = true
+			}
+		}
+		if !clipped {
+			return tris, errors.New("Triangulate: no ear found, the polygon may self-intersect")
+		}
+	}
+	if len(ring) == 3 && orient(v[ring[0]], v[ring[1]], v[ring[2]]) > 0 {
+		tris = append(tris, [3]int{ring[0], ring[1], ring[2]})
+	}
+	return tris, nil
+}
+
+// inCircle reports whether d lies strictly inside the circumcircle of the
+// counter-clockwise triangle abc.
+func inCircle(a, b, c, d Point) bool {
+	ax, ay := a.X-d.X, a.Y-d.Y
+	bx, by := b.X-d.X, b.Y-d.Y
+	cx, cy := c.X-d.X, c.Y-d.Y
+	det := (ax*ax+ay*ay)*(bx*cy-cx*by) - (bx*bx+by*by)*(ax*cy-cx*ay) + (cx*cx+cy*cy)*(ax*by-bx*ay)
+	return det > 0
+}
+
+// delaunayFlip flips interior edges that fail the empty circumcircle test
+// until none remain, leaving the ring edges in place.
+func (t *Triangulation) delaunayFlip(tris [][3]int, rings [][]int) [][3]int {
+	v := t.Vertices
+	key := func(a, b int) [2]int {
+		if a > b {
+			a, b = b, a
+		}
+		return [2]int{a, b}
+	}
+	fixed := map[[2]int]bool{}
+	for _, r := range rings {
+		for i := range r {
+			fixed[key(r[i], r[(i+1)%len(r)])] = true
+		}
+	}
+
+	// owner maps each directed edge to its triangle.
+	owner := map[[2]int]int{}
+	setOwner := func(i int) {
+		for k := 0; k < 3; k++ {
+			owner[[2]int{tris[i][k], tris[i][(k+1)%3]}] = i
+		}
+	}
+	for i := range tris {
+		setOwner(i)
+	}
+
+	for flipped := true; flipped; {
+		flipped = false
+		for i := range tris {
+			for k := 0; k < 3; k++ {
+				a, b, c := tris[i][k], tris[i][(k+1)%3], tris[i][(k+2)%3]
+				j, ok := owner[[2]int{b, a}]
+				if !ok || fixed[key(a, b)] {
+					continue
+				}
+				d := -1
+				for _, id := range tris[j] {
+					if id != a && id != b {
+						d = id
+					}
+				}
+				if d < 0 || !inCircle(v[a], v[b], v[c], v[d]) {
+					continue
+				}
+				if orient(v[a], v[d], v[c]) <= 0 || orient(v[d], v[b], v[c]) <= 0 {
+					continue
+				}
+				delete(owner, [2]int{a, b})
+				delete(owner, [2]int{b, a})
+				tris[i], tris[j] = [3]int{a, d, c}, [3]int{d, b, c}
+				setOwner(i)
+				setOwner(j)
+				flipped = true
+			}
+		}
+	}
+	return tris
+}
@@ -0,0 +1,340 @@
+package geometry
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// TWKBOptions control the encoding of Tiny WKB. Precision is the number of
+// decimal digits kept of X and Y, from -8 to 7, and ZPrecision that of Z,
+// from 0 to 7. Z is written when any point has a non zero Z. BBox and Size
+// add the optional bounding box and size headers. IDs, when set, gives an
+// id to each part of a multi geometry.
+type TWKBOptions struct {
+	Precision  int
+	ZPrecision int
+	BBox       bool
+	Size       bool
+	IDs        []int64
+}
+
+const (
+	twkbBBoxFlag     = 1
+	twkbSizeFlag     = 2
+	twkbIDListFlag   = 4
+	twkbExtendedFlag = 8
+	twkbEmptyFlag    = 16
+)
+
+type twkbEncoder struct {
+	dims   int
+	scales [3]float64
+	prev   [3]int64
+	body   []byte
+	min    [3]int64
+	max    [3]int64
+	seen   bool
+}
+
+func (e *twkbEncoder) uvarint(v uint64) {
+	e.body = binary.AppendUvarint(e.body, v)
+}
+
+func (e *twkbEncoder) points(pts []Point, count bool) {
+	if count {
+		e.uvarint(uint64(len(pts)))
+	}
+	for _, p := range pts {
+		xyz := [3]float64{p.X, p.Y, p.Z}
+		for d, v := range xyz[:e.dims] {
+			q := int64(math.Round(v * e.scales[d]))
+			e.uvarint(zigzag(q - e.prev[d]))
+			e.prev[d] = q
+			if !e.seen || q < e.min[d] {
+				e.min[d] = q
+			}
+			if !e.seen || q > e.max[d] {
+				e.max[d] = q
+			}
+		}
+		e.seen = true
+	}
+}
+
+// polygon writes the rings of p, each with its closing point.
+func (e *twkbEncoder) polygon(p Polygon) {
+	e.uvarint(uint64(len(p)))
+	for _, r := range p {
+		closed := r
+		if len(r) > 0 {
+			closed = append(append(LinearRing{}, r...), r[0])
+		}
+		e.points(closed, true)
+	}
+}
+
+// MarshalTWKB encodes a geometry as Tiny WKB.
+func MarshalTWKB(g Geometry, opts TWKBOptions) ([]byte, error) {
+	if opts.Precision < -8 || opts.Precision > 7 || opts.ZPrecision < 0 || opts.ZPrecision > 7 {
+		return nil, errors.New("TWKB precision out of range")
+	}
+
+	hasZ := false
+	count := 0
+	if _, err := mapGeometry(g, func(p Point) (Point, error) {
+		hasZ = hasZ || p.Z != 0
+		count++
+		return p, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	e := twkbEncoder{dims: 2}
+	e.scales[0] = math.Pow10(opts.Precision)
+	e.scales[1] = e.scales[0]
+	if hasZ {
+		e.dims = 3
+		e.scales[2] = math.Pow10(opts.ZPrecision)
+	}
+
+	var typ byte
+	parts := 0
+	switch t := g.(type) {
+	case *Point:
+		typ = 1
+		e.points([]Point{*t}, false)
+	case *LineString:
+		typ = 2
+		if count > 0 {
+			e.points(*t, true)
+		}
+	case *Polygon:
+		typ = 3
+		if count > 0 {
+			e.polygon(*t)
+		}
+	case *MultiPoint:
+		typ, parts = 4, len(*t)
+	case *MultiLineString:
+		typ, parts = 5, len(*t)
+	case *MultiPolygon:
+		typ, parts = 6, len(*t)
+	}
+
+	if typ < 4 && opts.IDs != nil {
+		return nil, errors.New("TWKB ids are only written for multi geometries")
+	}
+	if typ >= 4 && count > 0 {
+		e.uvarint(uint64(parts))
+		if opts.IDs != nil {
+			if len(opts.IDs) != parts {
+				return nil, fmt.Errorf("TWKB got %d ids for %d parts", len(opts.IDs), parts)
+			}
+			for _, id := range opts.IDs {
+				e.uvarint(zigzag(id))
+			}
+		}
+		switch t := g.(type) {
+		case *MultiPoint:
+			e.points(*t, false)
+		case *MultiLineString:
+			for _, l := range *t {
+				e.points(l, true)
+			}
+		case *MultiPolygon:
+			for _, p := range *t {
+				e.polygon(p)
+			}
+		}
+	}
+
+	out := []byte{typ | byte(zigzag(int64(opts.Precision)))<<4, 0}
+	if count == 0 {
+		out[1] |= twkbEmptyFlag
+		return out, nil
+	}
+	if hasZ {
+		out[1] |= twkbExtendedFlag
+		out = append(out, 1|byte(opts.ZPrecision)<<2)
+	}
+
+	var rest []byte
+	if opts.BBox {
+		out[1] |= twkbBBoxFlag
+		for d := 0; d < e.dims; d++ {
+			rest = binary.AppendUvarint(rest, zigzag(e.min[d]))
+			rest = binary.AppendUvarint(rest, zigzag(e.max[d]-e.min[d]))
+		}
+	}
+	if typ >= 4 && opts.IDs != nil {
+		out[1] |= twkbIDListFlag
+	}
+	rest = append(rest, e.body...)
+	if opts.Size {
+		out[1] |= twkbSizeFlag
+		out = binary.AppendUvarint(out, uint64(len(rest)))
+	}
+	return append(out, rest...), nil
+}
+
+type twkbDecoder struct {
+	buf    []byte
+	err    error
+	dims   int
+	skip   int
+	scales [3]float64
+	prev   [4]int64
+}
+
+func (d *twkbDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errors.New("TWKB truncated")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+// count reads a number of items, each taking at least a byte, so corrupt
+// counts are caught before allocating.
+func (d *twkbDecoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.err = errors.New("TWKB truncated")
+		return 0
+	}
+	return int(n)
+}
+
+func (d *twkbDecoder) points(n int) []Point {
+	pts := make([]Point, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		var xyz [3]float64
+		for k := 0; k < d.dims+d.skip; k++ {
+			d.prev[k] += unzigzag(d.uvarint())
+			if k < d.dims {
+				xyz[k] = float64(d.prev[k]) / d.scales[k]
+			}
+		}
+		pts = append(pts, Point{xyz[0], xyz[1], xyz[2]})
+	}
+	return pts
+}
+
+func (d *twkbDecoder) polygon() Polygon {
+	p := Polygon{}
+	rings := d.count()
+	for i := 0; i < rings && d.err == nil; i++ {
+		r := d.points(d.count())
+		if len(r) > 1 && r[0] == r[len(r)-1] {
+			r = r[:len(r)-1]
+		}
+		p = append(p, LinearRing(r))
+	}
+	return p
+}
+
+// UnmarshalTWKB decodes Tiny WKB, returning the geometry with the ids of
+// its parts when the encoding has an id list. M values are skipped and
+// geometry collections are not supported.
+func UnmarshalTWKB(in []byte) (Geometry, []int64, error) {
+	if len(in) < 2 {
+		return nil, nil, errors.New("TWKB truncated")
+	}
+	typ, flags := in[0]&15, in[1]
+	d := twkbDecoder{buf: in[2:], dims: 2}
+	d.scales[0] = math.Pow10(int(unzigzag(uint64(in[0] >> 4))))
+	d.scales[1] = d.scales[0]
+	if flags&twkbExtendedFlag != 0 {
+		if len(d.buf) == 0 {
+			return nil, nil, errors.New("TWKB truncated")
+		}
+		ext := d.buf[0]
+		d.buf = d.buf[1:]
+		if ext&1 != 0 {
+			d.dims = 3
+			d.scales[2] = math.Pow10(int(ext >> 2 & 7))
+		}
+		if ext&2 != 0 {
+			d.skip = 1
+		}
+	}
+
+	empty := flags&twkbEmptyFlag != 0
+	if flags&twkbSizeFlag != 0 {
+		size := d.uvarint()
+		if size > uint64(len(d.buf)) {
+			return nil, nil, errors.New("TWKB truncated")
+		}
+		d.buf = d.buf[:size]
+	}
+	if flags&twkbBBoxFlag != 0 && !empty {
+		for k := 0; k < 2*(d.dims+d.skip); k++ {
+			d.uvarint()
+		}
+	}
+
+	var g Geometry
+	var ids []int64
+	parts := 0
+	if typ >= 4 && typ <= 6 && !empty {
+		parts = d.count()
+		if flags&twkbIDListFlag != 0 {
+			ids = make([]int64, parts)
+			for i := range ids {
+				ids[i] = unzigzag(d.uvarint())
+			}
+		}
+	}
+
+	switch typ {
+	case 1:
+		if empty {
+			return nil, nil, errors.New("TWKB empty Point not supported")
+		}
+		pts := d.points(1)
+		if d.err != nil {
+			return nil, nil, d.err
+		}
+		g = &pts[0]
+	case 2:
+		l := LineString{}
+		if !empty {
+			l = LineString(d.points(d.count()))
+		}
+		g = &l
+	case 3:
+		p := Polygon{}
+		if !empty {
+			p = d.polygon()
+		}
+		g = &p
+	case 4:
+		m := MultiPoint(d.points(parts))
+		g = &m
+	case 5:
+		m := MultiLineString{}
+		for i := 0; i < parts && d.err == nil; i++ {
+			m = append(m, LineString(d.points(d.count())))
+		}
+		g = &m
+	case 6:
+		m := MultiPolygon{}
+		for i := 0; i < parts && d.err == nil; i++ {
+			m = append(m, d.polygon())
+		}
+		g = &m
+	default:
+		return nil, nil, fmt.Errorf("TWKB geometry type %d not supported", typ)
+	}
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	return g, ids, nil
+}
@@ -0,0 +1,238 @@
+package geometry
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// convexHull returns the indices of the points on the convex hull in
+// counter-clockwise order, leaving out points along hull edges.
+func convexHull(pts []Point, ids []int) []int {
+	sorted := append([]int{}, ids...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := pts[sorted[i]], pts[sorted[j]]
+		return a.X < b.X || (a.X == b.X && a.Y < b.Y)
+	})
+	if len(sorted) < 3 {
+		return sorted
+	}
+
+	hull := []int{}
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for _, id := range sorted {
+			for len(hull) >= start+2 && orient(pts[hull[len(hull)-2]], pts[hull[len(hull)-1]], pts[id]) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, id)
+		}
+		hull = hull[:len(hull)-1]
+		for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+	}
+	return hull
+}
+
+// DelaunayTriangulation triangulates a set of points so that no point lies
+// inside the circumcircle of any triangle. Indices refer to pts, repeated
+// points are triangulated once using their first occurrence.
+func DelaunayTriangulation(pts []Point) (Triangulation, error) {
+	t := Triangulation{Vertices: pts, Indices: []int{}}
+	ids := []int{}
+	seen := map[[2]float64]bool{}
+	for i, pt := range pts {
+		k := [2]float64{pt.X, pt.Y}
+		if !seen[k] {
+			seen[k] = true
+			ids = append(ids, i)
+		}
+	}
+
+	hull := convexHull(pts, ids)
+	if len(hull) < 3 {
+		return t, errors.New("Delaunay: points are collinear or fewer than three")
+	}
+
+	// Fan out from the first hull vertex, then split the triangle holding
+	// each remaining point before flipping edges to restore the
+	// circumcircle condition.
+	tris := [][3]int{}
+	for i := 1; i+1 < len(hull); i++ {
+		tris = append(tris, [3]int{hull[0], hull[i], hull[i+1]})
+	}
+	onHull := map[int]bool{}
+	for _, id := range hull {
+		onHull[id] = true
+	}
+	for _, id := range ids {
+		if !onHull[id] {
+			tris = insertPoint(pts, tris, id)
+		}
+	}
+
+	tris = t.delaunayFlip(tris, nil)
+	for _, tri := range tris {
+		t.Indices = append(t.Indices, tri[:]...)
+	}
+	return t, nil
+}
+
+// insertPoint splits the triangles containing point id, three ways if it
+// is inside a triangle and two ways on each side if it is on an edge.
+func insertPoint(pts []Point, tris [][3]int, id int) [][3]int {
+	p := pts[id]
+	for i, tri := range tris {
+		a, b, c := pts[tri[0]], pts[tri[1]], pts[tri[2]]
+		if !pointInTriangle(p, a, b, c) {
+			continue
+		}
+		for k := 0; k < 3; k++ {
+			u, v, w := tri[k], tri[(k+1)%3], tri[(k+2)%3]
+			if orient(pts[u], pts[v], p) != 0 {
+				continue
+			}
+			tris[i] = [3]int{u, id, w}
+			tris = append(tris, [3]int{id, v, w})
+			for j, other := range tris {
+				for l := 0; l < 3; l++ {
+					if other[l] == v && other[(l+1)%3] == u {
+						x := other[(l+2)%3]
+						tris[j] = [3]int{v, id, x}
+						return append(tris, [3]int{id, u, x})
+					}
+				}
+			}
+			return tris
+		}
+		tris[i] = [3]int{tri[0], tri[1], id}
+		return append(tris, [3]int{tri[1], tri[2], id}, [3]int{tri[2], tri[0], id})
+	}
+	return tris
+}
+
+// VoronoiDiagram returns the Voronoi cell of each point, the region closer
+// to it than to any other point, clipped to a Polygon or MultiPolygon or,
+// when clip is nil, the bounding box of the points. Cells are returned in
+// the order of pts, repeated points sharing a cell.
+func VoronoiDiagram(pts []Point, clip Geometry) ([]MultiPolygon, error) {
+	var region MultiPolygon
+	switch t := clip.(type) {
+	case nil:
+		region = MultiPolygon{pointsBounds(pts).AsPolygon()}
+	case *Polygon:
+		region = MultiPolygon{*t}
+	case *MultiPolygon:
+		region = *t
+	default:
+		return nil, fmt.Errorf("Voronoi: clip Geometry %T not supported", clip)
+	}
+	if len(pts) == 0 {
+		return []MultiPolygon{}, nil
+	}
+
+	// Cells only border those of Delaunay neighbours. Without a
+	// triangulation every point is a potential neighbour.
+	neighbours := make([]map[int]bool, len(pts))
+	for i := range neighbours {
+		neighbours[i] = map[int]bool{}
+	}
+	first := map[[2]float64]int{}
+	for i, pt := range pts {
+		if _, ok := first[[2]float64{pt.X, pt.Y}]; !ok {
+			first[[2]float64{pt.X, pt.Y}] = i
+		}
+	}
+	if tr, err := DelaunayTriangulation(pts); err == nil {
+		for i := 0; i < len(tr.Indices); i += 3 {
+			for k := 0; k < 3; k++ {
+				neighbours[tr.Indices[i+k]][tr.Indices[i+(k+1)%3]] = true
+				neighbours[tr.Indices[i+(k+1)%3]][tr.Indices[i+k]] = true
+			}
+		}
+	} else {
+		for _, i := range first {
+			for _, j := range first {
+				if i != j {
+					neighbours[i][j] = true
+				}
+			}
+		}
+	}
+
+	b := region.Bounds()
+	for _, pt := range pts {
+		b = b.ExtendPoint(pt)
+	}
+	simple := len(region) == 1 && len(region[0]) == 1 && isConvex(region[0][0])
+
+	cells := make([]MultiPolygon, len(pts))
+	for i, pt := range pts {
+		if f := first[[2]float64{pt.X, pt.Y}]; f != i {
+			cells[i] = cells[f]
+			continue
+		}
+		cell := []Point(b.AsPolygon()[0])
+		for j := range neighbours[i] {
+			cell = clipHalfPlane(cell, pt, pts[j])
+		}
+		if len(cell) < 3 {
+			cells[i] = MultiPolygon{}
+			continue
+		}
+
+		clipped := MultiPolygon{}
+		for _, p := range region {
+			q := Polygon{}
+			for _, r := range p {
+				if c := clipConvex(r, cell); len(c) >= 3 {
+					q = append(q, LinearRing(c))
+				}
+			}
+			if len(q) > 0 {
+				clipped = append(clipped, q)
+			}
+		}
+		if simple {
+			clipped = clipped.ForceRHR()
+		} else {
+			// Clipping a concave region can leave zero width bridges
+			// between its parts, which MakeValid removes.
+			clipped = clipped.MakeValid()
+		}
+		cells[i] = clipped
+	}
+	return cells, nil
+}
+
+// clipHalfPlane keeps the part of the convex polygon closer to a than b.
+func clipHalfPlane(poly []Point, a, b Point) []Point {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	mx, my := (a.X+b.X)/2, (a.Y+b.Y)/2
+	side := func(p Point) float64 {
+		return (mx-p.X)*dx + (my-p.Y)*dy
+	}
+	out := []Point{}
+	for i := range poly {
+		p, q := poly[i], poly[(i+1)%len(poly)]
+		sp, sq := side(p), side(q)
+		if sp >= 0 {
+			out = append(out, p)
+		}
+		if (sp >= 0) != (sq >= 0) {
+			t := sp / (sp - sq)
+			out = append(out, Point{X: p.X + t*(q.X-p.X), Y: p.Y + t*(q.Y-p.Y)})
+		}
+	}
+	return out
+}
+
+func isConvex(r LinearRing) bool {
+	pos, neg := false, false
+	for i := range r {
+		o := orient(r[i], r[(i+1)%len(r)], r[(i+2)%len(r)])
+		pos, neg = pos || o > 0, neg || o < 0
+	}
+	return !(pos && neg)
+}
@@ -0,0 +1,80 @@
+package geometry
+
+import "fmt"
+
+// mapGeometry returns a copy of g of the same type with f applied to each
+// of its coordinates.
+func mapGeometry(g Geometry, f func(Point) (Point, error)) (Geometry, error) {
+	switch t := g.(type) {
+	case *Point:
+		p, err := f(*t)
+		if err != nil {
+			return nil, err
+		}
+		return &p, nil
+	case *MultiPoint:
+		mp, err := mapPoints(*t, f)
+		if err != nil {
+			return nil, err
+		}
+		out := MultiPoint(mp)
+		return &out, nil
+	case *LineString:
+		ls, err := mapPoints(*t, f)
+		if err != nil {
+			return nil, err
+		}
+		out := LineString(ls)
+		return &out, nil
+	case *MultiLineString:
+		m := make(MultiLineString, len(*t))
+		for i, l := range *t {
+			ls, err := mapPoints(l, f)
+			if err != nil {
+				return nil, err
+			}
+			m[i] = LineString(ls)
+		}
+		return &m, nil
+	case *Polygon:
+		p, err := mapPolygon(*t, f)
+		if err != nil {
+			return nil, err
+		}
+		return &p, nil
+	case *MultiPolygon:
+		m := make(MultiPolygon, len(*t))
+		for i, p := range *t {
+			var err error
+			if m[i], err = mapPolygon(p, f); err != nil {
+				return nil, err
+			}
+		}
+		return &m, nil
+	}
+
+	return nil, fmt.Errorf("Geometry %T not supported", g)
+}
+
+func mapPoints(pts []Point, f func(Point) (Point, error)) ([]Point, error) {
+	out := make([]Point, len(pts))
+	for i, pt := range pts {
+		var err error
+		if out[i], err = f(pt); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func mapPolygon(p Polygon, f func(Point) (Point, error)) (Polygon, error) {
+	out := make(Polygon, len(p))
+	for i, lr := range p {
+		r, err := mapPoints(lr, f)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = LinearRing(r)
+	}
+	return out, nil
+}
@@ -0,0 +1,298 @@
+package geometry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The WKB of every geometry type is read and written here following ISO
+// 13249-3: geometries are written as XY with the type codes 1 to 6, or as
+// XYZ with the codes 1001 to 1006 when any point has a non zero Z. The Z,
+// M and SRID flags of extended WKB are also read, and M values skipped.
+
+var endian map[uint8]binary.ByteOrder = map[uint8]binary.ByteOrder{0: binary.BigEndian, 1: binary.LittleEndian}
+
+func byteOrderMode(end binary.ByteOrder) uint8 {
+	if end == binary.BigEndian {
+		return 0
+	}
+	return 1
+}
+
+// marshalWKB encodes a geometry as WKB with the byte order given by mode.
+func marshalWKB(g Geometry, mode uint8) []byte {
+	hasZ := false
+	mapGeometry(g, func(p Point) (Point, error) {
+		hasZ = hasZ || p.Z != 0
+		return p, nil
+	})
+	w := &wkbWriter{buf: new(bytes.Buffer), mode: mode, z: hasZ}
+	w.geometry(g)
+	return w.buf.Bytes()
+}
+
+// wkbBody returns the WKB of a geometry without its byte order and type.
+func wkbBody(g Geometry, end binary.ByteOrder) []byte {
+	return marshalWKB(g, byteOrderMode(end))[5:]
+}
+
+type wkbWriter struct {
+	buf  *bytes.Buffer
+	mode uint8
+	z    bool
+}
+
+func (w *wkbWriter) uint32(v int) {
+	binary.Write(w.buf, endian[w.mode], uint32(v))
+}
+
+func (w *wkbWriter) header(typ int) {
+	w.buf.WriteByte(w.mode)
+	if w.z {
+		typ += 1000
+	}
+	w.uint32(typ)
+}
+
+func (w *wkbWriter) point(p Point) {
+	coords := []float64{p.X, p.Y, p.Z}
+	if !w.z {
+		coords = coords[:2]
+	}
+	binary.Write(w.buf, endian[w.mode], coords)
+}
+
+func (w *wkbWriter) line(pts []Point, closed bool) {
+	n := len(pts)
+	if closed && n > 0 {
+		n++
+	}
+	w.uint32(n)
+	for _, p := range pts {
+		w.point(p)
+	}
+	if closed && len(pts) > 0 {
+		w.point(pts[0])
+	}
+}
+
+func (w *wkbWriter) polygon(p Polygon) {
+	w.header(3)
+	w.uint32(len(p))
+	for _, r := range p {
+		w.line(r, true)
+	}
+}
+
+func (w *wkbWriter) geometry(g Geometry) {
+	switch t := g.(type) {
+	case *Point:
+		w.header(1)
+		w.point(*t)
+	case *LineString:
+		w.header(2)
+		w.line(*t, false)
+	case *Polygon:
+		w.polygon(*t)
+	case *MultiPoint:
+		w.header(4)
+		w.uint32(len(*t))
+		for _, p := range *t {
+			w.header(1)
+			w.point(p)
+		}
+	case *MultiLineString:
+		w.header(5)
+		w.uint32(len(*t))
+		for _, l := range *t {
+			w.header(2)
+			w.line(l, false)
+		}
+	case *MultiPolygon:
+		w.header(6)
+		w.uint32(len(*t))
+		for _, p := range *t {
+			w.polygon(p)
+		}
+	}
+}
+
+// wkbReader reads WKB, remembering the first error. Each geometry and part
+// has its own byte order.
+type wkbReader struct {
+	b   []byte
+	err error
+}
+
+func (r *wkbReader) next(n int) []byte {
+	if r.err == nil && len(r.b) < n {
+		r.err = errors.New("Problem reading geometry: WKB truncated")
+	}
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+// count reads a number of items each taking at least size bytes, so that
+// corrupt counts are caught before allocating.
+func (r *wkbReader) count(end binary.ByteOrder, size int) int {
+	n := end.Uint32(r.next(4))
+	if r.err == nil && uint64(n)*uint64(size) > uint64(len(r.b)) {
+		r.err = errors.New("Problem reading geometry: WKB truncated")
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// geometry reads a geometry with its header.
+func (r *wkbReader) geometry() Geometry {
+	order := r.next(1)[0]
+	if r.err == nil && order > 1 {
+		r.err = errors.New("Problem reading geometry: not WKB")
+	}
+	if r.err != nil {
+		return nil
+	}
+	end := endian[order]
+	typ := end.Uint32(r.next(4))
+	hasZ, hasM := typ&0x80000000 != 0, typ&0x40000000 != 0
+	if typ&0x20000000 != 0 {
+		r.next(4)
+	}
+	typ &= 0x0fffffff
+	switch typ / 1000 {
+	case 1:
+		hasZ = true
+	case 2:
+		hasM = true
+	case 3:
+		hasZ, hasM = true, true
+	}
+	return r.body(typ%1000, end, hasZ, hasM)
+}
+
+// body reads the geometry of the given type following its header.
+func (r *wkbReader) body(typ uint32, end binary.ByteOrder, hasZ, hasM bool) Geometry {
+	dims := 2
+	if hasZ {
+		dims++
+	}
+	if hasM {
+		dims++
+	}
+	point := func() Point {
+		b := r.next(8 * dims)
+		p := Point{X: math.Float64frombits(end.Uint64(b)), Y: math.Float64frombits(end.Uint64(b[8:]))}
+		if hasZ {
+			p.Z = math.Float64frombits(end.Uint64(b[16:]))
+		}
+		return p
+	}
+	points := func() []Point {
+		pts := make([]Point, r.count(end, 8*dims))
+		for i := range pts {
+			pts[i] = point()
+		}
+		return pts
+	}
+
+	switch typ {
+	case 1:
+		p := point()
+		return &p
+	case 2:
+		l := LineString(points())
+		return &l
+	case 3:
+		p := make(Polygon, r.count(end, 4))
+		for i := range p {
+			p[i] = wkbRing(points())
+		}
+		return &p
+	case 4:
+		m := make(MultiPoint, r.count(end, 5))
+		for i := range m {
+			p, ok := r.geometry().(*Point)
+			if !ok {
+				return r.badPart("Point")
+			}
+			m[i] = *p
+		}
+		return &m
+	case 5:
+		m := make(MultiLineString, r.count(end, 5))
+		for i := range m {
+			l, ok := r.geometry().(*LineString)
+			if !ok {
+				return r.badPart("LineString")
+			}
+			m[i] = *l
+		}
+		return &m
+	case 6:
+		m := make(MultiPolygon, r.count(end, 5))
+		for i := range m {
+			p, ok := r.geometry().(*Polygon)
+			if !ok {
+				return r.badPart("Polygon")
+			}
+			m[i] = *p
+		}
+		return &m
+	}
+	r.err = fmt.Errorf("WKB geometry type %d not supported", typ)
+	return nil
+}
+
+// wkbRing drops the closing point of a ring.
+func wkbRing(pts []Point) LinearRing {
+	if len(pts) > 1 && pts[0] == pts[len(pts)-1] {
+		pts = pts[:len(pts)-1]
+	}
+	return LinearRing(pts)
+}
+
+// badPart records a member of a multi geometry of the wrong type, unless
+// reading it already failed.
+func (r *wkbReader) badPart(want string) Geometry {
+	if r.err == nil {
+		r.err = fmt.Errorf("Problem reading geometry: expected %s in multi geometry", want)
+	}
+	return nil
+}
+
+// unmarshalWKB reads a geometry from WKB.
+func unmarshalWKB(in []byte) (Geometry, error) {
+	r := &wkbReader{b: in}
+	g := r.geometry()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return g, nil
+}
+
+// extractWKB reads from buf the body of an XY geometry of the given type,
+// or a whole geometry with its header when typ is 0, consuming what it
+// reads.
+func extractWKB(buf *bytes.Buffer, end binary.ByteOrder, typ uint32) (Geometry, error) {
+	r := &wkbReader{b: buf.Bytes()}
+	var g Geometry
+	if typ == 0 {
+		g = r.geometry()
+	} else {
+		g = r.body(typ, end, false, false)
+	}
+	buf.Next(buf.Len() - len(r.b))
+	if r.err != nil {
+		return nil, r.err
+	}
+	return g, nil
+}
@@ -0,0 +1,355 @@
+package geometry
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// wktNode is a keyword with its bracketed arguments. Arguments are either
+// nested nodes, quoted strings or bare numbers and enumerations.
+type wktNode struct {
+	Keyword string
+	Args    []interface{}
+}
+
+func (n *wktNode) child(keywords ...string) *wktNode {
+	for _, a := range n.Args {
+		if c, ok := a.(*wktNode); ok {
+			for _, k := range keywords {
+				if c.Keyword == k {
+					return c
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (n *wktNode) children(keyword string) []*wktNode {
+	out := []*wktNode{}
+	for _, a := range n.Args {
+		if c, ok := a.(*wktNode); ok && c.Keyword == keyword {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (n *wktNode) str(i int) string {
+	if i < len(n.Args) {
+		if s, ok := n.Args[i].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+func (n *wktNode) num(i int) (float64, error) {
+	f, err := strconv.ParseFloat(n.str(i), 64)
+	if err != nil {
+		return 0, fmt.Errorf("WKT %s: expected number at position %d", n.Keyword, i)
+	}
+	return f, nil
+}
+
+type wktParser struct {
+	in  string
+	pos int
+}
+
+func (p *wktParser) skipSpace() {
+	for p.pos < len(p.in) && unicode.IsSpace(rune(p.in[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *wktParser) node() (*wktNode, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.in) && (unicode.IsLetter(rune(p.in[p.pos])) || unicode.IsDigit(rune(p.in[p.pos])) || p.in[p.pos] == '_') {
+		p.pos++
+	}
+	n := &wktNode{Keyword: strings.ToUpper(p.in[start:p.pos])}
+	p.skipSpace()
+	if n.Keyword == "" || p.pos >= len(p.in) || (p.in[p.pos] != '[' && p.in[p.pos] != '(') {
+		return nil, fmt.Errorf("WKT CRS: expected keyword followed by '[' at offset %d", start)
+	}
+	closing := byte(']')
+	if p.in[p.pos] == '(' {
+		closing = ')'
+	}
+	p.pos++
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.in) {
+			return nil, errors.New("WKT CRS: unexpected end of input")
+		}
+		switch c := p.in[p.pos]; {
+		case c == closing:
+			p.pos++
+			return n, nil
+		case c == ',':
+			p.pos++
+		case c == '"':
+			p.pos++
+			var sb strings.Builder
+			for {
+				if p.pos >= len(p.in) {
+					return nil, errors.New("WKT CRS: unterminated string")
+				}
+				if p.in[p.pos] == '"' {
+					if p.pos+1 < len(p.in) && p.in[p.pos+1] == '"' {
+						sb.WriteByte('"')
+						p.pos += 2
+						continue
+					}
+					p.pos++
+					break
+				}
+				sb.WriteByte(p.in[p.pos])
+				p.pos++
+			}
+			n.Args = append(n.Args, sb.String())
+		default:
+			tokStart := p.pos
+			for p.pos < len(p.in) && !strings.ContainsRune(",[]() \t\r\n", rune(p.in[p.pos])) {
+				p.pos++
+			}
+			q := p.pos
+			p.skipSpace()
+			if p.pos < len(p.in) && (p.in[p.pos] == '[' || p.in[p.pos] == '(') {
+				p.pos = tokStart
+				child, err := p.node()
+				if err != nil {
+					return nil, err
+				}
+				n.Args = append(n.Args, child)
+			} else {
+				if q == tokStart {
+					return nil, fmt.Errorf("WKT CRS: unexpected character %q at offset %d", c, q)
+				}
+				n.Args = append(n.Args, p.in[tokStart:q])
+			}
+		}
+	}
+}
+
+var wktParamAliases = map[string]string{
+	"latitude_of_origin":                "lat0",
+	"latitude_of_center":                "lat0",
+	"latitude_of_natural_origin":        "lat0",
+	"latitude_of_false_origin":          "lat0",
+	"central_meridian":                  "lon0",
+	"longitude_of_center":               "lon0",
+	"longitude_of_origin":               "lon0",
+	"longitude_of_natural_origin":       "lon0",
+	"longitude_of_false_origin":         "lon0",
+	"scale_factor":                      "k0",
+	"scale_factor_at_natural_origin":    "k0",
+	"false_easting":                     "x0",
+	"easting_at_false_origin":           "x0",
+	"false_northing":                    "y0",
+	"northing_at_false_origin":          "y0",
+	"standard_parallel_1":               "lat1",
+	"latitude_of_1st_standard_parallel": "lat1",
+	"standard_parallel_2":               "lat2",
+	"latitude_of_2nd_standard_parallel": "lat2",
+}
+
+var wktDatums = map[string]Datum{
+	"world_geodetic_system_1984":          WGS84Datum,
+	"world_geodetic_system_1984_ensemble": WGS84Datum,
+	"wgs_1984":                            WGS84Datum,
+	"wgs84":                               WGS84Datum,
+	"geocentric_datum_of_australia_1994":  GDA94Datum,
+	"gda94":                               GDA94Datum,
+	"gda_1994":                            GDA94Datum,
+	"geocentric_datum_of_australia_2020":  GDA2020Datum,
+	"gda2020":                             GDA2020Datum,
+}
+
+func wktName(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer(" ", "_", "-", "_", "(", "", ")", "").Replace(s)
+}
+
+// ParseWKTCRS builds a CRS from an OGC WKT1 or WKT2 coordinate reference
+// system definition.
+func ParseWKTCRS(in string) (*CRS, error) {
+	p := &wktParser{in: in}
+	root, err := p.node()
+	if err != nil {
+		return nil, err
+	}
+
+	crs := &CRS{Name: root.str(0)}
+	if id := root.child("ID", "AUTHORITY"); id != nil && strings.EqualFold(id.str(0), "EPSG") {
+		crs.Code, _ = strconv.Atoi(id.str(1))
+	}
+
+	switch root.Keyword {
+	case "GEOGCS", "GEOGCRS", "GEODCRS", "GEOGRAPHICCRS":
+		crs.Datum, err = wktDatum(root)
+		return crs, err
+	case "PROJCS", "PROJCRS", "PROJECTEDCRS":
+	default:
+		return nil, fmt.Errorf("WKT CRS %s not supported", root.Keyword)
+	}
+
+	base := root.child("GEOGCS", "BASEGEOGCRS", "BASEGEODCRS")
+	if base == nil {
+		return nil, errors.New("WKT CRS: projected CRS without base geographic CRS")
+	}
+	if crs.Datum, err = wktDatum(base); err != nil {
+		return nil, err
+	}
+
+	// WKT1 keeps the method and parameters on the CRS, WKT2 inside the
+	// conversion.
+	conv := root
+	method := root.child("PROJECTION")
+	if c := root.child("CONVERSION"); c != nil {
+		conv = c
+		method = c.child("METHOD")
+	}
+	if method == nil {
+		return nil, errors.New("WKT CRS: projected CRS without projection method")
+	}
+	if err := wktCheckUnits(root, "linear", 1, "UNIT", "LENGTHUNIT"); err != nil {
+		return nil, err
+	}
+
+	params := map[string]float64{"k0": 1}
+	for _, param := range conv.children("PARAMETER") {
+		v, err := param.num(1)
+		if err != nil {
+			return nil, err
+		}
+		if unit := param.child("ANGLEUNIT", "LENGTHUNIT", "SCALEUNIT"); unit != nil {
+			f, err := unit.num(1)
+			if err != nil {
+				return nil, err
+			}
+			if unit.Keyword == "ANGLEUNIT" {
+				f /= deg
+			}
+			v *= f
+		}
+		if key, ok := wktParamAliases[wktName(param.str(0))]; ok {
+			params[key] = v
+		}
+	}
+	if _, ok := params["lat2"]; !ok {
+		params["lat2"] = params["lat1"]
+	}
+
+	ell := crs.Datum.Ellipsoid
+	switch wktName(method.str(0)) {
+	case "transverse_mercator":
+		crs.Projection = NewTransverseMercator(ell, params["lat0"], params["lon0"], params["k0"], params["x0"], params["y0"])
+	case "albers_conic_equal_area", "albers_equal_area":
+		crs.Projection = NewAlbersEqualArea(ell, params["lat1"], params["lat2"], params["lat0"], params["lon0"], params["x0"], params["y0"])
+	case "mercator_1sp", "mercator_variant_a", "mercator_2sp", "mercator_variant_b", "mercator":
+		k0 := params["k0"]
+		if lat1, ok := params["lat1"]; ok {
+			k0 = ell.parallelRadius(lat1)
+		}
+		crs.Projection = NewMercator(ell, params["lon0"], k0, params["x0"], params["y0"])
+	case "popular_visualisation_pseudo_mercator":
+		merc := NewMercator(ell, params["lon0"], 1, params["x0"], params["y0"])
+		merc.Spherical = true
+		crs.Projection = merc
+	default:
+		return nil, fmt.Errorf("WKT CRS projection method %s not supported", method.str(0))
+	}
+
+	return crs, nil
+}
+
+// wktCheckUnits returns an error unless every unit of the coordinate
+// system, given for the whole system or on its axes, is size metres or
+// radians.
+func wktCheckUnits(cs *wktNode, kind string, size float64, keywords ...string) error {
+	for _, n := range append([]*wktNode{cs}, cs.children("AXIS")...) {
+		for _, k := range keywords {
+			for _, unit := range n.children(k) {
+				f, err := unit.num(1)
+				if err != nil {
+					return err
+				}
+				if math.Abs(f-size) > 1e-12*size {
+					return fmt.Errorf("WKT CRS: %s unit %s not supported", kind, unit.str(0))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// wktDatum reads the datum of a geographic CRS, which must use degrees and
+// the Greenwich prime meridian as coordinates are kept as such.
+func wktDatum(geog *wktNode) (Datum, error) {
+	if err := wktCheckUnits(geog, "angular", deg, "UNIT", "ANGLEUNIT"); err != nil {
+		return Datum{}, err
+	}
+	if pm := geog.child("PRIMEM", "PRIMEMERIDIAN"); pm != nil {
+		if lon, err := pm.num(1); err != nil || lon != 0 {
+			return Datum{}, fmt.Errorf("WKT CRS: prime meridian %s not supported", pm.str(0))
+		}
+	}
+
+	d := geog.child("DATUM", "GEODETICDATUM", "ENSEMBLE")
+	if d == nil {
+		return Datum{}, errors.New("WKT CRS: geographic CRS without datum")
+	}
+	sph := d.child("SPHEROID", "ELLIPSOID")
+	if sph == nil {
+		return Datum{}, errors.New("WKT CRS: datum without ellipsoid")
+	}
+	a, err := sph.num(1)
+	if err != nil {
+		return Datum{}, err
+	}
+	rf, err := sph.num(2)
+	if err != nil {
+		return Datum{}, err
+	}
+	if unit := sph.child("LENGTHUNIT"); unit != nil {
+		f, err := unit.num(1)
+		if err != nil {
+			return Datum{}, err
+		}
+		a *= f
+	}
+	ell := Ellipsoid{A: a}
+	if rf != 0 {
+		ell.F = 1 / rf
+	}
+
+	// Datums are known by name, with the D_ prefix of ESRI names dropped,
+	// or by their TOWGS84. Others cannot be placed relative to WGS84.
+	datum := Datum{Name: d.str(0), Ellipsoid: ell}
+	known, ok := wktDatums[strings.TrimPrefix(wktName(d.str(0)), "d_")]
+	if tw := d.child("TOWGS84"); tw != nil {
+		var h [7]float64
+		for i := range h {
+			if i < len(tw.Args) {
+				if h[i], err = tw.num(i); err != nil {
+					return Datum{}, err
+				}
+			}
+		}
+		datum.ToWGS84 = Helmert{h[0], h[1], h[2], h[3], h[4], h[5], h[6]}
+	} else if ok && math.Abs(known.Ellipsoid.A-ell.A) < 1e-3 && math.Abs(known.Ellipsoid.F-ell.F) < 1e-12 {
+		datum.Ellipsoid, datum.ToWGS84 = known.Ellipsoid, known.ToWGS84
+	} else {
+		return Datum{}, fmt.Errorf("WKT CRS: datum %s not supported without TOWGS84", d.str(0))
+	}
+
+	return datum, nil
+}